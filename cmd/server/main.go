@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
 	"hex-postgres-grpc/internal/app"
+	"hex-postgres-grpc/internal/config"
+	authpb "hex-postgres-grpc/proto/auth"
 	categorypb "hex-postgres-grpc/proto/category"
 	customerpb "hex-postgres-grpc/proto/customer"
 	orderpb "hex-postgres-grpc/proto/order"
 	productpb "hex-postgres-grpc/proto/product"
+	userpb "hex-postgres-grpc/proto/user"
 	"log"
 	"net"
 	"net/http"
@@ -35,44 +42,92 @@ import (
 // @name Authorization
 
 func main() {
-	cfg := app.DBConfig{
-		User:     "admin",
-		Password: "password123",
-		HostPort: "localhost:5432",
-		Name:     "ordersdb",
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	if len(args) > 0 {
+		if args[0] != "migrate" {
+			log.Fatalf("unknown command %q", args[0])
+		}
+		if err := runMigrate(cfg, args[1:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	log.Printf("configuration:\n%s", cfg)
+
 	a, err := app.Init(cfg)
 	if err != nil {
 		log.Fatalf("init app: %v", err)
 	}
-	go func() {
-		mux := http.NewServeMux()
-		a.AuthHandler.RegisterRoutes(mux) // Register auth routes
-		a.Order.HTTPHandler.RegisterRoutes(mux)
-		a.Product.HTTPHandler.RegisterRoutes(mux)
-		a.Customer.HTTPHandler.RegisterRoutes(mux)
-		a.Category.HTTPHandler.RegisterRoutes(mux)
 
-		// Wrap mux with Auth middleware
-		handler := a.Auth.HTTPMiddleware(mux)
+	mux := http.NewServeMux()
+	a.AuthHandler.RegisterRoutes(mux) // Register auth routes
+	if a.OIDCHandler != nil {
+		a.OIDCHandler.RegisterRoutes(mux)
+	}
+	a.Order.HTTPHandler.RegisterRoutes(mux)
+	a.Product.HTTPHandler.RegisterRoutes(mux)
+	a.Customer.HTTPHandler.RegisterRoutes(mux)
+	a.Category.HTTPHandler.RegisterRoutes(mux)
 
-		log.Println("HTTP listening :8080")
-		log.Fatal(http.ListenAndServe(":8080", handler))
+	httpServer := &http.Server{
+		Addr: cfg.Server.HTTPAddr,
+		// Wrap mux with the auth and idempotency middleware
+		Handler:      a.HTTPMiddleware(mux),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	go func() {
+		log.Printf("HTTP listening %s", cfg.Server.HTTPAddr)
+		var err error
+		if tls := cfg.Server.TLS; tls.Enabled() {
+			err = httpServer.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("http serve: %v", err)
+		}
 	}()
 
-	grpcLis, err := net.Listen("tcp", ":50051")
+	// Metrics expose runtime and process details, so they are served on a
+	// listener of their own instead of the public API.
+	var debugServer *http.Server
+	if cfg.Server.DebugAddr != "" {
+		debugMux := http.NewServeMux()
+		debugMux.Handle("GET /debug/vars", expvar.Handler())
+		debugServer = &http.Server{Addr: cfg.Server.DebugAddr, Handler: debugMux}
+		go func() {
+			log.Printf("debug listening %s", cfg.Server.DebugAddr)
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("debug serve: %v", err)
+			}
+		}()
+	}
+
+	grpcLis, err := net.Listen("tcp", cfg.Server.GRPCAddr)
 	if err != nil {
 		log.Fatalf("grpc listen %v", err)
 	}
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(a.Auth.GRPCUnaryInterceptor),
-	)
+	opts, err := a.GRPCServerOptions()
+	if err != nil {
+		log.Fatalf("grpc options: %v", err)
+	}
+	grpcServer := grpc.NewServer(opts...)
+	authpb.RegisterAuthServiceServer(grpcServer, a.AuthGRPC)
+	userpb.RegisterUserServiceServer(grpcServer, a.UserGRPC)
 	orderpb.RegisterORderServiceServer(grpcServer, a.Order.GRPCServer)
 	productpb.RegisterProductServiceServer(grpcServer, a.Product.GRPCServer)
 	customerpb.RegisterCustomerServiceServer(grpcServer, a.Customer.GRPCServer)
 	categorypb.RegisterCategoryServiceServer(grpcServer, a.Category.GRPCHandler)
 	go func() {
-		log.Println("gRPC listening :50051")
+		log.Printf("gRPC listening %s", cfg.Server.GRPCAddr)
 		if err := grpcServer.Serve(grpcLis); err != nil {
 			log.Fatalf("grpc serve: %v", err)
 		}
@@ -82,6 +137,26 @@ func main() {
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 	<-sig
 	log.Println("shutting down gracefully")
-	grpcServer.GracefulStop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	if debugServer != nil {
+		if err := debugServer.Shutdown(ctx); err != nil {
+			log.Printf("debug shutdown: %v", err)
+		}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		grpcServer.Stop()
+	}
 	_ = a.DB.Close()
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hex-postgres-grpc/internal/app"
+	"hex-postgres-grpc/internal/config"
+	"hex-postgres-grpc/internal/migrate"
+	"hex-postgres-grpc/migrations"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: server [flags] migrate up | down [steps] | status | baseline <version>"
+
+// runMigrate implements the migrate subcommand:
+//
+//	migrate up                 apply every pending migration
+//	migrate down [steps]       revert the latest migration, or the latest steps
+//	migrate status             list migrations and when they were applied
+//	migrate baseline <version> mark migrations up to version as applied
+func runMigrate(cfg config.Config, args []string) error {
+	switch {
+	case len(args) == 1 && (args[0] == "up" || args[0] == "status"):
+	case len(args) >= 1 && len(args) <= 2 && args[0] == "down":
+	case len(args) == 2 && args[0] == "baseline":
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	db, err := app.OpenDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		printMigrations("applied", applied)
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		printMigrations("reverted", reverted)
+		return err
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT\tNOTE")
+		for _, s := range statuses {
+			appliedAt, note := "pending", ""
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			switch {
+			case s.Unknown:
+				note = "no migration file"
+			case s.Modified:
+				note = "modified since applied"
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, appliedAt, note)
+		}
+		return w.Flush()
+
+	case "baseline":
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 1 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		recorded, err := m.Baseline(ctx, version)
+		printMigrations("recorded", recorded)
+		return err
+	}
+	return errors.New(migrateUsage)
+}
+
+func printMigrations(verb string, ms []migrate.Migration) {
+	for _, mig := range ms {
+		fmt.Printf("%s %03d_%s\n", verb, mig.Version, mig.Name)
+	}
+	if len(ms) == 0 {
+		fmt.Printf("nothing %s\n", verb)
+	}
+}
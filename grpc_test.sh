@@ -89,12 +89,12 @@ echo -e "${YELLOW}===== ORDER SERVICE TESTS =====${NC}"
 
 # Create Order
 test_grpc_call "orderpb.ORderService" "CreateOrder" \
-'{"amount": 1299.98}' \
+'{"customer_id": "1", "items": [{"product_id": "1", "quantity": 2}]}' \
 "Create Order"
 
 # Create another order
 test_grpc_call "orderpb.ORderService" "CreateOrder" \
-'{"amount": 59.99}' \
+'{"customer_id": "1", "items": [{"product_id": "2", "quantity": 1}]}' \
 "Create Another Order"
 
 # List Orders
@@ -109,9 +109,19 @@ test_grpc_call "orderpb.ORderService" "GetOrder" \
 
 # Update Order (assuming ID 1 exists)
 test_grpc_call "orderpb.ORderService" "UpdateOrder" \
-'{"id": "1", "amount": 1399.99}' \
+'{"id": "1", "items": [{"product_id": "1", "quantity": 3}]}' \
 "Update Order with ID 1"
 
+# Confirm Order (assuming ID 1 exists)
+test_grpc_call "orderpb.ORderService" "TransitionOrder" \
+'{"id": "1", "status": "confirmed"}' \
+"Confirm Order with ID 1"
+
+# List Order Transitions (assuming ID 1 exists)
+test_grpc_call "orderpb.ORderService" "ListOrderTransitions" \
+'{"id": "1"}' \
+"List Transitions of Order with ID 1"
+
 # Delete Order (assuming ID 2 exists)
 test_grpc_call "orderpb.ORderService" "DeleteOrder" \
 '{"id": "2"}' \
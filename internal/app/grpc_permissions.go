@@ -0,0 +1,56 @@
+package app
+
+import (
+	"hex-postgres-grpc/internal/auth"
+	authpb "hex-postgres-grpc/proto/auth"
+	categorypb "hex-postgres-grpc/proto/category"
+	customerpb "hex-postgres-grpc/proto/customer"
+	orderpb "hex-postgres-grpc/proto/order"
+	productpb "hex-postgres-grpc/proto/product"
+	userpb "hex-postgres-grpc/proto/user"
+)
+
+// grpcPermissions declares the permission required by every gRPC method. It is
+// enforced by auth.Service.GRPCUnaryInterceptor; a method that is not listed
+// here is refused, so new RPCs must be added explicitly.
+var grpcPermissions = auth.MethodPermissions{
+	authpb.AuthService_Login_FullMethodName:     {Public: true},
+	authpb.AuthService_Refresh_FullMethodName:   {Public: true},
+	authpb.AuthService_Logout_FullMethodName:    {Public: true},
+	authpb.AuthService_VerifyMFA_FullMethodName: {Public: true},
+
+	userpb.UserService_CreateUser_FullMethodName:     {Action: auth.ActionCreate, ResourceType: "user"},
+	userpb.UserService_GetUser_FullMethodName:        {Action: auth.ActionRead, ResourceType: "user"},
+	userpb.UserService_UpdateUser_FullMethodName:     {Action: auth.ActionUpdate, ResourceType: "user"},
+	userpb.UserService_DeleteUser_FullMethodName:     {Action: auth.ActionDelete, ResourceType: "user"},
+	userpb.UserService_ListUsers_FullMethodName:      {Action: auth.ActionRead, ResourceType: "user"},
+	userpb.UserService_DeactivateUser_FullMethodName: {Action: auth.ActionUpdate, ResourceType: "user"},
+	userpb.UserService_ActivateUser_FullMethodName:   {Action: auth.ActionUpdate, ResourceType: "user"},
+	userpb.UserService_GetMe_FullMethodName:          {},
+	userpb.UserService_UpdateMe_FullMethodName:       {},
+
+	orderpb.ORderService_CreateOrder_FullMethodName:          {Action: auth.ActionCreate, ResourceType: "order"},
+	orderpb.ORderService_GetOrder_FullMethodName:             {Action: auth.ActionRead, ResourceType: "order"},
+	orderpb.ORderService_UpdateOrder_FullMethodName:          {Action: auth.ActionUpdate, ResourceType: "order"},
+	orderpb.ORderService_DeleteOrder_FullMethodName:          {Action: auth.ActionDelete, ResourceType: "order"},
+	orderpb.ORderService_ListOrders_FullMethodName:           {Action: auth.ActionRead, ResourceType: "order"},
+	orderpb.ORderService_TransitionOrder_FullMethodName:      {Action: auth.ActionUpdate, ResourceType: "order"},
+	orderpb.ORderService_ListOrderTransitions_FullMethodName: {Action: auth.ActionRead, ResourceType: "order"},
+	orderpb.ORderService_RestoreOrder_FullMethodName:         {Action: auth.ActionDelete, ResourceType: "order"},
+
+	productpb.ProductService_CreateProduct_FullMethodName: {Action: auth.ActionCreate, ResourceType: "product"},
+	productpb.ProductService_GetProduct_FullMethodName:    {Action: auth.ActionRead, ResourceType: "product"},
+	productpb.ProductService_UpdateProduct_FullMethodName: {Action: auth.ActionUpdate, ResourceType: "product"},
+	productpb.ProductService_DeleteProduct_FullMethodName: {Action: auth.ActionDelete, ResourceType: "product"},
+	productpb.ProductService_ListProducts_FullMethodName:  {Action: auth.ActionRead, ResourceType: "product"},
+
+	customerpb.CustomerService_CreateCustomer_FullMethodName: {Action: auth.ActionCreate, ResourceType: "customer"},
+	customerpb.CustomerService_GetCustomer_FullMethodName:    {Action: auth.ActionRead, ResourceType: "customer"},
+	customerpb.CustomerService_ListCustomers_FullMethodName:  {Action: auth.ActionRead, ResourceType: "customer"},
+
+	categorypb.CategoryService_CreateCategory_FullMethodName: {Action: auth.ActionCreate, ResourceType: "category"},
+	categorypb.CategoryService_GetCategory_FullMethodName:    {Action: auth.ActionRead, ResourceType: "category"},
+	categorypb.CategoryService_UpdateCategory_FullMethodName: {Action: auth.ActionUpdate, ResourceType: "category"},
+	categorypb.CategoryService_DeleteCategory_FullMethodName: {Action: auth.ActionDelete, ResourceType: "category"},
+	categorypb.CategoryService_ListCategories_FullMethodName: {Action: auth.ActionRead, ResourceType: "category"},
+}
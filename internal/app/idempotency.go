@@ -0,0 +1,35 @@
+package app
+
+import (
+	"context"
+	"net/http"
+
+	"hex-postgres-grpc/internal/auth"
+	commonhttp "hex-postgres-grpc/internal/common/adapters/http"
+	orderpb "hex-postgres-grpc/proto/order"
+	productpb "hex-postgres-grpc/proto/product"
+)
+
+// idempotentRoutes and idempotentMethods accept an idempotency key, so that
+// clients can retry them without creating duplicates.
+var (
+	idempotentRoutes = []string{
+		"POST /orders",
+		"POST /products",
+	}
+	idempotentMethods = []string{
+		orderpb.ORderService_CreateOrder_FullMethodName,
+		productpb.ProductService_CreateProduct_FullMethodName,
+	}
+)
+
+// HTTPMiddleware authenticates requests and then applies idempotency keys.
+func (a *Application) HTTPMiddleware(next http.Handler) http.Handler {
+	idempotent := commonhttp.Idempotent(a.Idempotency, subjectID, idempotentRoutes...)
+	return a.Auth.HTTPMiddleware(idempotent(next))
+}
+
+func subjectID(ctx context.Context) string {
+	sub, _ := auth.SubjectFromContext(ctx)
+	return sub.ID
+}
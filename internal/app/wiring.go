@@ -1,16 +1,36 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"hex-postgres-grpc/internal/auth"
+	authfile "hex-postgres-grpc/internal/auth/adapters/file"
+	authgrpc "hex-postgres-grpc/internal/auth/adapters/grpc"
+	authoidc "hex-postgres-grpc/internal/auth/adapters/oidc"
 	authpg "hex-postgres-grpc/internal/auth/adapters/postgres"
+	authsmtp "hex-postgres-grpc/internal/auth/adapters/smtp"
 	"hex-postgres-grpc/internal/category"
+	"hex-postgres-grpc/internal/common/adapters/eventbus"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/adapters/webhook"
+	"hex-postgres-grpc/internal/common/events"
+	"hex-postgres-grpc/internal/common/idempotency"
+	"hex-postgres-grpc/internal/common/interceptors"
+	"hex-postgres-grpc/internal/common/uow"
+	"hex-postgres-grpc/internal/config"
 	"hex-postgres-grpc/internal/customer"
+	"hex-postgres-grpc/internal/migrate"
 	"hex-postgres-grpc/internal/order"
 	"hex-postgres-grpc/internal/product"
+	"hex-postgres-grpc/migrations"
+	"log"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type Application struct {
@@ -21,43 +41,217 @@ type Application struct {
 	Category    category.Component
 	Auth        auth.Service
 	AuthHandler *auth.Handler
+	AuthGRPC    *authgrpc.Server
+	UserGRPC    *authgrpc.UserServer
 	AuthRepo    auth.UserRepository
+	// UnitOfWork runs changes across repositories in one transaction.
+	UnitOfWork uow.UnitOfWork
+	// Idempotency stores the responses of create requests sent with an
+	// idempotency key.
+	Idempotency *idempotency.Service
+	// Events delivers domain events to in-process subscribers unless a
+	// webhook is configured.
+	Events *eventbus.Bus
+	// OIDCHandler is nil unless an OpenID Connect provider is configured.
+	OIDCHandler *auth.OIDCHandler
+
+	cfg config.Config
 }
 
-func Init(cfg DBConfig) (*Application, error) {
-	db, err := initDB(cfg)
+func Init(cfg config.Config) (*Application, error) {
+	db, err := OpenDB(cfg.Database)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.Database.AutoMigrate {
+		m, err := migrate.New(db, migrations.FS)
+		if err != nil {
+			return nil, err
+		}
+		applied, err := m.Up(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+		for _, mig := range applied {
+			log.Printf("migrate: applied %03d_%s", mig.Version, mig.Name)
+		}
+	}
+
+	// Units of work run serializable, so that a use case that reads and
+	// then writes sees no concurrent change; conflicts are retried.
+	unitOfWork := commonpg.NewTxManager(db, commonpg.TxConfig{Isolation: sql.LevelSerializable})
+
+	idempotencySvc := idempotency.NewService(commonpg.NewIdempotencyStore(db), cfg.Server.IdempotencyTTL)
+	go idempotencySvc.Run(context.Background(), time.Hour)
+
+	// Domain events are recorded in the outbox with the writes they
+	// describe and relayed to the webhook when one is configured, otherwise
+	// to in-process subscribers.
+	outbox := commonpg.NewOutboxStore(db)
+	bus := eventbus.New()
+	var publisher events.EventPublisher = bus
+	if cfg.Events.WebhookURL != "" {
+		publisher = webhook.NewPublisher(webhook.Config{
+			URL:     cfg.Events.WebhookURL,
+			Secret:  string(cfg.Events.WebhookSecret),
+			Timeout: cfg.Events.WebhookTimeout,
+		})
+	}
+	relay := events.NewRelay(outbox, publisher, events.RelayConfig{
+		Interval:    cfg.Events.RelayInterval,
+		BatchSize:   cfg.Events.RelayBatchSize,
+		MaxAttempts: cfg.Events.MaxAttempts,
+	})
+	go relay.Run(context.Background())
 
 	authRepo := authpg.NewRepository(db)
-	authSvc := auth.NewService("super-secret-key", authRepo)
+	keys, err := auth.NewKeyManager(context.Background(), authpg.NewSigningKeyRepository(db), auth.KeyManagerConfig{
+		Algorithm:        cfg.Auth.KeyAlgorithm,
+		RotationInterval: cfg.Auth.KeyRotationInterval,
+		Overlap:          cfg.Auth.KeyOverlap,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("init signing keys: %w", err)
+	}
+	go keys.Run(context.Background())
+
+	// Policies come from the policy file when set, otherwise from the
+	// policies table.
+	var policySource auth.PolicySource = authpg.NewPolicySource(db)
+	if cfg.Auth.PolicyFile != "" {
+		policySource = authfile.NewPolicySource(cfg.Auth.PolicyFile)
+	}
+	policies, err := auth.NewPolicyEngine(context.Background(), policySource, cfg.Auth.PolicyReloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("init policies: %w", err)
+	}
+	go policies.Run(context.Background())
+
+	claims := auth.DefaultClaimMapping()
+	if cfg.OIDC.ClaimMapping != "" {
+		if claims, err = authfile.LoadClaimMapping(cfg.OIDC.ClaimMapping); err != nil {
+			return nil, fmt.Errorf("load oidc claim mapping: %w", err)
+		}
+	}
+
+	// Mail goes through SMTP when configured; otherwise it is appended to
+	// the mail file, or logged.
+	var mailer auth.Mailer = authfile.NewMailer(cfg.Mail.File)
+	if cfg.Mail.SMTPAddr != "" {
+		mailer = authsmtp.NewMailer(authsmtp.Config{
+			Addr:     cfg.Mail.SMTPAddr,
+			Username: cfg.Mail.SMTPUsername,
+			Password: string(cfg.Mail.SMTPPassword),
+			From:     cfg.Mail.From,
+		})
+	}
+
+	authSvc := auth.NewService(auth.ServiceConfig{
+		Keys:           keys,
+		Policies:       policies,
+		Users:          authRepo,
+		Hasher:         auth.NewBcryptHasher(cfg.Auth.BcryptCost),
+		RefreshTokens:  authpg.NewRefreshTokenRepository(db),
+		APIKeys:        authpg.NewAPIKeyRepository(db),
+		Methods:        grpcPermissions,
+		Identities:     authpg.NewIdentityRepository(db),
+		ClaimMapping:   claims,
+		MFA:            authpg.NewMFARepository(db),
+		MFAIssuer:      cfg.Auth.MFAIssuer,
+		LoginAttempts:  authpg.NewLoginAttemptRepository(db),
+		UserTokens:     authpg.NewUserTokenRepository(db),
+		Impersonations: authpg.NewImpersonationRepository(db),
+		UnitOfWork:     unitOfWork,
+		Mailer:         mailer,
+		AppURL:         cfg.Auth.AppURL,
+	})
 	authHandler := auth.NewHandler(authSvc)
 
+	// OIDC login is enabled by setting an issuer URL.
+	var oidcHandler *auth.OIDCHandler
+	if cfg.OIDC.IssuerURL != "" {
+		provider, err := authoidc.NewProvider(context.Background(), authoidc.Config{
+			IssuerURL:    cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: string(cfg.OIDC.ClientSecret),
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       []string{"profile", "email"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("init oidc provider: %w", err)
+		}
+		oidcHandler = auth.NewOIDCHandler(authSvc, provider, strings.HasPrefix(cfg.OIDC.RedirectURL, "https://"))
+	}
+
+	productModule := product.Init(db, authSvc, unitOfWork, outbox)
+	customerModule := customer.Init(db, authSvc, unitOfWork, outbox)
+
 	return &Application{
 		DB:          db,
-		Order:       order.Init(db, authSvc),
-		Product:     product.Init(db, authSvc),
-		Customer:    customer.Init(db, authSvc),
+		Order:       order.Init(db, authSvc, productModule.Service, customerModule.Service, unitOfWork, outbox),
+		Product:     productModule,
+		Customer:    customerModule,
 		Category:    category.Init(db, authSvc),
 		Auth:        authSvc,
 		AuthHandler: authHandler,
+		AuthGRPC:    authgrpc.NewServer(authSvc),
+		UserGRPC:    authgrpc.NewUserServer(authSvc),
 		AuthRepo:    authRepo,
+		UnitOfWork:  unitOfWork,
+		Idempotency: idempotencySvc,
+		Events:      bus,
+		OIDCHandler: oidcHandler,
+		cfg:         cfg,
 	}, nil
 }
 
-func initDB(cfg DBConfig) (*sql.DB, error) {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", cfg.User, cfg.Password, cfg.HostPort, cfg.Name)
-	db, err := sql.Open("postgres", connStr)
+// OpenDB connects to the database and configures the connection pool.
+func OpenDB(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN())
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
 	return db, nil
 }
 
-type DBConfig struct {
-	User, Password, HostPort, Name string
+// GRPCServerOptions builds the interceptor pipeline shared by unary and
+// streaming RPCs: logging and metrics see the final status, domain errors
+// are turned into statuses, recovery turns panics into Internal errors, and
+// auth runs right before the handler, followed only by idempotency keys,
+// which are scoped to the authenticated caller.
+// The listener uses TLS when a certificate is configured.
+func (a *Application) GRPCServerOptions() ([]grpc.ServerOption, error) {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			interceptors.UnaryLogging,
+			interceptors.UnaryMetrics,
+			interceptors.UnaryErrors,
+			interceptors.UnaryRecovery,
+			a.Auth.GRPCUnaryInterceptor,
+			interceptors.UnaryIdempotency(a.Idempotency, subjectID, idempotentMethods...),
+		),
+		grpc.ChainStreamInterceptor(
+			interceptors.StreamLogging,
+			interceptors.StreamMetrics,
+			interceptors.StreamErrors,
+			interceptors.StreamRecovery,
+			a.Auth.GRPCStreamInterceptor,
+		),
+	}
+	if tls := a.cfg.Server.TLS; tls.Enabled() {
+		creds, err := credentials.NewServerTLSFromFile(tls.CertFile, tls.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	return opts, nil
 }
@@ -0,0 +1,30 @@
+package file
+
+import (
+	"encoding/json"
+	"hex-postgres-grpc/internal/auth"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LoadClaimMapping reads OIDC claim mapping rules from a JSON or YAML file.
+func LoadClaimMapping(path string) (auth.ClaimMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return auth.ClaimMapping{}, err
+	}
+
+	var m auth.ClaimMapping
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &m)
+	} else {
+		err = yaml.UnmarshalStrict(data, &m)
+	}
+	if err != nil {
+		return auth.ClaimMapping{}, err
+	}
+	return m, nil
+}
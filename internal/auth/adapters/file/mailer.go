@@ -0,0 +1,42 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"hex-postgres-grpc/internal/auth"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Mailer appends messages to a file instead of sending them, for local
+// development. With an empty path messages are written to the log.
+type Mailer struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewMailer(path string) *Mailer {
+	return &Mailer{path: path}
+}
+
+func (m *Mailer) Send(ctx context.Context, msg auth.Message) error {
+	text := fmt.Sprintf("Date: %s\nTo: %s\nSubject: %s\n\n%s\n", time.Now().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	if m.path == "" {
+		log.Printf("mail:\n%s", text)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(text + "----\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,40 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"hex-postgres-grpc/internal/auth"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PolicySource reads policies from a JSON or YAML file. The file is read on
+// every load, so edits are picked up by the next reload.
+type PolicySource struct {
+	path string
+}
+
+func NewPolicySource(path string) *PolicySource {
+	return &PolicySource{path: path}
+}
+
+func (s *PolicySource) LoadPolicies(ctx context.Context) (auth.PolicySet, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return auth.PolicySet{}, err
+	}
+
+	var set auth.PolicySet
+	if strings.EqualFold(filepath.Ext(s.path), ".json") {
+		err = json.Unmarshal(data, &set)
+	} else {
+		err = yaml.UnmarshalStrict(data, &set)
+	}
+	if err != nil {
+		return auth.PolicySet{}, err
+	}
+	return set, nil
+}
@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"hex-postgres-grpc/internal/auth"
+	authpb "hex-postgres-grpc/proto/auth"
+)
+
+type Server struct {
+	authpb.UnimplementedAuthServiceServer
+	service auth.Service
+}
+
+func NewServer(service auth.Service) *Server {
+	return &Server{service: service}
+}
+
+func (s *Server) Login(ctx context.Context, req *authpb.LoginRequest) (*authpb.LoginResponse, error) {
+	result, err := s.service.Login(ctx, req.Username, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if result.Challenge != nil {
+		return &authpb.LoginResponse{
+			MfaRequired:        true,
+			ChallengeToken:     result.Challenge.ChallengeToken,
+			ChallengeExpiresIn: result.Challenge.ExpiresIn,
+		}, nil
+	}
+	return &authpb.LoginResponse{Tokens: toTokenPairMessage(*result.Tokens)}, nil
+}
+
+func (s *Server) VerifyMFA(ctx context.Context, req *authpb.VerifyMFARequest) (*authpb.VerifyMFAResponse, error) {
+	tokens, err := s.service.VerifyMFA(ctx, req.ChallengeToken, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.VerifyMFAResponse{Tokens: toTokenPairMessage(tokens)}, nil
+}
+
+func (s *Server) Refresh(ctx context.Context, req *authpb.RefreshRequest) (*authpb.RefreshResponse, error) {
+	tokens, err := s.service.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.RefreshResponse{Tokens: toTokenPairMessage(tokens)}, nil
+}
+
+func (s *Server) Logout(ctx context.Context, req *authpb.LogoutRequest) (*authpb.LogoutResponse, error) {
+	if err := s.service.Logout(ctx, req.RefreshToken); err != nil {
+		return nil, err
+	}
+	return &authpb.LogoutResponse{Success: true}, nil
+}
+
+func toTokenPairMessage(t auth.TokenPair) *authpb.TokenPairMessage {
+	return &authpb.TokenPairMessage{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		TokenType:    t.TokenType,
+		ExpiresIn:    t.ExpiresIn,
+	}
+}
@@ -0,0 +1,211 @@
+package grpc
+
+import (
+	"context"
+	"hex-postgres-grpc/internal/auth"
+	userpb "hex-postgres-grpc/proto/user"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserServer exposes user management over gRPC. Authorization is checked by
+// auth.Service, as for the HTTP handlers.
+type UserServer struct {
+	userpb.UnimplementedUserServiceServer
+	service auth.Service
+}
+
+func NewUserServer(service auth.Service) *UserServer {
+	return &UserServer{service: service}
+}
+
+func (s *UserServer) CreateUser(ctx context.Context, req *userpb.CreateUserRequest) (*userpb.CreateUserResponse, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	u := &auth.User{
+		Username:       req.Username,
+		Password:       req.Password,
+		Role:           req.Role,
+		Attributes:     req.Attributes.AsMap(),
+		Email:          req.Email,
+		ServiceAccount: req.ServiceAccount,
+	}
+	if err := s.service.CreateUser(ctx, sub, u); err != nil {
+		return nil, err
+	}
+
+	msg, err := toUserMessage(u)
+	if err != nil {
+		return nil, err
+	}
+	return &userpb.CreateUserResponse{User: msg}, nil
+}
+
+func (s *UserServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.GetUserResponse, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	u, err := s.service.GetUser(ctx, sub, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := toUserMessage(u)
+	if err != nil {
+		return nil, err
+	}
+	return &userpb.GetUserResponse{User: msg}, nil
+}
+
+func (s *UserServer) UpdateUser(ctx context.Context, req *userpb.UpdateUserRequest) (*userpb.UpdateUserResponse, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	u := &auth.User{
+		ID:         req.Id,
+		Username:   req.Username,
+		Password:   req.Password,
+		Role:       req.Role,
+		Attributes: req.Attributes.AsMap(),
+		Email:      req.Email,
+	}
+	if err := s.service.UpdateUser(ctx, sub, u); err != nil {
+		return nil, err
+	}
+
+	msg, err := toUserMessage(u)
+	if err != nil {
+		return nil, err
+	}
+	return &userpb.UpdateUserResponse{User: msg}, nil
+}
+
+func (s *UserServer) DeleteUser(ctx context.Context, req *userpb.DeleteUserRequest) (*userpb.DeleteUserResponse, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	if err := s.service.DeleteUser(ctx, sub, req.Id); err != nil {
+		return nil, err
+	}
+	return &userpb.DeleteUserResponse{}, nil
+}
+
+func (s *UserServer) ListUsers(ctx context.Context, req *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	users, err := s.service.ListUsers(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &userpb.ListUsersResponse{}
+	for _, u := range users {
+		msg, err := toUserMessage(u)
+		if err != nil {
+			return nil, err
+		}
+		resp.Users = append(resp.Users, msg)
+	}
+	return resp, nil
+}
+
+func (s *UserServer) DeactivateUser(ctx context.Context, req *userpb.DeactivateUserRequest) (*userpb.DeactivateUserResponse, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	if err := s.service.SetUserDisabled(ctx, sub, req.Id, true); err != nil {
+		return nil, err
+	}
+	return &userpb.DeactivateUserResponse{}, nil
+}
+
+func (s *UserServer) ActivateUser(ctx context.Context, req *userpb.ActivateUserRequest) (*userpb.ActivateUserResponse, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	if err := s.service.SetUserDisabled(ctx, sub, req.Id, false); err != nil {
+		return nil, err
+	}
+	return &userpb.ActivateUserResponse{}, nil
+}
+
+func (s *UserServer) GetMe(ctx context.Context, req *userpb.GetMeRequest) (*userpb.GetMeResponse, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	u, err := s.service.GetMe(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := toUserMessage(u)
+	if err != nil {
+		return nil, err
+	}
+	return &userpb.GetMeResponse{User: msg}, nil
+}
+
+func (s *UserServer) UpdateMe(ctx context.Context, req *userpb.UpdateMeRequest) (*userpb.UpdateMeResponse, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	u, err := s.service.UpdateMe(ctx, sub, auth.ProfileUpdate{
+		Email:           req.Email,
+		Password:        req.Password,
+		CurrentPassword: req.CurrentPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := toUserMessage(u)
+	if err != nil {
+		return nil, err
+	}
+	return &userpb.UpdateMeResponse{User: msg}, nil
+}
+
+func toUserMessage(u *auth.User) (*userpb.UserMessage, error) {
+	attrs, err := structpb.NewStruct(u.Attributes)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encode attributes: %v", err)
+	}
+	return &userpb.UserMessage{
+		Id:             u.ID,
+		Username:       u.Username,
+		Role:           u.Role,
+		Attributes:     attrs,
+		ServiceAccount: u.ServiceAccount,
+		Email:          u.Email,
+		EmailVerified:  u.EmailVerified,
+		Disabled:       u.Disabled,
+		CreatedAt:      timestamppb.New(u.CreatedAt),
+		UpdatedAt:      timestamppb.New(u.UpdatedAt),
+	}, nil
+}
+
+// userError maps service errors to gRPC codes. Errors without a mapping get
+// fallback, mirroring the status codes of the HTTP handlers.
@@ -0,0 +1,349 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hex-postgres-grpc/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeIssuer is a minimal OpenID Connect provider: it serves discovery,
+// JWKS, an authorization endpoint that approves every request and a token
+// endpoint that enforces PKCE.
+type fakeIssuer struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+	// claims are put into every ID token, next to the standard ones.
+	claims map[string]interface{}
+	// signingKey signs ID tokens; it defaults to key, which is published.
+	signingKey *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+type grant struct {
+	challenge string
+	nonce     string
+}
+
+const (
+	clientID     = "shop"
+	clientSecret = "secret"
+	redirectURL  = "https://shop.example/auth/oidc/callback"
+)
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeIssuer{key: key, codes: map[string]grant{}, claims: map[string]interface{}{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", f.discovery)
+	mux.HandleFunc("GET /keys", f.jwks)
+	mux.HandleFunc("GET /authorize", f.authorize)
+	mux.HandleFunc("POST /token", f.token)
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                f.srv.URL,
+		"authorization_endpoint":                f.srv.URL + "/authorize",
+		"token_endpoint":                        f.srv.URL + "/token",
+		"jwks_uri":                              f.srv.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (f *fakeIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize signs the user in right away and redirects back with a code
+// bound to the PKCE challenge and nonce of the request.
+func (f *fakeIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != clientID || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	code := "code-" + q.Get("state")
+	f.mu.Lock()
+	f.codes[code] = grant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	f.mu.Unlock()
+
+	back, _ := url.Parse(q.Get("redirect_uri"))
+	bq := back.Query()
+	bq.Set("code", code)
+	bq.Set("state", q.Get("state"))
+	back.RawQuery = bq.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (f *fakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != clientID || secret != clientSecret {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	f.mu.Lock()
+	g, ok := f.codes[r.PostForm.Get("code")]
+	delete(f.codes, r.PostForm.Get("code"))
+	f.mu.Unlock()
+	if !ok {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   f.srv.URL,
+		"sub":   "idp-user-1",
+		"aud":   clientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": g.nonce,
+	}
+	for k, v := range f.claims {
+		claims[k] = v
+	}
+	signingKey := f.signingKey
+	if signingKey == nil {
+		signingKey = f.key
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = "k1"
+	raw, err := idToken.SignedString(signingKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "at",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     raw,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func newTestProvider(t *testing.T, f *fakeIssuer) *Provider {
+	t.Helper()
+	p, err := NewProvider(context.Background(), Config{
+		IssuerURL:    f.srv.URL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"profile", "email"},
+	})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return p
+}
+
+// approve sends the browser to the authorization URL and returns the code
+// the issuer redirects back with.
+func approve(t *testing.T, authURL string) string {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc.Query().Get("code")
+}
+
+func TestAuthCodeURLSendsPKCEChallenge(t *testing.T) {
+	p := newTestProvider(t, newFakeIssuer(t))
+
+	u, err := url.Parse(p.AuthCodeURL("st", "nc", "the-verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	sum := sha256.Sum256([]byte("the-verifier"))
+	if got, want := q.Get("code_challenge"), base64.RawURLEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("code_challenge = %q, want %q", got, want)
+	}
+	if got := q.Get("code_challenge_method"); got != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", got)
+	}
+	if strings.Contains(u.String(), "the-verifier") {
+		t.Error("the verifier itself is sent to the provider")
+	}
+	if q.Get("state") != "st" || q.Get("nonce") != "nc" || q.Get("redirect_uri") != redirectURL {
+		t.Errorf("unexpected query %v", q)
+	}
+	if scopes := q.Get("scope"); !strings.Contains(scopes, "openid") {
+		t.Errorf("scope = %q, want openid", scopes)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		nonce    string
+		// forge signs the ID token with a key the issuer does not publish.
+		forge   bool
+		wantErr bool
+	}{
+		{name: "valid", verifier: "v1", nonce: "n1"},
+		{name: "wrong verifier", verifier: "other", nonce: "n1", wantErr: true},
+		{name: "nonce mismatch", verifier: "v1", nonce: "n2", wantErr: true},
+		{name: "forged id token", verifier: "v1", nonce: "n1", forge: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeIssuer(t)
+			f.claims = map[string]interface{}{"email": "ann@example.com", "groups": []string{"staff"}}
+			if tt.forge {
+				other, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.signingKey = other
+			}
+			p := newTestProvider(t, f)
+
+			// The authorization request always uses v1 and n1.
+			code := approve(t, p.AuthCodeURL("st", "n1", "v1"))
+			id, err := p.Exchange(context.Background(), code, tt.verifier, tt.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if id.Issuer != f.srv.URL || id.Subject != "idp-user-1" {
+				t.Errorf("identity = %s %s", id.Issuer, id.Subject)
+			}
+			if id.Claims["email"] != "ann@example.com" {
+				t.Errorf("claims = %v", id.Claims)
+			}
+		})
+	}
+}
+
+// recordingService is an auth.Service that records external logins.
+type recordingService struct {
+	auth.Service
+	identities []auth.ExternalIdentity
+}
+
+func (s *recordingService) LoginExternal(ctx context.Context, id auth.ExternalIdentity) (auth.TokenPair, error) {
+	s.identities = append(s.identities, id)
+	return auth.TokenPair{AccessToken: "access", TokenType: "Bearer"}, nil
+}
+
+func TestLoginFlow(t *testing.T) {
+	f := newFakeIssuer(t)
+	f.claims = map[string]interface{}{"preferred_username": "ann"}
+	svc := &recordingService{}
+	h := auth.NewOIDCHandler(svc, newTestProvider(t, f), true)
+
+	// Login stores the state in a cookie and redirects to the issuer.
+	rec := httptest.NewRecorder()
+	h.Login(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login?tenant=acme", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: status %d", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("login: cookies %v", cookies)
+	}
+	authURL, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := authURL.Query().Get("state")
+	code := approve(t, authURL.String())
+
+	callback := func(state string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+		r.AddCookie(cookies[0])
+		rec := httptest.NewRecorder()
+		h.Callback(rec, r)
+		return rec
+	}
+
+	// A callback whose state does not match the cookie is rejected before
+	// the code is redeemed.
+	if rec := callback("forged"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("callback with forged state: status %d", rec.Code)
+	}
+	if len(svc.identities) != 0 {
+		t.Fatal("forged state signed in")
+	}
+
+	rec = callback(state)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback: status %d: %s", rec.Code, rec.Body)
+	}
+	if len(svc.identities) != 1 || svc.identities[0].Claims["preferred_username"] != "ann" {
+		t.Fatalf("identities = %v", svc.identities)
+	}
+
+	// The code is single-use.
+	if rec := callback(state); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed callback: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/tenant"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = `k.id, k.user_id, u.tenant_id, k.name, k.prefix, k.key_hash, k.scopes, k.expires_at, k.last_used_at, k.created_at, k.revoked_at`
+
+type apiKeyRepository struct {
+	db *commonpg.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) auth.APIKeyRepository {
+	return &apiKeyRepository{db: commonpg.Wrap(db)}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, k *auth.APIKey) error {
+	query := `INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.ExecContext(ctx, query, k.ID, k.UserID, k.Name, k.Prefix, k.Hash, pq.Array(k.Scopes), k.ExpiresAt, k.CreatedAt)
+	return err
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (*auth.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.key_hash = $1`
+	k, err := scanAPIKey(r.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, auth.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return k, nil
+}
+
+func (r *apiKeyRepository) ListByUser(ctx context.Context, userID string) ([]*auth.APIKey, error) {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.user_id = $1 AND u.tenant_id = $2 ORDER BY k.created_at`
+	rows, err := r.db.QueryContext(ctx, query, userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*auth.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id string) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE api_keys SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)`
+	res, err := r.db.ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return auth.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = $1 WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, at, id)
+	return err
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*auth.APIKey, error) {
+	var k auth.APIKey
+	err := row.Scan(&k.ID, &k.UserID, &k.TenantID, &k.Name, &k.Prefix, &k.Hash, pq.Array(&k.Scopes), &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt, &k.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/tenant"
+)
+
+type identityRepository struct {
+	db *commonpg.DB
+}
+
+func NewIdentityRepository(db *sql.DB) auth.IdentityRepository {
+	return &identityRepository{db: commonpg.Wrap(db)}
+}
+
+func (r *identityRepository) GetUserID(ctx context.Context, issuer, subject string) (string, bool, error) {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	query := `SELECT user_id FROM user_identities WHERE tenant_id = $1 AND issuer = $2 AND subject = $3`
+	var userID string
+	err = r.db.QueryRowContext(ctx, query, tenantID, issuer, subject).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return userID, true, nil
+}
+
+func (r *identityRepository) Link(ctx context.Context, userID, issuer, subject string) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO user_identities (tenant_id, user_id, issuer, subject) VALUES ($1, $2, $3, $4)`
+	_, err = r.db.ExecContext(ctx, query, tenantID, userID, issuer, subject)
+	return err
+}
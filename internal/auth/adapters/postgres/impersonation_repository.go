@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/tenant"
+)
+
+type impersonationRepository struct {
+	db *commonpg.DB
+}
+
+func NewImpersonationRepository(db *sql.DB) auth.ImpersonationRepository {
+	return &impersonationRepository{db: commonpg.Wrap(db)}
+}
+
+func (r *impersonationRepository) Create(ctx context.Context, imp *auth.Impersonation) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO impersonations (id, tenant_id, actor_id, subject_id, reason, started_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = r.db.ExecContext(ctx, query, imp.ID, tenantID, imp.ActorID, imp.SubjectID, imp.Reason, imp.StartedAt, imp.ExpiresAt)
+	return err
+}
+
+func (r *impersonationRepository) Get(ctx context.Context, id string) (*auth.Impersonation, error) {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, actor_id, subject_id, reason, started_at, expires_at, ended_at FROM impersonations WHERE id = $1 AND tenant_id = $2`
+	var imp auth.Impersonation
+	err = r.db.QueryRowContext(ctx, query, id, tenantID).Scan(&imp.ID, &imp.ActorID, &imp.SubjectID, &imp.Reason, &imp.StartedAt, &imp.ExpiresAt, &imp.EndedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, auth.ErrImpersonationNotFound
+		}
+		return nil, err
+	}
+	return &imp, nil
+}
+
+func (r *impersonationRepository) End(ctx context.Context, id string) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE impersonations SET ended_at = NOW() WHERE id = $1 AND tenant_id = $2 AND ended_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return auth.ErrImpersonationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"time"
+)
+
+type loginAttemptRepository struct {
+	db *commonpg.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) auth.LoginAttemptRepository {
+	return &loginAttemptRepository{db: commonpg.Wrap(db)}
+}
+
+func (r *loginAttemptRepository) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	query := `SELECT locked_until FROM login_attempts WHERE key = $1`
+	var until sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, key).Scan(&until)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return until.Time, nil
+}
+
+func (r *loginAttemptRepository) RecordFailure(ctx context.Context, key string, resetAfter time.Duration) (int, error) {
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < NOW() - make_interval(secs => $2) THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = NOW()
+		RETURNING failures`
+	var failures int
+	err := r.db.QueryRowContext(ctx, query, key, resetAfter.Seconds()).Scan(&failures)
+	return failures, err
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `UPDATE login_attempts SET locked_until = GREATEST(COALESCE(locked_until, $1), $1) WHERE key = $2`
+	_, err := r.db.ExecContext(ctx, query, until, key)
+	return err
+}
+
+func (r *loginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
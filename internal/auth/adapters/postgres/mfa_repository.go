@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+)
+
+type mfaRepository struct {
+	db *commonpg.DB
+}
+
+func NewMFARepository(db *sql.DB) auth.MFARepository {
+	return &mfaRepository{db: commonpg.Wrap(db)}
+}
+
+func (r *mfaRepository) Get(ctx context.Context, userID string) (*auth.MFAEnrollment, error) {
+	query := `SELECT user_id, secret, enabled, last_used_step, created_at FROM user_mfa WHERE user_id = $1`
+	var e auth.MFAEnrollment
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&e.UserID, &e.Secret, &e.Enabled, &e.LastUsedStep, &e.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, auth.ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *mfaRepository) Save(ctx context.Context, e *auth.MFAEnrollment) error {
+	query := `INSERT INTO user_mfa (user_id, secret, enabled, last_used_step, created_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, enabled = EXCLUDED.enabled, last_used_step = GREATEST(user_mfa.last_used_step, EXCLUDED.last_used_step), created_at = EXCLUDED.created_at`
+	_, err := r.db.ExecContext(ctx, query, e.UserID, e.Secret, e.Enabled, e.LastUsedStep, e.CreatedAt)
+	return err
+}
+
+func (r *mfaRepository) Delete(ctx context.Context, userID string) error {
+	return r.db.InTx(ctx, func(ctx context.Context) error {
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := r.db.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+func (r *mfaRepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	query := `UPDATE user_mfa SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`
+	res, err := r.db.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error {
+	return r.db.InTx(ctx, func(ctx context.Context) error {
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		for _, h := range hashes {
+			if _, err := r.db.ExecContext(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, h); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+	query := `UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, userID, hash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+func (r *mfaRepository) CreateChallenge(ctx context.Context, c *auth.LoginChallenge) error {
+	query := `INSERT INTO login_challenges (id, user_id, challenge_hash, expires_at) VALUES ($1, $2, $3, $4)`
+	_, err := r.db.ExecContext(ctx, query, c.ID, c.UserID, c.Hash, c.ExpiresAt)
+	return err
+}
+
+func (r *mfaRepository) GetChallenge(ctx context.Context, hash string) (*auth.LoginChallenge, error) {
+	query := `SELECT c.id, c.user_id, u.tenant_id, c.challenge_hash, c.expires_at, c.attempts
+		FROM login_challenges c JOIN users u ON u.id = c.user_id WHERE c.challenge_hash = $1`
+	var c auth.LoginChallenge
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(&c.ID, &c.UserID, &c.TenantID, &c.Hash, &c.ExpiresAt, &c.Attempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("login challenge not found")
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *mfaRepository) IncrementChallengeAttempts(ctx context.Context, id string) (int, error) {
+	query := `UPDATE login_challenges SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts`
+	var attempts int
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&attempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("login challenge not found")
+		}
+		return 0, err
+	}
+	return attempts, nil
+}
+
+func (r *mfaRepository) DeleteChallenge(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM login_challenges WHERE id = $1`, id)
+	return err
+}
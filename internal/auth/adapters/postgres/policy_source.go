@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+)
+
+type policySource struct {
+	db *commonpg.DB
+}
+
+func NewPolicySource(db *sql.DB) auth.PolicySource {
+	return &policySource{db: commonpg.Wrap(db)}
+}
+
+func (r *policySource) LoadPolicies(ctx context.Context) (auth.PolicySet, error) {
+	set := auth.PolicySet{Roles: map[string][]string{}}
+
+	roleRows, err := r.db.QueryContext(ctx, `SELECT role, inherits FROM role_inheritance`)
+	if err != nil {
+		return auth.PolicySet{}, err
+	}
+	defer roleRows.Close()
+	for roleRows.Next() {
+		var role, inherits string
+		if err := roleRows.Scan(&role, &inherits); err != nil {
+			return auth.PolicySet{}, err
+		}
+		set.Roles[role] = append(set.Roles[role], inherits)
+	}
+	if err := roleRows.Err(); err != nil {
+		return auth.PolicySet{}, err
+	}
+
+	query := `SELECT id, subject_role, action, resource_type, condition, effect, description FROM policies ORDER BY priority, created_at`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return auth.PolicySet{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p auth.Policy
+		if err := rows.Scan(&p.ID, &p.SubjectRole, &p.Action, &p.ResourceType, &p.Condition, &p.Effect, &p.Description); err != nil {
+			return auth.PolicySet{}, err
+		}
+		set.Policies = append(set.Policies, p)
+	}
+	return set, rows.Err()
+}
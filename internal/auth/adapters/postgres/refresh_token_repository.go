@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+)
+
+type refreshTokenRepository struct {
+	db *commonpg.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) auth.RefreshTokenRepository {
+	return &refreshTokenRepository{db: commonpg.Wrap(db)}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, t *auth.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at, mfa) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.ExecContext(ctx, query, t.ID, t.UserID, t.FamilyID, t.TokenHash, t.ExpiresAt, t.CreatedAt, t.MFA)
+	return err
+}
+
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, hash string) (*auth.RefreshToken, error) {
+	query := `SELECT t.id, t.user_id, u.tenant_id, t.family_id, t.token_hash, t.expires_at, t.created_at, t.revoked_at, t.replaced_by, t.mfa
+		FROM refresh_tokens t JOIN users u ON u.id = t.user_id WHERE t.token_hash = $1`
+	var t auth.RefreshToken
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(&t.ID, &t.UserID, &t.TenantID, &t.FamilyID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &t.RevokedAt, &t.ReplacedBy, &t.MFA)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("refresh token not found")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *refreshTokenRepository) MarkRotated(ctx context.Context, id, replacedBy string) (bool, error) {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $1 WHERE id = $2 AND revoked_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, replacedBy, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, familyID)
+	return err
+}
+
+func (r *refreshTokenRepository) RevokeUser(ctx context.Context, userID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
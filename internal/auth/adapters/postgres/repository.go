@@ -4,75 +4,97 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/tenant"
 )
 
 type repository struct {
-	db *sql.DB
+	db *commonpg.DB
 }
 
 func NewRepository(db *sql.DB) auth.UserRepository {
-	return &repository{db: db}
+	return &repository{db: commonpg.Wrap(db)}
 }
 
+const userColumns = `id, tenant_id, username, password_hash, role, attributes, service_account, COALESCE(email, ''), email_verified, disabled, created_at, updated_at`
+
 func (r *repository) GetByUsername(ctx context.Context, username string) (*auth.User, error) {
-	query := `SELECT id, username, password_hash, role, attributes FROM users WHERE username = $1`
-	var user auth.User
-	var attrJSON []byte
-	err := r.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &attrJSON)
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1 AND tenant_id = $2 AND deleted_at IS NULL`
+	return r.get(ctx, query, username)
+}
+
+func (r *repository) GetByEmail(ctx context.Context, email string) (*auth.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE lower(email) = lower($1) AND tenant_id = $2 AND deleted_at IS NULL`
+	return r.get(ctx, query, email)
+}
+
+func (r *repository) GetByID(ctx context.Context, id string) (*auth.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL`
+	return r.get(ctx, query, id)
+}
+
+func (r *repository) get(ctx context.Context, query string, arg interface{}) (*auth.User, error) {
+	tenantID, err := tenant.ID(ctx)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
 		return nil, err
 	}
 
-	if err := json.Unmarshal(attrJSON, &user.Attributes); err != nil {
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, arg, tenantID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, auth.ErrUserNotFound
+		}
 		return nil, err
 	}
-
-	return &user, nil
+	return user, nil
 }
 
-func (r *repository) GetByID(ctx context.Context, id string) (*auth.User, error) {
-	query := `SELECT id, username, password_hash, role, attributes FROM users WHERE id = $1`
-	var user auth.User
-	var attrJSON []byte
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &attrJSON)
+func (r *repository) Create(ctx context.Context, user *auth.User) error {
+	tenantID, err := tenant.ID(ctx)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, err
+		return err
 	}
+	user.TenantID = tenantID
 
-	if err := json.Unmarshal(attrJSON, &user.Attributes); err != nil {
-		return nil, err
+	attrJSON, err := json.Marshal(user.Attributes)
+	if err != nil {
+		return err
 	}
 
-	return &user, nil
+	query := `INSERT INTO users (id, tenant_id, username, password_hash, role, attributes, service_account, email, email_verified, disabled) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10) RETURNING created_at, updated_at`
+	return r.db.QueryRowContext(ctx, query, user.ID, user.TenantID, user.Username, user.PasswordHash, user.Role, attrJSON, user.ServiceAccount, user.Email, user.EmailVerified, user.Disabled).Scan(&user.CreatedAt, &user.UpdatedAt)
 }
 
-func (r *repository) Create(ctx context.Context, user *auth.User) error {
+func (r *repository) Update(ctx context.Context, user *auth.User) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
 	attrJSON, err := json.Marshal(user.Attributes)
 	if err != nil {
 		return err
 	}
 
-	query := `INSERT INTO users (id, username, password_hash, role, attributes) VALUES ($1, $2, $3, $4, $5)`
-	_, err = r.db.ExecContext(ctx, query, user.ID, user.Username, user.PasswordHash, user.Role, attrJSON)
+	query := `UPDATE users SET username = $1, password_hash = $2, role = $3, attributes = $4, service_account = $5, email = NULLIF($6, ''), email_verified = $7, disabled = $8, updated_at = NOW() WHERE id = $9 AND tenant_id = $10 AND deleted_at IS NULL RETURNING updated_at`
+	err = r.db.QueryRowContext(ctx, query, user.Username, user.PasswordHash, user.Role, attrJSON, user.ServiceAccount, user.Email, user.EmailVerified, user.Disabled, user.ID, tenantID).Scan(&user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return auth.ErrUserNotFound
+	}
 	return err
 }
 
-func (r *repository) Update(ctx context.Context, user *auth.User) error {
-	attrJSON, err := json.Marshal(user.Attributes)
+// Delete soft-deletes a user. The row is kept so that audit references stay
+// valid, but the user can no longer be found or sign in.
+func (r *repository) Delete(ctx context.Context, id string) error {
+	tenantID, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
 
-	query := `UPDATE users SET username = $1, password_hash = $2, role = $3, attributes = $4 WHERE id = $5`
-	res, err := r.db.ExecContext(ctx, query, user.Username, user.PasswordHash, user.Role, attrJSON, user.ID)
+	query := `UPDATE users SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, id, tenantID)
 	if err != nil {
 		return err
 	}
@@ -82,14 +104,19 @@ func (r *repository) Update(ctx context.Context, user *auth.User) error {
 		return err
 	}
 	if rows == 0 {
-		return fmt.Errorf("user not found")
+		return auth.ErrUserNotFound
 	}
 	return nil
 }
 
 func (r *repository) List(ctx context.Context) ([]*auth.User, error) {
-	query := `SELECT id, username, password_hash, role, attributes FROM users`
-	rows, err := r.db.QueryContext(ctx, query)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE tenant_id = $1 AND deleted_at IS NULL ORDER BY username`
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -97,15 +124,26 @@ func (r *repository) List(ctx context.Context) ([]*auth.User, error) {
 
 	var users []*auth.User
 	for rows.Next() {
-		var user auth.User
-		var attrJSON []byte
-		if err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &attrJSON); err != nil {
-			return nil, err
-		}
-		if err := json.Unmarshal(attrJSON, &user.Attributes); err != nil {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 	return users, nil
 }
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*auth.User, error) {
+	var user auth.User
+	var attrJSON []byte
+	var createdAt, updatedAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.TenantID, &user.Username, &user.PasswordHash, &user.Role, &attrJSON, &user.ServiceAccount, &user.Email, &user.EmailVerified, &user.Disabled, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	user.CreatedAt = createdAt.Time
+	user.UpdatedAt = updatedAt.Time
+	if err := json.Unmarshal(attrJSON, &user.Attributes); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
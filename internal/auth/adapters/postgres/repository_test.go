@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/adapters/postgres/pgtest"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRepositoryTenantIsolation(t *testing.T) {
+	repo := NewRepository(pgtest.Open(t))
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	u := &auth.User{ID: uuid.NewString(), Username: "ann", PasswordHash: "hash", Role: "user", Email: "ann@example.com"}
+	if err := repo.Create(ctxA, u); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := repo.GetByID(ctxB, u.ID); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("GetByID in other tenant: %v, want ErrUserNotFound", err)
+	}
+	if _, err := repo.GetByUsername(ctxB, "ann"); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("GetByUsername in other tenant: %v, want ErrUserNotFound", err)
+	}
+	if _, err := repo.GetByEmail(ctxB, "ann@example.com"); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("GetByEmail in other tenant: %v, want ErrUserNotFound", err)
+	}
+	list, err := repo.List(ctxB)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("other tenant lists %d users, want none", len(list))
+	}
+	changed := *u
+	changed.Role = "admin"
+	if err := repo.Update(ctxB, &changed); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("Update in other tenant: %v, want ErrUserNotFound", err)
+	}
+	if err := repo.Delete(ctxB, u.ID); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("Delete in other tenant: %v, want ErrUserNotFound", err)
+	}
+
+	got, err := repo.GetByID(ctxA, u.ID)
+	if err != nil {
+		t.Fatalf("GetByID in own tenant: %v", err)
+	}
+	if got.Role != "user" {
+		t.Fatalf("user changed by other tenant: %+v", got)
+	}
+	if list, err := repo.List(ctxA); err != nil || len(list) != 1 {
+		t.Fatalf("own tenant lists %d users (%v), want 1", len(list), err)
+	}
+}
+
+func TestImpersonationNotFound(t *testing.T) {
+	repo := NewImpersonationRepository(pgtest.Open(t))
+	ctx := pgtest.Tenant()
+
+	if _, err := repo.Get(ctx, uuid.NewString()); !errors.Is(err, auth.ErrImpersonationNotFound) {
+		t.Fatalf("Get: %v, want ErrImpersonationNotFound", err)
+	}
+	if err := repo.End(ctx, uuid.NewString()); !errors.Is(err, auth.ErrImpersonationNotFound) {
+		t.Fatalf("End: %v, want ErrImpersonationNotFound", err)
+	}
+}
+
+func TestServiceTenantIsolation(t *testing.T) {
+	db := pgtest.Open(t)
+	ctx := context.Background()
+	keys, err := auth.NewKeyManager(ctx, NewSigningKeyRepository(db), auth.KeyManagerConfig{})
+	if err != nil {
+		t.Fatalf("NewKeyManager: %v", err)
+	}
+	policies, err := auth.NewPolicyEngine(ctx, auth.NewStaticPolicySource(auth.DefaultPolicySet()), 0)
+	if err != nil {
+		t.Fatalf("NewPolicyEngine: %v", err)
+	}
+	svc := auth.NewService(auth.ServiceConfig{
+		Keys:          keys,
+		Policies:      policies,
+		Users:         NewRepository(db),
+		Hasher:        auth.NewBcryptHasher(bcrypt.MinCost),
+		RefreshTokens: NewRefreshTokenRepository(db),
+		MFA:           NewMFARepository(db),
+		LoginAttempts: NewLoginAttemptRepository(db),
+		UnitOfWork:    commonpg.NewTxManager(db, commonpg.TxConfig{}),
+	})
+	admin := auth.Subject{ID: uuid.NewString(), Role: "admin", MFA: true}
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	u := &auth.User{Username: "ann", Password: "secret", Role: "user"}
+	if err := svc.CreateUser(ctxA, admin, u); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	if _, err := svc.GetUser(ctxB, admin, u.ID); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("GetUser in other tenant: %v, want ErrUserNotFound", err)
+	}
+	list, err := svc.ListUsers(ctxB, admin)
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("other tenant lists %d users, want none", len(list))
+	}
+	changed := &auth.User{ID: u.ID, Username: "ann", Role: "admin"}
+	if err := svc.UpdateUser(ctxB, admin, changed); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("UpdateUser in other tenant: %v, want ErrUserNotFound", err)
+	}
+	if err := svc.SetUserDisabled(ctxB, admin, u.ID, true); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("SetUserDisabled in other tenant: %v, want ErrUserNotFound", err)
+	}
+	if err := svc.DeleteUser(ctxB, admin, u.ID); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("DeleteUser in other tenant: %v, want ErrUserNotFound", err)
+	}
+
+	got, err := svc.GetUser(ctxA, admin, u.ID)
+	if err != nil {
+		t.Fatalf("GetUser in own tenant: %v", err)
+	}
+	if got.Role != "user" || got.Disabled {
+		t.Fatalf("user changed by other tenant: %+v", got)
+	}
+	if _, err := svc.Login(ctxA, "ann", "secret"); err != nil {
+		t.Fatalf("Login in own tenant: %v", err)
+	}
+	if _, err := svc.Login(ctxB, "ann", "secret"); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Login in other tenant: %v, want ErrInvalidCredentials", err)
+	}
+}
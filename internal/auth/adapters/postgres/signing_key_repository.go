@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"fmt"
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+)
+
+type signingKeyRepository struct {
+	db *commonpg.DB
+}
+
+func NewSigningKeyRepository(db *sql.DB) auth.SigningKeyRepository {
+	return &signingKeyRepository{db: commonpg.Wrap(db)}
+}
+
+func (r *signingKeyRepository) ListActive(ctx context.Context) ([]*auth.SigningKey, error) {
+	query := `SELECT id, algorithm, private_key, created_at, expires_at FROM signing_keys WHERE expires_at > NOW()`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*auth.SigningKey
+	for rows.Next() {
+		var k auth.SigningKey
+		var privatePEM string
+		if err := rows.Scan(&k.ID, &k.Algorithm, &privatePEM, &k.CreatedAt, &k.ExpiresAt); err != nil {
+			return nil, err
+		}
+		signer, err := decodePrivateKey(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", k.ID, err)
+		}
+		k.PrivateKey = signer
+		keys = append(keys, &k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *signingKeyRepository) Create(ctx context.Context, k *auth.SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return err
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	query := `INSERT INTO signing_keys (id, algorithm, private_key, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err = r.db.ExecContext(ctx, query, k.ID, k.Algorithm, string(privatePEM), k.CreatedAt, k.ExpiresAt)
+	return err
+}
+
+func decodePrivateKey(privatePEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
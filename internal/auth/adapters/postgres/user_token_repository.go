@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+)
+
+type userTokenRepository struct {
+	db *commonpg.DB
+}
+
+func NewUserTokenRepository(db *sql.DB) auth.UserTokenRepository {
+	return &userTokenRepository{db: commonpg.Wrap(db)}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, t *auth.UserToken) error {
+	query := `INSERT INTO user_tokens (id, user_id, purpose, token_hash, email, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.ExecContext(ctx, query, t.ID, t.UserID, t.Purpose, t.Hash, t.Email, t.ExpiresAt, t.CreatedAt)
+	return err
+}
+
+func (r *userTokenRepository) Consume(ctx context.Context, hash string, purpose auth.UserTokenPurpose) (*auth.UserToken, error) {
+	query := `UPDATE user_tokens t SET used_at = NOW()
+		FROM users u
+		WHERE u.id = t.user_id AND t.token_hash = $1 AND t.purpose = $2 AND t.used_at IS NULL AND t.expires_at > NOW()
+		RETURNING t.id, t.user_id, u.tenant_id, t.purpose, t.token_hash, t.email, t.expires_at, t.created_at`
+	var t auth.UserToken
+	err := r.db.QueryRowContext(ctx, query, hash, purpose).Scan(&t.ID, &t.UserID, &t.TenantID, &t.Purpose, &t.Hash, &t.Email, &t.ExpiresAt, &t.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("token not found")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *userTokenRepository) InvalidateUser(ctx context.Context, userID string, purpose auth.UserTokenPurpose) error {
+	query := `UPDATE user_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, userID, purpose)
+	return err
+}
@@ -0,0 +1,63 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hex-postgres-grpc/internal/auth"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+type Config struct {
+	// Addr is host:port of the SMTP server. STARTTLS is used when the server
+	// offers it.
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Mailer sends email through an SMTP server.
+type Mailer struct {
+	cfg Config
+}
+
+func NewMailer(cfg Config) *Mailer {
+	return &Mailer{cfg: cfg}
+}
+
+func (m *Mailer) Send(ctx context.Context, msg auth.Message) error {
+	var smtpAuth smtp.Auth
+	if m.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(m.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		smtpAuth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, host)
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(msg.Body)
+
+	// net/smtp does not take a context; run the send so that a cancelled
+	// request does not wait for a slow server.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.cfg.Addr, smtpAuth, m.cfg.From, []string{msg.To}, body.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"hex-postgres-grpc/internal/common/apperr"
+	"hex-postgres-grpc/internal/common/tenant"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key so that leaked keys are easy to spot.
+const APIKeyPrefix = "hpg_"
+
+// apiKeyTouchInterval limits how often LastUsedAt is written for a busy key.
+const apiKeyTouchInterval = time.Minute
+
+var (
+	ErrInvalidAPIKey      = apperr.New(apperr.Unauthenticated, "INVALID_API_KEY", "invalid api key")
+	ErrAPIKeyNotFound     = apperr.New(apperr.NotFound, "API_KEY_NOT_FOUND", "api key not found")
+	ErrNotServiceAccount  = apperr.New(apperr.FailedPrecondition, "NOT_SERVICE_ACCOUNT", "api keys can only be issued to service accounts")
+	ErrScopesRequired     = apperr.NewField("scopes", "SCOPES_REQUIRED", "at least one scope is required")
+	ErrInvalidScope       = apperr.NewField("scopes", "INVALID_SCOPE", "scope must have the form <resource_type>:<action>")
+	ErrServiceAccountAuth = apperr.New(apperr.InvalidArgument, "SERVICE_ACCOUNT_AUTH", "service accounts authenticate with api keys")
+)
+
+func (s *service) ValidateAPIKey(ctx context.Context, key string) (Subject, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return Subject{}, ErrInvalidAPIKey
+	}
+
+	k, err := s.apiKeys.GetByHash(ctx, hashToken(key))
+	if err != nil {
+		return Subject{}, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if k.RevokedAt != nil || (k.ExpiresAt != nil && now.After(*k.ExpiresAt)) {
+		return Subject{}, ErrInvalidAPIKey
+	}
+
+	// The key determines the tenant, whatever the request asked for.
+	ctx = tenant.WithID(ctx, k.TenantID)
+	u, err := s.repo.GetByID(ctx, k.UserID)
+	if err != nil || u.Disabled {
+		return Subject{}, ErrInvalidAPIKey
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeys.TouchLastUsed(ctx, k.ID, now); err != nil {
+			log.Printf("auth: record use of api key %s: %v", k.ID, err)
+		}
+	}
+
+	return Subject{
+		ID:         u.ID,
+		TenantID:   u.TenantID,
+		Username:   u.Username,
+		Role:       u.Role,
+		Attributes: u.Attributes,
+		APIKeyID:   k.ID,
+		Scopes:     k.Scopes,
+	}, nil
+}
+
+// IssueAPIKey stores a new key for key.UserID, which must be a service
+// account, and returns the key. It cannot be retrieved again later.
+func (s *service) IssueAPIKey(ctx context.Context, sub Subject, key *APIKey) (string, error) {
+	authorized, err := s.Authorize(ctx, sub, ActionCreate, Resource{Type: "api_key"})
+	if err != nil {
+		return "", err
+	}
+	if !authorized {
+		return "", ErrUnauthorized
+	}
+
+	if len(key.Scopes) == 0 {
+		return "", ErrScopesRequired
+	}
+	for _, scope := range key.Scopes {
+		if err := validateScope(scope); err != nil {
+			return "", err
+		}
+	}
+
+	u, err := s.repo.GetByID(ctx, key.UserID)
+	if err != nil {
+		return "", err
+	}
+	if !u.ServiceAccount {
+		return "", ErrNotServiceAccount
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	raw := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key.ID = uuid.NewString()
+	key.Prefix = raw[:len(APIKeyPrefix)+8]
+	key.Hash = hashToken(raw)
+	key.CreatedAt = time.Now()
+	key.LastUsedAt = nil
+	key.RevokedAt = nil
+
+	if err := s.apiKeys.Create(ctx, key); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (s *service) ListAPIKeys(ctx context.Context, sub Subject, userID string) ([]*APIKey, error) {
+	authorized, err := s.Authorize(ctx, sub, ActionRead, Resource{Type: "api_key"})
+	if err != nil {
+		return nil, err
+	}
+	if !authorized {
+		return nil, ErrUnauthorized
+	}
+
+	return s.apiKeys.ListByUser(ctx, userID)
+}
+
+func (s *service) RevokeAPIKey(ctx context.Context, sub Subject, id string) error {
+	authorized, err := s.Authorize(ctx, sub, ActionDelete, Resource{Type: "api_key", ID: id})
+	if err != nil {
+		return err
+	}
+	if !authorized {
+		return ErrUnauthorized
+	}
+
+	return s.apiKeys.Revoke(ctx, id)
+}
+
+// validateScope checks that scope has the form <resource_type>:<action>,
+// where either part may be "*".
+func validateScope(scope string) error {
+	resType, act, ok := strings.Cut(scope, ":")
+	if !ok || resType == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+	}
+	switch Action(act) {
+	case "*", ActionCreate, ActionRead, ActionUpdate, ActionDelete:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+}
+
+func scopeAllows(scopes []string, act Action, resourceType string) bool {
+	for _, scope := range scopes {
+		resType, a, _ := strings.Cut(scope, ":")
+		if (resType == "*" || resType == resourceType) && (a == "*" || Action(a) == act) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"google.golang.org/grpc"
 )
@@ -18,53 +19,399 @@ const (
 
 type Subject struct {
 	ID         string
+	TenantID   string
 	Username   string
 	Role       string
 	Attributes map[string]interface{}
+	// APIKeyID and Scopes are set when the caller authenticated with an API
+	// key. Such a caller may only act within the key's scopes.
+	APIKeyID string
+	Scopes   []string
+	// MFA reports whether the session was established with a second factor.
+	MFA bool
+	// ActorID is the administrator acting as this subject while
+	// impersonating it, in the session ImpersonationID. Both are empty
+	// otherwise.
+	ActorID         string
+	ImpersonationID string
+}
+
+// Actor returns ActorID for recording as the author of a write, or nil when
+// the subject is not impersonated.
+func (s Subject) Actor() *string {
+	if s.ActorID == "" {
+		return nil
+	}
+	id := s.ActorID
+	return &id
 }
 
 type User struct {
-	ID           string
+	ID string
+	// TenantID is set by the repository from the request context. Usernames
+	// and email addresses are unique within a tenant.
+	TenantID     string
 	Username     string
+	Password     string // plaintext input only, never persisted
 	PasswordHash string
 	Role         string
 	Attributes   map[string]interface{}
+	// ServiceAccount users have no password and authenticate with API keys.
+	ServiceAccount bool
+	// Email is optional. EmailVerified is reset whenever the address changes.
+	Email         string
+	EmailVerified bool
+	// Disabled users keep their data but cannot sign in, and their existing
+	// sessions and API keys stop working.
+	Disabled  bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ProfileUpdate is a change users make to their own account. Nil fields are
+// left unchanged; changing the password requires the current one.
+type ProfileUpdate struct {
+	Email           *string
+	Password        *string
+	CurrentPassword string
 }
 
 type UserRepository interface {
 	GetByUsername(ctx context.Context, username string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
 	GetByID(ctx context.Context, id string) (*User, error)
 	Create(ctx context.Context, user *User) error
 	Update(ctx context.Context, user *User) error
+	// Delete soft-deletes the user; deleted users are not returned by any
+	// other method.
+	Delete(ctx context.Context, id string) error
 	List(ctx context.Context) ([]*User, error)
 }
 
+// TokenPair is returned on login and refresh. The access token is a short-lived
+// JWT; the refresh token is an opaque, single-use value stored hashed.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// RefreshToken belongs to a family that starts at login. Every refresh rotates
+// the token within its family; presenting an already rotated token revokes the
+// whole family.
+type RefreshToken struct {
+	ID     string
+	UserID string
+	// TenantID is the tenant of the user, read along with the token.
+	TenantID   string
+	FamilyID   string
+	TokenHash  string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *string
+	// MFA is carried over to every token rotated from the same login.
+	MFA bool
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	// MarkRotated revokes the token and records its replacement. It reports
+	// false when the token had already been revoked by a concurrent caller.
+	MarkRotated(ctx context.Context, id, replacedBy string) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeUser revokes every refresh token of the user.
+	RevokeUser(ctx context.Context, userID string) error
+}
+
+// APIKey authenticates a service account. Only the SHA-256 hash of the key is
+// stored; the key itself is shown once, when it is issued.
+type APIKey struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	// TenantID is the tenant of the service account, read along with the key.
+	TenantID string   `json:"-"`
+	Name     string   `json:"name"`
+	Prefix   string   `json:"prefix"` // leading characters of the key, to recognise it in listings
+	Hash     string   `json:"-"`
+	Scopes   []string `json:"scopes"`
+	// ExpiresAt is optional; a nil value means the key does not expire.
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	GetByHash(ctx context.Context, hash string) (*APIKey, error)
+	ListByUser(ctx context.Context, userID string) ([]*APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	TouchLastUsed(ctx context.Context, id string, at time.Time) error
+}
+
+// MFAEnrollment holds a user's TOTP secret. It becomes effective once the user
+// has confirmed it with a valid code.
+type MFAEnrollment struct {
+	UserID  string
+	Secret  string
+	Enabled bool
+	// LastUsedStep is the TOTP time step of the last accepted code; codes
+	// from that step or earlier are rejected to prevent replay.
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// LoginChallenge is the state between the password step and the second
+// factor of a login. Only the hash of the challenge token is stored.
+type LoginChallenge struct {
+	ID        string
+	UserID    string
+	TenantID  string
+	Hash      string
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+type MFARepository interface {
+	// Get returns ErrMFANotEnrolled when the user has no enrollment.
+	Get(ctx context.Context, userID string) (*MFAEnrollment, error)
+	Save(ctx context.Context, enrollment *MFAEnrollment) error
+	// Delete removes the enrollment and the user's recovery codes.
+	Delete(ctx context.Context, userID string) error
+	// UseStep records step as used. It reports false if step is not newer
+	// than the last used step.
+	UseStep(ctx context.Context, userID string, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error
+	// UseRecoveryCode consumes an unused recovery code. It reports false if
+	// there was none with that hash.
+	UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error)
+
+	CreateChallenge(ctx context.Context, challenge *LoginChallenge) error
+	GetChallenge(ctx context.Context, hash string) (*LoginChallenge, error)
+	// IncrementChallengeAttempts returns the attempt count after the increment.
+	IncrementChallengeAttempts(ctx context.Context, id string) (int, error)
+	DeleteChallenge(ctx context.Context, id string) error
+}
+
+// LoginResult carries either tokens or, when the user has MFA enabled, the
+// challenge to complete with a second factor.
+type LoginResult struct {
+	Tokens    *TokenPair
+	Challenge *MFAChallenge
+}
+
+type MFAChallenge struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int64  `json:"expires_in"`
+}
+
+// MFASetup is returned when enrollment starts. The secret is shown once so
+// that it can be added to an authenticator app.
+type MFASetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type UserTokenPurpose string
+
+const (
+	PurposePasswordReset     UserTokenPurpose = "password_reset"
+	PurposeEmailVerification UserTokenPurpose = "email_verification"
+)
+
+// UserToken is a single-use, time-limited token sent to a user by email. Only
+// its hash is stored.
+type UserToken struct {
+	ID       string
+	UserID   string
+	TenantID string
+	Purpose  UserTokenPurpose
+	Hash     string
+	// Email is the address the token was sent to. A verification token only
+	// verifies that address.
+	Email     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *UserToken) error
+	// Consume marks the unused, unexpired token with the given hash and
+	// purpose as used and returns it. It fails if there is none.
+	Consume(ctx context.Context, hash string, purpose UserTokenPurpose) (*UserToken, error)
+	// InvalidateUser marks every unused token of the user for purpose as used.
+	InvalidateUser(ctx context.Context, userID string, purpose UserTokenPurpose) error
+}
+
+// Impersonation is a time-boxed session in which an administrator acts as
+// another user. It ends at ExpiresAt or when it is ended explicitly.
+type Impersonation struct {
+	ID        string     `json:"id"`
+	ActorID   string     `json:"actor_id"`
+	SubjectID string     `json:"subject_id"`
+	Reason    string     `json:"reason"`
+	StartedAt time.Time  `json:"started_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+type ImpersonationRepository interface {
+	Create(ctx context.Context, imp *Impersonation) error
+	// Get returns ErrImpersonationNotFound if there is no session with that
+	// ID.
+	Get(ctx context.Context, id string) (*Impersonation, error)
+	// End records the end of a session that has not ended yet. It returns
+	// ErrImpersonationNotFound if there is none with that ID.
+	End(ctx context.Context, id string) error
+}
+
+// ImpersonationToken is an access token for an impersonation session. There
+// is no refresh token; a new session has to be started once it expires.
+type ImpersonationToken struct {
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	ImpersonationID string `json:"impersonation_id"`
+}
+
+// Message is an email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email on behalf of the auth service.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
 type Resource struct {
 	Type       string
 	ID         string
 	Attributes map[string]interface{}
 }
 
+type Effect string
+
+const (
+	EffectAllow Effect = "allow"
+	EffectDeny  Effect = "deny"
+)
+
 type Policy struct {
-	SubjectRole  string
+	// ID names the policy in decision explanations.
+	ID           string `json:"id,omitempty" yaml:"id,omitempty"`
+	SubjectRole  string `json:"subject_role" yaml:"subject_role"`
+	Action       Action `json:"action" yaml:"action"` // "*" matches every action
+	ResourceType string `json:"resource_type" yaml:"resource_type"`
+	// Condition is an expression over subject, resource and action attributes
+	// that must hold for the policy to apply, e.g. "resource.owner_id == subject.id".
+	// This is where the "Attribute" part of ABAC comes in. Empty means always.
+	Condition string `json:"condition,omitempty" yaml:"condition,omitempty"`
+	// Effect defaults to allow. A matching deny always wins over any allow.
+	Effect      Effect `json:"effect,omitempty" yaml:"effect,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// PolicySet is everything the policy engine evaluates. Roles maps a role to
+// the roles it inherits policies from, e.g. {"admin": ["user"]}.
+type PolicySet struct {
+	Roles    map[string][]string `json:"roles" yaml:"roles"`
+	Policies []Policy            `json:"policies" yaml:"policies"`
+}
+
+// PolicySource loads the current policy set, e.g. from a file or a table.
+type PolicySource interface {
+	LoadPolicies(ctx context.Context) (PolicySet, error)
+}
+
+// MethodPermission is the permission a gRPC method requires. Public methods
+// are served without a token; every other method needs an authenticated
+// subject that is allowed Action on ResourceType. Methods without an Action
+// only need an authenticated subject.
+type MethodPermission struct {
+	Public       bool
 	Action       Action
 	ResourceType string
-	// Condition defines a function that must be true for the policy to apply
-	// This is where the "Attribute" part of ABAC comes in.
-	Condition func(sub Subject, res Resource) bool
+}
+
+// MethodPermissions maps full gRPC method names ("/pkg.Service/Method") to the
+// permission they require. Methods missing from the map are refused.
+type MethodPermissions map[string]MethodPermission
+
+// Decision explains the outcome of an authorization request.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// DecidingPolicy is the deny that refused, or the first allow that granted.
+	DecidingPolicy *Policy            `json:"deciding_policy,omitempty"`
+	EffectiveRoles []string           `json:"effective_roles"`
+	Evaluations    []PolicyEvaluation `json:"evaluations"`
+}
+
+// PolicyEvaluation records how one policy whose role, action and resource type
+// apply to the request evaluated.
+type PolicyEvaluation struct {
+	Policy    Policy `json:"policy"`
+	Condition bool   `json:"condition"`
+	Error     string `json:"error,omitempty"`
 }
 
 type Service interface {
 	Authorize(ctx context.Context, sub Subject, act Action, res Resource) (bool, error)
+	Explain(ctx context.Context, sub Subject, act Action, res Resource) (Decision, error)
 	GenerateToken(ctx context.Context, sub Subject) (string, error)
 	ValidateToken(ctx context.Context, token string) (Subject, error)
-	Login(ctx context.Context, username, password string) (string, error)
+	JWKS(ctx context.Context) JWKSet
+	Login(ctx context.Context, username, password string) (LoginResult, error)
+	VerifyMFA(ctx context.Context, challengeToken, code string) (TokenPair, error)
+	LoginExternal(ctx context.Context, id ExternalIdentity) (TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
 	HTTPMiddleware(next http.Handler) http.Handler
 	GRPCUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error)
+	GRPCStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error
 
 	// User management
 	CreateUser(ctx context.Context, sub Subject, user *User) error
+	// UpdateUser replaces the profile of user. Whether it is a service
+	// account cannot be changed.
 	UpdateUser(ctx context.Context, sub Subject, user *User) error
 	GetUser(ctx context.Context, sub Subject, id string) (*User, error)
 	ListUsers(ctx context.Context, sub Subject) ([]*User, error)
+	DeleteUser(ctx context.Context, sub Subject, id string) error
+	SetUserDisabled(ctx context.Context, sub Subject, id string, disabled bool) error
+
+	// Self-service profile
+	GetMe(ctx context.Context, sub Subject) (*User, error)
+	UpdateMe(ctx context.Context, sub Subject, update ProfileUpdate) (*User, error)
+
+	// API keys for service accounts
+	ValidateAPIKey(ctx context.Context, key string) (Subject, error)
+	IssueAPIKey(ctx context.Context, sub Subject, key *APIKey) (string, error)
+	ListAPIKeys(ctx context.Context, sub Subject, userID string) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, sub Subject, id string) error
+
+	// Multi-factor authentication
+	EnrollMFA(ctx context.Context, sub Subject) (MFASetup, error)
+	ConfirmMFA(ctx context.Context, sub Subject, code string) ([]string, error)
+	ResetMFA(ctx context.Context, sub Subject, userID string) error
+
+	// Account recovery
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	VerifyEmail(ctx context.Context, token string) error
+
+	// Login lockouts
+	UnlockUser(ctx context.Context, sub Subject, userID string) error
+	UnlockIP(ctx context.Context, sub Subject, ip string) error
+
+	// Impersonation
+	Impersonate(ctx context.Context, sub Subject, userID, reason string, ttl time.Duration) (ImpersonationToken, error)
+	EndImpersonation(ctx context.Context, sub Subject) error
 }
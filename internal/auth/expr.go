@@ -0,0 +1,530 @@
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Condition expressions are a small, side-effect free language evaluated
+// against the subject, the resource and the action of an authorization
+// request:
+//
+//	resource.owner_id == subject.id
+//	subject.department in ["sales", "support"] && action != "delete"
+//	!(resource.locked == true) || subject.role == "admin"
+//
+// Paths start at "subject", "resource" or "action". subject.id,
+// subject.username, subject.role, subject.mfa, subject.actor_id, resource.id
+// and resource.type address the struct fields; any other segment is looked
+// up in Attributes, descending into nested maps. Missing values evaluate to null. Supported operators are
+// == != < <= > >= in && || ! (and the keywords and, or, not), with literals for
+// strings, numbers, true, false, null and [lists].
+
+type exprEnv struct {
+	sub Subject
+	act Action
+	res Resource
+}
+
+type exprNode interface {
+	eval(env exprEnv) (interface{}, error)
+}
+
+// compileExpr parses a condition. An empty condition always holds.
+func compileExpr(src string) (exprNode, error) {
+	if strings.TrimSpace(src) == "" {
+		return literalNode{value: true}, nil
+	}
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.peek().text, p.peek().pos)
+	}
+	return n, nil
+}
+
+// evalCondition evaluates a compiled condition; anything that is not the
+// boolean true counts as false.
+func evalCondition(n exprNode, env exprEnv) (bool, error) {
+	v, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	return ok && b, nil
+}
+
+// --- lexer ---
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func lexExpr(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start})
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			start := i
+			i++
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(src) {
+				if src[i] == '\\' && i+1 < len(src) {
+					sb.WriteByte(src[i+1])
+					i += 2
+					continue
+				}
+				if rune(src[i]) == c {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: start})
+		default:
+			start := i
+			if i+1 < len(src) {
+				switch src[i : i+2] {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					tokens = append(tokens, token{kind: tokOp, text: src[i : i+2], pos: start})
+					i += 2
+					continue
+				}
+			}
+			switch c {
+			case '<', '>', '!', '(', ')', '[', ']', ',', '.':
+				tokens = append(tokens, token{kind: tokOp, text: string(c), pos: start})
+				i++
+			default:
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, start)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// --- parser ---
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token { return p.tokens[p.pos] }
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) accept(texts ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return "", false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			p.next()
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		return fmt.Errorf("expected %q at offset %d", text, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">", "in")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseOperand() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literalNode{value: t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return literalNode{value: f}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		case "subject", "resource", "action":
+			return p.parsePath(t.text)
+		}
+		return nil, fmt.Errorf("unknown identifier %q at offset %d", t.text, t.pos)
+	case tokOp:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			return p.parseList()
+		}
+	}
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+func (p *exprParser) parsePath(root string) (exprNode, error) {
+	path := pathNode{root: root}
+	for {
+		if _, ok := p.accept("."); !ok {
+			break
+		}
+		t := p.next()
+		if t.kind != tokIdent {
+			return nil, fmt.Errorf("expected attribute name at offset %d", t.pos)
+		}
+		path.segments = append(path.segments, t.text)
+	}
+	if root == "action" && len(path.segments) > 0 {
+		return nil, fmt.Errorf("action has no attributes")
+	}
+	if root != "action" && len(path.segments) == 0 {
+		return nil, fmt.Errorf("%s needs an attribute, e.g. %s.id", root, root)
+	}
+	return path, nil
+}
+
+func (p *exprParser) parseList() (exprNode, error) {
+	var list listNode
+	if _, ok := p.accept("]"); ok {
+		return list, nil
+	}
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+		if _, ok := p.accept(","); !ok {
+			return list, p.expect("]")
+		}
+	}
+}
+
+// --- evaluation ---
+
+type literalNode struct{ value interface{} }
+
+func (n literalNode) eval(exprEnv) (interface{}, error) { return n.value, nil }
+
+type listNode struct{ items []exprNode }
+
+func (n listNode) eval(env exprEnv) (interface{}, error) {
+	out := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+type pathNode struct {
+	root     string
+	segments []string
+}
+
+func (n pathNode) eval(env exprEnv) (interface{}, error) {
+	var attrs map[string]interface{}
+	switch n.root {
+	case "action":
+		return string(env.act), nil
+	case "subject":
+		if len(n.segments) == 1 {
+			switch n.segments[0] {
+			case "id":
+				return env.sub.ID, nil
+			case "username":
+				return env.sub.Username, nil
+			case "role":
+				return env.sub.Role, nil
+			case "mfa":
+				return env.sub.MFA, nil
+			case "actor_id":
+				return env.sub.ActorID, nil
+			}
+		}
+		attrs = env.sub.Attributes
+	case "resource":
+		if len(n.segments) == 1 {
+			switch n.segments[0] {
+			case "id":
+				return env.res.ID, nil
+			case "type":
+				return env.res.Type, nil
+			}
+		}
+		attrs = env.res.Attributes
+	}
+
+	var cur interface{} = attrs
+	for _, seg := range n.segments {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		cur = m[seg]
+	}
+	return cur, nil
+}
+
+type notNode struct{ operand exprNode }
+
+func (n notNode) eval(env exprEnv) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	b, _ := v.(bool)
+	return !b, nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n logicalNode) eval(env exprEnv) (interface{}, error) {
+	l, err := evalCondition(n.left, env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" && !l {
+		return false, nil
+	}
+	if n.op == "||" && l {
+		return true, nil
+	}
+	return evalCondition(n.right, env)
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n compareNode) eval(env exprEnv) (interface{}, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(l, r), nil
+	case "!=":
+		return !valuesEqual(l, r), nil
+	case "in":
+		for _, item := range toList(r) {
+			if valuesEqual(l, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if lf, ok := toFloat(l); ok {
+		if rf, ok := toFloat(r); ok {
+			return compareOrdered(n.op, lf, rf), nil
+		}
+	}
+	if ls, ok := l.(string); ok {
+		if rs, ok := r.(string); ok {
+			return compareOrdered(n.op, ls, rs), nil
+		}
+	}
+	return false, nil
+}
+
+func compareOrdered[T float64 | string](op string, l, r T) bool {
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+	return false
+}
+
+func valuesEqual(l, r interface{}) bool {
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+	if lf, ok := toFloat(l); ok {
+		rf, ok := toFloat(r)
+		return ok && lf == rf
+	}
+	switch lv := l.(type) {
+	case string:
+		rv, ok := r.(string)
+		return ok && lv == rv
+	case bool:
+		rv, ok := r.(bool)
+		return ok && lv == rv
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func toList(v interface{}) []interface{} {
+	switch l := v.(type) {
+	case []interface{}:
+		return l
+	case []string:
+		out := make([]interface{}, len(l))
+		for i, s := range l {
+			out[i] = s
+		}
+		return out
+	}
+	return nil
+}
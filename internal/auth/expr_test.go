@@ -0,0 +1,107 @@
+package auth
+
+import "testing"
+
+func TestConditionEvaluation(t *testing.T) {
+	env := exprEnv{
+		sub: Subject{
+			ID:   "u1",
+			Role: "user",
+			Attributes: map[string]interface{}{
+				"verified":   true,
+				"department": "sales",
+				"level":      3,
+				"org":        map[string]interface{}{"region": "eu"},
+			},
+		},
+		act: ActionUpdate,
+		res: Resource{
+			Type: "order",
+			ID:   "o1",
+			Attributes: map[string]interface{}{
+				"owner_id": "u1",
+				"amount":   250.5,
+				"locked":   false,
+			},
+		},
+	}
+
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{"", true},
+		{"resource.owner_id == subject.id", true},
+		{"resource.owner_id != subject.id", false},
+		{`subject.department in ["sales", "support"]`, true},
+		{`subject.department in ["support"]`, false},
+		{`subject.department in []`, false},
+		{`action == "update"`, true},
+		{`action != "delete" && subject.role == "user"`, true},
+		{"resource.amount > 100", true},
+		{"resource.amount <= 100", false},
+		{"subject.level >= 3", true},
+		{"subject.level < 3", false},
+		{"-1 < 0", true},
+		{`"abc" < "abd"`, true},
+		{"subject.org.region == 'eu'", true},
+		{"subject.org.missing == null", true},
+		{"subject.department.nested == null", true},
+		{"resource.missing == null", true},
+		{"resource.missing == false", false},
+		{"resource.missing > 0", false},
+		{"!(resource.locked == true)", true},
+		{"not resource.locked", true},
+		{"resource.locked or subject.verified", true},
+		{"resource.locked and subject.verified", false},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		// ! binds looser than comparisons: !(true == false).
+		{"!true == false", true},
+		{"subject.verified == true", true},
+		{`resource.type == "order" && resource.id == "o1"`, true},
+		{"1 == 1.0", true},
+		{`1 == "1"`, false},
+		{`"it\"s" == 'it"s'`, true},
+		// Non-boolean results do not hold.
+		{"subject.department", false},
+		{"resource.amount", false},
+	}
+	for _, tt := range tests {
+		n, err := compileExpr(tt.cond)
+		if err != nil {
+			t.Errorf("compileExpr(%q): %v", tt.cond, err)
+			continue
+		}
+		got, err := evalCondition(n, env)
+		if err != nil {
+			t.Errorf("evalCondition(%q): %v", tt.cond, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("evalCondition(%q) = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
+
+func TestCompileExprErrors(t *testing.T) {
+	tests := []string{
+		"resource.owner_id ==",
+		"resource.owner_id = subject.id",
+		"subject",
+		"action.name == 'x'",
+		"user.id == 'x'",
+		`"unterminated`,
+		"(true",
+		"[1, 2",
+		"true true",
+		"subject.id == 'a' #",
+		"subject.",
+		"1.2.3 == 1",
+	}
+	for _, src := range tests {
+		if _, err := compileExpr(src); err == nil {
+			t.Errorf("compileExpr(%q) succeeded, want error", src)
+		}
+	}
+}
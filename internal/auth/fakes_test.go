@@ -0,0 +1,398 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// In-memory implementations of the repositories, for tests of the service.
+
+type memUsers struct {
+	mu    sync.Mutex
+	users map[string]User
+}
+
+func newMemUsers(users ...User) *memUsers {
+	r := &memUsers{users: map[string]User{}}
+	for _, u := range users {
+		r.users[u.ID] = u
+	}
+	return r
+}
+
+func (r *memUsers) find(match func(User) bool) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if match(u) {
+			return &u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r *memUsers) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return r.find(func(u User) bool { return u.Username == username })
+}
+
+func (r *memUsers) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return r.find(func(u User) bool { return u.Email != "" && strings.EqualFold(u.Email, email) })
+}
+
+func (r *memUsers) GetByID(ctx context.Context, id string) (*User, error) {
+	return r.find(func(u User) bool { return u.ID == id })
+}
+
+func (r *memUsers) Create(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.CreatedAt = time.Now()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memUsers) Update(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[user.ID]; !ok {
+		return ErrUserNotFound
+	}
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memUsers) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return ErrUserNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
+
+func (r *memUsers) List(ctx context.Context) ([]*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*User
+	for _, u := range r.users {
+		u := u
+		users = append(users, &u)
+	}
+	return users, nil
+}
+
+type memSigningKeys struct {
+	mu   sync.Mutex
+	keys []*SigningKey
+}
+
+func (r *memSigningKeys) ListActive(ctx context.Context) ([]*SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*SigningKey(nil), r.keys...), nil
+}
+
+func (r *memSigningKeys) Create(ctx context.Context, key *SigningKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, key)
+	return nil
+}
+
+type memRefreshTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*RefreshToken
+}
+
+func newMemRefreshTokens() *memRefreshTokens {
+	return &memRefreshTokens{tokens: map[string]*RefreshToken{}}
+}
+
+func (r *memRefreshTokens) Create(ctx context.Context, token *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := *token
+	r.tokens[token.ID] = &t
+	return nil
+}
+
+func (r *memRefreshTokens) GetByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.TokenHash == hash {
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+func (r *memRefreshTokens) MarkRotated(ctx context.Context, id, replacedBy string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[id]
+	if !ok || t.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.RevokedAt = &now
+	return true, nil
+}
+
+func (r *memRefreshTokens) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.revoke(func(t *RefreshToken) bool { return t.FamilyID == familyID })
+}
+
+func (r *memRefreshTokens) RevokeUser(ctx context.Context, userID string) error {
+	return r.revoke(func(t *RefreshToken) bool { return t.UserID == userID })
+}
+
+func (r *memRefreshTokens) revoke(match func(*RefreshToken) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, t := range r.tokens {
+		if match(t) && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type memIdentities struct {
+	mu    sync.Mutex
+	links map[string]string // issuer + " " + subject -> user ID
+}
+
+func newMemIdentities() *memIdentities {
+	return &memIdentities{links: map[string]string{}}
+}
+
+func (r *memIdentities) GetUserID(ctx context.Context, issuer, subject string) (string, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.links[issuer+" "+subject]
+	return id, ok, nil
+}
+
+func (r *memIdentities) Link(ctx context.Context, userID, issuer, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.links[issuer+" "+subject] = userID
+	return nil
+}
+
+// passthroughTx runs units of work without a transaction.
+type passthroughTx struct{}
+
+func (passthroughTx) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// newTestService returns a service with in-memory signing keys and refresh
+// tokens, the default policies and the collaborators set in cfg.
+func newTestService(t *testing.T, cfg ServiceConfig) *service {
+	t.Helper()
+	ctx := context.Background()
+	if cfg.Keys == nil {
+		keys, err := NewKeyManager(ctx, &memSigningKeys{}, KeyManagerConfig{})
+		if err != nil {
+			t.Fatalf("NewKeyManager: %v", err)
+		}
+		cfg.Keys = keys
+	}
+	if cfg.Policies == nil {
+		policies, err := NewPolicyEngine(ctx, NewStaticPolicySource(DefaultPolicySet()), 0)
+		if err != nil {
+			t.Fatalf("NewPolicyEngine: %v", err)
+		}
+		cfg.Policies = policies
+	}
+	if cfg.Users == nil {
+		cfg.Users = newMemUsers()
+	}
+	if cfg.RefreshTokens == nil {
+		cfg.RefreshTokens = newMemRefreshTokens()
+	}
+	if cfg.UnitOfWork == nil {
+		cfg.UnitOfWork = passthroughTx{}
+	}
+	return NewService(cfg).(*service)
+}
+
+type memMFA struct {
+	mu          sync.Mutex
+	enrollments map[string]MFAEnrollment
+}
+
+func newMemMFA(enrollments ...MFAEnrollment) *memMFA {
+	r := &memMFA{enrollments: map[string]MFAEnrollment{}}
+	for _, e := range enrollments {
+		r.enrollments[e.UserID] = e
+	}
+	return r
+}
+
+func (r *memMFA) Get(ctx context.Context, userID string) (*MFAEnrollment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.enrollments[userID]
+	if !ok {
+		return nil, ErrMFANotEnrolled
+	}
+	return &e, nil
+}
+
+func (r *memMFA) Save(ctx context.Context, enrollment *MFAEnrollment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enrollments[enrollment.UserID] = *enrollment
+	return nil
+}
+
+func (r *memMFA) Delete(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.enrollments, userID)
+	return nil
+}
+
+func (r *memMFA) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.enrollments[userID]
+	if !ok || step <= e.LastUsedStep {
+		return false, nil
+	}
+	e.LastUsedStep = step
+	r.enrollments[userID] = e
+	return true, nil
+}
+
+func (r *memMFA) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error {
+	return nil
+}
+
+func (r *memMFA) UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+	return false, nil
+}
+
+func (r *memMFA) CreateChallenge(ctx context.Context, challenge *LoginChallenge) error {
+	return nil
+}
+
+func (r *memMFA) GetChallenge(ctx context.Context, hash string) (*LoginChallenge, error) {
+	return nil, ErrInvalidToken
+}
+
+func (r *memMFA) IncrementChallengeAttempts(ctx context.Context, id string) (int, error) {
+	return 0, ErrInvalidToken
+}
+
+func (r *memMFA) DeleteChallenge(ctx context.Context, id string) error {
+	return nil
+}
+
+type memLoginAttempts struct {
+	mu       sync.Mutex
+	failures map[string]int
+	locked   map[string]time.Time
+}
+
+func newMemLoginAttempts() *memLoginAttempts {
+	return &memLoginAttempts{failures: map[string]int{}, locked: map[string]time.Time{}}
+}
+
+func (r *memLoginAttempts) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.locked[key], nil
+}
+
+func (r *memLoginAttempts) RecordFailure(ctx context.Context, key string, resetAfter time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[key]++
+	return r.failures[key], nil
+}
+
+func (r *memLoginAttempts) Lock(ctx context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.locked[key] = until
+	return nil
+}
+
+func (r *memLoginAttempts) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failures, key)
+	delete(r.locked, key)
+	return nil
+}
+
+type memUserTokens struct {
+	mu     sync.Mutex
+	tokens []UserToken
+	err    error // returned by every call when set
+}
+
+func (r *memUserTokens) Create(ctx context.Context, token *UserToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+func (r *memUserTokens) Consume(ctx context.Context, hash string, purpose UserTokenPurpose) (*UserToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	for i, t := range r.tokens {
+		if t.Hash == hash && t.Purpose == purpose && time.Now().Before(t.ExpiresAt) {
+			r.tokens = append(r.tokens[:i], r.tokens[i+1:]...)
+			return &t, nil
+		}
+	}
+	return nil, ErrInvalidUserToken
+}
+
+func (r *memUserTokens) InvalidateUser(ctx context.Context, userID string, purpose UserTokenPurpose) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	kept := r.tokens[:0]
+	for _, t := range r.tokens {
+		if t.UserID != userID || t.Purpose != purpose {
+			kept = append(kept, t)
+		}
+	}
+	r.tokens = kept
+	return nil
+}
+
+// chanMailer delivers messages to a channel. Sends block until the message
+// is received.
+type chanMailer chan Message
+
+func (m chanMailer) Send(ctx context.Context, msg Message) error {
+	select {
+	case m <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -3,6 +3,9 @@ package auth
 import (
 	"encoding/json"
 	"net/http"
+	"time"
+
+	commonhttp "hex-postgres-grpc/internal/common/adapters/http"
 )
 
 type Handler struct {
@@ -15,21 +18,45 @@ func NewHandler(service Service) *Handler {
 
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /auth/login", h.Login)
+	mux.HandleFunc("POST /auth/refresh", h.Refresh)
+	mux.HandleFunc("POST /auth/logout", h.Logout)
+	mux.HandleFunc("GET /.well-known/jwks.json", h.JWKS)
+	mux.HandleFunc("POST /auth/explain", h.Explain)
 	mux.HandleFunc("POST /users", h.CreateUser)
 	mux.HandleFunc("PUT /users/{id}", h.UpdateUser)
 	mux.HandleFunc("GET /users/{id}", h.GetUser)
 	mux.HandleFunc("GET /users", h.ListUsers)
+	mux.HandleFunc("DELETE /users/{id}", h.DeleteUser)
+	mux.HandleFunc("POST /users/{id}/deactivate", h.DeactivateUser)
+	mux.HandleFunc("POST /users/{id}/activate", h.ActivateUser)
+	mux.HandleFunc("POST /users/{id}/impersonate", h.Impersonate)
+	mux.HandleFunc("DELETE /auth/impersonation", h.EndImpersonation)
+	mux.HandleFunc("GET /me", h.GetMe)
+	mux.HandleFunc("PATCH /me", h.UpdateMe)
+	mux.HandleFunc("POST /users/{id}/api-keys", h.IssueAPIKey)
+	mux.HandleFunc("GET /users/{id}/api-keys", h.ListAPIKeys)
+	mux.HandleFunc("DELETE /api-keys/{id}", h.RevokeAPIKey)
+	mux.HandleFunc("POST /auth/mfa/verify", h.VerifyMFA)
+	mux.HandleFunc("POST /auth/mfa/enroll", h.EnrollMFA)
+	mux.HandleFunc("POST /auth/mfa/confirm", h.ConfirmMFA)
+	mux.HandleFunc("DELETE /users/{id}/mfa", h.ResetMFA)
+	mux.HandleFunc("DELETE /users/{id}/lockout", h.UnlockUser)
+	mux.HandleFunc("DELETE /auth/lockouts/ips/{ip}", h.UnlockIP)
+	mux.HandleFunc("POST /auth/password/forgot", h.ForgotPassword)
+	mux.HandleFunc("POST /auth/password/reset", h.ResetPassword)
+	mux.HandleFunc("POST /auth/verify", h.VerifyEmail)
 }
 
 // Login handles user authentication
 // @Summary Login
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return an access token and a refresh token. Users with MFA enabled get a challenge instead, to be completed at /auth/mfa/verify.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body object{username=string,password=string} true "Login Request"
-// @Success 200 {object} map[string]string "token"
-// @Failure 401 {string} string "Unauthorized"
+// @Success 200 {object} auth.TokenPair
+// @Success 202 {object} auth.MFAChallenge
+// @Failure 401 {object} commonhttp.Problem "Unauthorized"
 // @Router /auth/login [post]
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -37,93 +64,862 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	token, err := h.service.Login(r.Context(), req.Username, req.Password)
+	result, err := h.service.Login(r.Context(), req.Username, req.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	if result.Challenge != nil {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(result.Challenge)
+		return
+	}
+	json.NewEncoder(w).Encode(result.Tokens)
 }
 
+// Refresh rotates a refresh token
+// @Summary Refresh
+// @Description Exchange a refresh token for a new token pair. The presented refresh token is invalidated.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body object{refresh_token=string} true "Refresh Request"
+// @Success 200 {object} auth.TokenPair
+// @Failure 401 {object} commonhttp.Problem "Unauthorized"
+// @Router /auth/refresh [post]
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tokens, err := h.service.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout revokes a refresh token family
+// @Summary Logout
+// @Description Revoke the refresh token and every token rotated from the same login
+// @Tags auth
+// @Accept json
+// @Param request body object{refresh_token=string} true "Logout Request"
+// @Success 204 "No Content"
+// @Failure 401 {object} commonhttp.Problem "Unauthorized"
+// @Router /auth/logout [post]
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), req.RefreshToken); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKS publishes the token verification keys
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens, selected by the token's kid header
+// @Tags auth
+// @Produce json
+// @Success 200 {object} auth.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.service.JWKS(r.Context()))
+}
+
+type ExplainRequest struct {
+	Subject struct {
+		ID         string                 `json:"id"`
+		Username   string                 `json:"username"`
+		Role       string                 `json:"role"`
+		Attributes map[string]interface{} `json:"attributes"`
+		MFA        bool                   `json:"mfa"`
+	} `json:"subject"`
+	Action   Action `json:"action"`
+	Resource struct {
+		Type       string                 `json:"type"`
+		ID         string                 `json:"id"`
+		Attributes map[string]interface{} `json:"attributes"`
+	} `json:"resource"`
+}
+
+// Explain shows how an authorization request would be decided
+// @Summary Explain authorization decision
+// @Description Evaluate a subject/action/resource against the current policies and report which policy decided, or why none matched. Admin only.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ExplainRequest true "Authorization request"
+// @Success 200 {object} auth.Decision
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Router /auth/explain [post]
+func (h *Handler) Explain(w http.ResponseWriter, r *http.Request) {
+	sub, ok := SubjectFromContext(r.Context())
+	if !ok {
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	authorized, err := h.service.Authorize(r.Context(), sub, ActionRead, Resource{Type: "policy"})
+	if err != nil || !authorized {
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	var req ExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	decision, err := h.service.Explain(r.Context(), Subject{
+		ID:         req.Subject.ID,
+		Username:   req.Subject.Username,
+		Role:       req.Subject.Role,
+		Attributes: req.Subject.Attributes,
+		MFA:        req.Subject.MFA,
+	}, req.Action, Resource{
+		Type:       req.Resource.Type,
+		ID:         req.Resource.ID,
+		Attributes: req.Resource.Attributes,
+	})
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(decision)
+}
+
+// UserResponse is the public representation of a user. Password hashes are
+// never included.
+type UserResponse struct {
+	ID             string                 `json:"id"`
+	Username       string                 `json:"username"`
+	Role           string                 `json:"role"`
+	Attributes     map[string]interface{} `json:"attributes"`
+	ServiceAccount bool                   `json:"service_account"`
+	Email          string                 `json:"email,omitempty"`
+	EmailVerified  bool                   `json:"email_verified"`
+	Disabled       bool                   `json:"disabled"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+func toUserResponse(u *User) UserResponse {
+	return UserResponse{
+		ID:             u.ID,
+		Username:       u.Username,
+		Role:           u.Role,
+		Attributes:     u.Attributes,
+		ServiceAccount: u.ServiceAccount,
+		Email:          u.Email,
+		EmailVerified:  u.EmailVerified,
+		Disabled:       u.Disabled,
+		CreatedAt:      u.CreatedAt,
+		UpdatedAt:      u.UpdatedAt,
+	}
+}
+
+type CreateUserRequest struct {
+	Username       string                 `json:"username"`
+	Password       string                 `json:"password"`
+	Role           string                 `json:"role"`
+	Attributes     map[string]interface{} `json:"attributes"`
+	Email          string                 `json:"email"`
+	ServiceAccount bool                   `json:"service_account"`
+}
+
+// UpdateUserRequest replaces a user's profile. An empty password keeps the
+// current one.
+type UpdateUserRequest struct {
+	Username   string                 `json:"username"`
+	Password   string                 `json:"password"`
+	Role       string                 `json:"role"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Email      string                 `json:"email"`
+}
+
+// UpdateMeRequest changes the caller's own profile. Omitted fields are left
+// unchanged; a new password requires the current one.
+type UpdateMeRequest struct {
+	Email           *string `json:"email,omitempty"`
+	Password        *string `json:"password,omitempty"`
+	CurrentPassword string  `json:"current_password,omitempty"`
+}
+
+// CreateUser creates a user
+// @Summary Create user
+// @Description Create a user or, with service_account set, a service account without a password. Admin only.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateUserRequest true "User"
+// @Success 201 {object} UserResponse
+// @Failure 400 {object} commonhttp.Problem "bad request"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Router /users [post]
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	sub, _ := SubjectFromContext(r.Context())
-	var user User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err := h.service.CreateUser(r.Context(), sub, &user); err != nil {
-		if err == ErrUnauthorized {
-			http.Error(w, err.Error(), http.StatusForbidden)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	user := &User{
+		Username:       req.Username,
+		Password:       req.Password,
+		Role:           req.Role,
+		Attributes:     req.Attributes,
+		Email:          req.Email,
+		ServiceAccount: req.ServiceAccount,
+	}
+	if err := h.service.CreateUser(r.Context(), sub, user); err != nil {
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(toUserResponse(user))
 }
 
+// UpdateUser replaces a user's profile
+// @Summary Update user
+// @Description Replace a user's username, role, attributes and email. The password is only changed when one is given. Admin only.
+// @Tags users
+// @Accept json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body UpdateUserRequest true "User"
+// @Success 204 "No Content"
+// @Failure 400 {object} commonhttp.Problem "bad request"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Router /users/{id} [put]
 func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	sub, _ := SubjectFromContext(r.Context())
-	id := r.PathValue("id")
-	var user User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	user.ID = id
 
-	if err := h.service.UpdateUser(r.Context(), sub, &user); err != nil {
-		if err == ErrUnauthorized {
-			http.Error(w, err.Error(), http.StatusForbidden)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	user := &User{
+		ID:         r.PathValue("id"),
+		Username:   req.Username,
+		Password:   req.Password,
+		Role:       req.Role,
+		Attributes: req.Attributes,
+		Email:      req.Email,
+	}
+	if err := h.service.UpdateUser(r.Context(), sub, user); err != nil {
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetUser returns a user
+// @Summary Get user
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} UserResponse
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Router /users/{id} [get]
 func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	sub, _ := SubjectFromContext(r.Context())
 	id := r.PathValue("id")
 
 	user, err := h.service.GetUser(r.Context(), sub, id)
 	if err != nil {
-		if err == ErrUnauthorized {
-			http.Error(w, err.Error(), http.StatusForbidden)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusNotFound)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(toUserResponse(user))
 }
 
+// ListUsers lists users
+// @Summary List users
+// @Description List all users that have not been deleted, including deactivated ones
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} UserResponse
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Router /users [get]
 func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	sub, _ := SubjectFromContext(r.Context())
 
 	users, err := h.service.ListUsers(r.Context(), sub)
 	if err != nil {
-		if err == ErrUnauthorized {
-			http.Error(w, err.Error(), http.StatusForbidden)
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	resp := make([]UserResponse, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, toUserResponse(u))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteUser deletes a user
+// @Summary Delete user
+// @Description Soft-delete a user and sign out all of its sessions. The username and email can be reused afterwards. Admin only.
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Router /users/{id} [delete]
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	sub, _ := SubjectFromContext(r.Context())
+
+	if err := h.service.DeleteUser(r.Context(), sub, r.PathValue("id")); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeactivateUser deactivates a user
+// @Summary Deactivate user
+// @Description Block a user from signing in. Existing access tokens, refresh tokens and API keys stop working immediately. Admin only.
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Router /users/{id}/deactivate [post]
+func (h *Handler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, true)
+}
+
+// ActivateUser reactivates a user
+// @Summary Activate user
+// @Description Allow a deactivated user to sign in again. Admin only.
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Router /users/{id}/activate [post]
+func (h *Handler) ActivateUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, false)
+}
+
+func (h *Handler) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	sub, _ := SubjectFromContext(r.Context())
+
+	if err := h.service.SetUserDisabled(r.Context(), sub, r.PathValue("id"), disabled); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason"`
+	// DurationSeconds defaults to 15 minutes and is capped at one hour.
+	DurationSeconds int64 `json:"duration_seconds,omitempty"`
+}
+
+// Impersonate starts acting as a user
+// @Summary Impersonate user
+// @Description Issue a short-lived access token that acts as the user. The token records the caller as the actor, and writes made with it name both. It cannot be refreshed and stops working when it expires or is ended with DELETE /auth/impersonation. Admin only; administrators and service accounts cannot be impersonated.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body ImpersonateRequest true "Impersonation"
+// @Success 201 {object} auth.ImpersonationToken
+// @Failure 400 {object} commonhttp.Problem "bad request"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Router /users/{id}/impersonate [post]
+func (h *Handler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	sub, _ := SubjectFromContext(r.Context())
+
+	var req ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token, err := h.service.Impersonate(r.Context(), sub, r.PathValue("id"), req.Reason, time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(token)
+}
+
+// EndImpersonation ends the caller's impersonation session
+// @Summary End impersonation
+// @Description End the impersonation session of the token used for this request. The token is refused from then on.
+// @Tags users
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 400 {object} commonhttp.Problem "not an impersonation session"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Router /auth/impersonation [delete]
+func (h *Handler) EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	sub, ok := SubjectFromContext(r.Context())
+	if !ok {
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if err := h.service.EndImpersonation(r.Context(), sub); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMe returns the caller's profile
+// @Summary Get own profile
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} UserResponse
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Router /me [get]
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	sub, ok := SubjectFromContext(r.Context())
+	if !ok {
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	user, err := h.service.GetMe(r.Context(), sub)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toUserResponse(user))
+}
+
+// UpdateMe changes the caller's profile
+// @Summary Update own profile
+// @Description Change the caller's email address or password. Changing the password requires current_password; a new email address has to be verified again.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateMeRequest true "Profile changes"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} commonhttp.Problem "bad request"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "not allowed while impersonating"
+// @Router /me [patch]
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	sub, ok := SubjectFromContext(r.Context())
+	if !ok {
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req UpdateMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.service.UpdateMe(r.Context(), sub, ProfileUpdate{
+		Email:           req.Email,
+		Password:        req.Password,
+		CurrentPassword: req.CurrentPassword,
+	})
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toUserResponse(user))
+}
+
+type IssueAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type IssueAPIKeyResponse struct {
+	// Key is only returned here; store it securely.
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"api_key"`
+}
+
+// IssueAPIKey creates an API key for a service account
+// @Summary Issue API key
+// @Description Create an API key for a service account. Scopes have the form resource_type:action, either part may be "*". The key is only returned in this response. Admin only.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Service account user ID"
+// @Param request body IssueAPIKeyRequest true "API key"
+// @Success 201 {object} IssueAPIKeyResponse
+// @Failure 400 {object} commonhttp.Problem "bad request"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Router /users/{id}/api-keys [post]
+func (h *Handler) IssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	sub, _ := SubjectFromContext(r.Context())
+	var req IssueAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key := &APIKey{
+		UserID:    r.PathValue("id"),
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	raw, err := h.service.IssueAPIKey(r.Context(), sub, key)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(IssueAPIKeyResponse{Key: raw, APIKey: key})
+}
+
+// ListAPIKeys lists the API keys of a service account
+// @Summary List API keys
+// @Description List the API keys of a service account, including revoked and expired keys. Admin only.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Service account user ID"
+// @Success 200 {array} auth.APIKey
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Router /users/{id}/api-keys [get]
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	sub, _ := SubjectFromContext(r.Context())
+
+	keys, err := h.service.ListAPIKeys(r.Context(), sub, r.PathValue("id"))
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey revokes an API key
+// @Summary Revoke API key
+// @Description Revoke an API key immediately. Admin only.
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "API key ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Router /api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	sub, _ := SubjectFromContext(r.Context())
+
+	if err := h.service.RevokeAPIKey(r.Context(), sub, r.PathValue("id")); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyMFA completes a login with a second factor
+// @Summary Verify MFA
+// @Description Complete a login that returned an MFA challenge, using a TOTP code or a recovery code
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body object{challenge_token=string,code=string} true "Verify Request"
+// @Success 200 {object} auth.TokenPair
+// @Failure 401 {object} commonhttp.Problem "Unauthorized"
+// @Router /auth/mfa/verify [post]
+func (h *Handler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tokens, err := h.service.VerifyMFA(r.Context(), req.ChallengeToken, req.Code)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// EnrollMFA starts TOTP enrollment
+// @Summary Enroll MFA
+// @Description Generate a TOTP secret for the calling user. It takes effect after /auth/mfa/confirm.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} auth.MFASetup
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "not allowed while impersonating"
+// @Failure 409 {object} commonhttp.Problem "mfa is already enabled"
+// @Router /auth/mfa/enroll [post]
+func (h *Handler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	sub, ok := SubjectFromContext(r.Context())
+	if !ok {
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	setup, err := h.service.EnrollMFA(r.Context(), sub)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(setup)
+}
+
+// ConfirmMFA enables TOTP for the calling user
+// @Summary Confirm MFA
+// @Description Enable MFA with a code from the authenticator app and return single-use recovery codes. They are shown only once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body object{code=string} true "Confirm Request"
+// @Success 200 {object} object{recovery_codes=[]string}
+// @Failure 400 {object} commonhttp.Problem "invalid mfa code"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "not allowed while impersonating"
+// @Failure 409 {object} commonhttp.Problem "mfa is already enabled"
+// @Router /auth/mfa/confirm [post]
+func (h *Handler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	sub, ok := SubjectFromContext(r.Context())
+	if !ok {
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	codes, err := h.service.ConfirmMFA(r.Context(), sub, req.Code)
+	if err != nil {
+		// A wrong code while enrolling is a bad request, not a failed login.
+		if err == ErrInvalidMFACode {
+			commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// ResetMFA removes a user's MFA enrollment
+// @Summary Reset MFA
+// @Description Remove a user's TOTP secret and recovery codes, e.g. after a lost device. Admin only.
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Router /users/{id}/mfa [delete]
+func (h *Handler) ResetMFA(w http.ResponseWriter, r *http.Request) {
+	sub, _ := SubjectFromContext(r.Context())
+
+	if err := h.service.ResetMFA(r.Context(), sub, r.PathValue("id")); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlockUser lifts a login lockout
+// @Summary Unlock user
+// @Description Clear the failed login count and any lockout of a user. Admin only.
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Router /users/{id}/lockout [delete]
+func (h *Handler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	sub, _ := SubjectFromContext(r.Context())
+
+	if err := h.service.UnlockUser(r.Context(), sub, r.PathValue("id")); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlockIP lifts a login lockout of a client address
+// @Summary Unlock IP address
+// @Description Clear the failed login count and any lockout of a client IP address. Admin only.
+// @Tags auth
+// @Security BearerAuth
+// @Param ip path string true "IP address"
+// @Success 204 "No Content"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Router /auth/lockouts/ips/{ip} [delete]
+func (h *Handler) UnlockIP(w http.ResponseWriter, r *http.Request) {
+	sub, _ := SubjectFromContext(r.Context())
+
+	if err := h.service.UnlockIP(r.Context(), sub, r.PathValue("ip")); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForgotPassword emails a password reset token
+// @Summary Forgot password
+// @Description Email a single-use password reset token to the user with this address. The response is the same whether or not the address is registered.
+// @Tags auth
+// @Accept json
+// @Param request body object{email=string} true "Forgot Password Request"
+// @Success 202 "Accepted"
+// @Router /auth/password/forgot [post]
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.service.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password
+// @Summary Reset password
+// @Description Set a new password with a token from /auth/password/forgot. All sessions of the user are signed out.
+// @Tags auth
+// @Accept json
+// @Param request body object{token=string,password=string} true "Reset Password Request"
+// @Success 204 "No Content"
+// @Failure 400 {object} commonhttp.Problem "invalid or expired token"
+// @Router /auth/password/reset [post]
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.service.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail confirms an email address
+// @Summary Verify email
+// @Description Mark the user's email address as verified with the token sent to it
+// @Tags auth
+// @Accept json
+// @Param request body object{token=string} true "Verify Request"
+// @Success 204 "No Content"
+// @Failure 400 {object} commonhttp.Problem "invalid or expired token"
+// @Router /auth/verify [post]
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.service.VerifyEmail(r.Context(), req.Token); err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"hex-postgres-grpc/internal/common/apperr"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultImpersonationTTL = 15 * time.Minute
+	MaxImpersonationTTL     = time.Hour
+)
+
+var (
+	ErrReasonRequired          = apperr.NewField("reason", "REASON_REQUIRED", "a reason is required to impersonate a user")
+	ErrImpersonationNotAllowed = apperr.New(apperr.PermissionDenied, "IMPERSONATION_NOT_ALLOWED", "not allowed while impersonating")
+	ErrNotImpersonating        = apperr.New(apperr.FailedPrecondition, "NOT_IMPERSONATING", "not an impersonation session")
+	ErrImpersonationNotFound   = apperr.New(apperr.NotFound, "IMPERSONATION_NOT_FOUND", "impersonation not found")
+)
+
+// Impersonate starts a session in which sub acts as the user. The returned
+// token carries both: the user as the subject and sub as the actor. ttl
+// defaults to DefaultImpersonationTTL and is capped at MaxImpersonationTTL.
+func (s *service) Impersonate(ctx context.Context, sub Subject, userID, reason string, ttl time.Duration) (ImpersonationToken, error) {
+	if sub.ActorID != "" {
+		return ImpersonationToken{}, ErrImpersonationNotAllowed
+	}
+	if reason == "" {
+		return ImpersonationToken{}, ErrReasonRequired
+	}
+
+	u, err := s.activeUser(ctx, userID)
+	if err == ErrInvalidToken {
+		return ImpersonationToken{}, ErrUserNotFound
+	}
+	if err != nil {
+		return ImpersonationToken{}, err
+	}
+	if u.ID == sub.ID || u.ServiceAccount {
+		return ImpersonationToken{}, ErrImpersonationNotAllowed
+	}
+
+	// The target's role is exposed to policies, e.g. to keep administrators
+	// from being impersonated.
+	authorized, err := s.Authorize(ctx, sub, ActionCreate, Resource{
+		Type:       "impersonation",
+		ID:         u.ID,
+		Attributes: map[string]interface{}{"role": u.Role},
+	})
+	if err != nil {
+		return ImpersonationToken{}, err
+	}
+	if !authorized {
+		return ImpersonationToken{}, ErrUnauthorized
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultImpersonationTTL
+	}
+	if ttl > MaxImpersonationTTL {
+		ttl = MaxImpersonationTTL
+	}
+	now := time.Now()
+	imp := &Impersonation{
+		ID:        uuid.NewString(),
+		ActorID:   sub.ID,
+		SubjectID: u.ID,
+		Reason:    reason,
+		StartedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.impersonations.Create(ctx, imp); err != nil {
+		return ImpersonationToken{}, err
+	}
+
+	token, err := s.signToken(Subject{
+		ID:              u.ID,
+		TenantID:        u.TenantID,
+		Username:        u.Username,
+		Role:            u.Role,
+		Attributes:      u.Attributes,
+		ActorID:         sub.ID,
+		ImpersonationID: imp.ID,
+	}, imp.ExpiresAt)
+	if err != nil {
+		return ImpersonationToken{}, err
+	}
+
+	return ImpersonationToken{
+		AccessToken:     token,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(ttl.Seconds()),
+		ImpersonationID: imp.ID,
+	}, nil
+}
+
+// EndImpersonation ends the impersonation session sub belongs to. Its token
+// is refused from then on.
+func (s *service) EndImpersonation(ctx context.Context, sub Subject) error {
+	if sub.ImpersonationID == "" {
+		return ErrNotImpersonating
+	}
+	return s.impersonations.End(ctx, sub.ImpersonationID)
+}
+
+// checkImpersonation verifies that the session of an impersonation token is
+// still running and that the administrator behind it is still active.
+func (s *service) checkImpersonation(ctx context.Context, id, subjectID, actorID string) error {
+	imp, err := s.impersonations.Get(ctx, id)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if imp.EndedAt != nil || time.Now().After(imp.ExpiresAt) || imp.SubjectID != subjectID || imp.ActorID != actorID {
+		return ErrInvalidToken
+	}
+	_, err = s.activeUser(ctx, actorID)
+	return err
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// SigningKey is an asymmetric key used to sign access tokens. Keys are told
+// apart by their ID, which is published as the JWT "kid" header.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+	// ExpiresAt is the end of the verification window: the key stops signing
+	// when a newer key is created and stays verifiable until then.
+	ExpiresAt time.Time
+}
+
+type SigningKeyRepository interface {
+	// ListActive returns keys whose verification window has not ended.
+	ListActive(ctx context.Context) ([]*SigningKey, error)
+	Create(ctx context.Context, key *SigningKey) error
+}
+
+type KeyManagerConfig struct {
+	Algorithm string
+	// RotationInterval is how long a key is used for signing.
+	RotationInterval time.Duration
+	// Overlap is how long a retired key stays valid for verification. It is
+	// never shorter than AccessTokenTTL.
+	Overlap time.Duration
+	// RefreshInterval controls how often keys created by other instances are
+	// picked up and rotation is checked.
+	RefreshInterval time.Duration
+}
+
+// KeyManager keeps the set of signing keys in memory and rotates them on a
+// schedule. Keys are persisted so every instance signs and verifies with the
+// same key set.
+type KeyManager struct {
+	repo SigningKeyRepository
+	cfg  KeyManagerConfig
+
+	mu   sync.RWMutex
+	keys []*SigningKey // newest first
+}
+
+func NewKeyManager(ctx context.Context, repo SigningKeyRepository, cfg KeyManagerConfig) (*KeyManager, error) {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = AlgRS256
+	}
+	if cfg.Algorithm != AlgRS256 && cfg.Algorithm != AlgEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", cfg.Algorithm)
+	}
+	if cfg.RotationInterval <= 0 {
+		cfg.RotationInterval = 30 * 24 * time.Hour
+	}
+	if cfg.Overlap < AccessTokenTTL {
+		cfg.Overlap = AccessTokenTTL
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = time.Minute
+	}
+
+	m := &KeyManager{repo: repo, cfg: cfg}
+	if err := m.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Run reloads keys and rotates them when due until ctx is cancelled.
+func (m *KeyManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.refresh(ctx); err != nil {
+				log.Printf("auth: refresh signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// Rotate creates a new signing key immediately. The previous key keeps
+// verifying tokens until the end of its overlap window.
+func (m *KeyManager) Rotate(ctx context.Context) error {
+	key, err := generateSigningKey(m.cfg.Algorithm, m.cfg.RotationInterval+m.cfg.Overlap)
+	if err != nil {
+		return err
+	}
+	if err := m.repo.Create(ctx, key); err != nil {
+		return err
+	}
+	return m.load(ctx)
+}
+
+func (m *KeyManager) refresh(ctx context.Context) error {
+	if err := m.load(ctx); err != nil {
+		return err
+	}
+	m.mu.RLock()
+	due := len(m.keys) == 0 || time.Since(m.keys[0].CreatedAt) >= m.cfg.RotationInterval
+	m.mu.RUnlock()
+	if due {
+		return m.Rotate(ctx)
+	}
+	return nil
+}
+
+func (m *KeyManager) load(ctx context.Context) error {
+	keys, err := m.repo.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *KeyManager) signingKey() (*SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	return m.keys[0], nil
+}
+
+func (m *KeyManager) verificationKey(kid string) (*SigningKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	for _, k := range m.keys {
+		if k.ID == kid && now.Before(k.ExpiresAt) {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// JWKS returns the public half of every key that can still verify tokens.
+func (m *KeyManager) JWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	now := time.Now()
+	for _, k := range m.keys {
+		if now.Before(k.ExpiresAt) {
+			set.Keys = append(set.Keys, k.jwk())
+		}
+	}
+	return set
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *SigningKey) jwk() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+	switch pub := k.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+func generateSigningKey(alg string, lifetime time.Duration) (*SigningKey, error) {
+	var signer crypto.Signer
+	switch alg {
+	case AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		signer = key
+	case AlgEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		signer = key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", alg)
+	}
+
+	now := time.Now()
+	return &SigningKey{
+		ID:         uuid.NewString(),
+		Algorithm:  alg,
+		PrivateKey: signer,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(lifetime),
+	}, nil
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLockoutDelay(t *testing.T) {
+	p := LockoutPolicy{Threshold: 5, BaseDelay: 30 * time.Second, MaxDelay: 15 * time.Minute}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, 30 * time.Second},
+		{6, time.Minute},
+		{7, 2 * time.Minute},
+		{9, 8 * time.Minute},
+		{10, 15 * time.Minute},
+		{1000, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	hasher := NewBcryptHasher(bcrypt.MinCost)
+	hash, err := hasher.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newService := func() (*service, *memLoginAttempts) {
+		attempts := newMemLoginAttempts()
+		svc := newTestService(t, ServiceConfig{
+			Users: newMemUsers(
+				User{ID: "u1", Username: "ann", Role: "user", PasswordHash: hash},
+				User{ID: "u2", Username: "bob", Role: "user", PasswordHash: hash},
+			),
+			Hasher:        hasher,
+			MFA:           newMemMFA(),
+			LoginAttempts: attempts,
+			UserLockout:   LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour},
+			IPLockout:     LockoutPolicy{Threshold: 5, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour},
+		})
+		return svc, attempts
+	}
+	fail := func(t *testing.T, svc *service, ctx context.Context, username string, n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			if _, err := svc.Login(ctx, username, "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("wrong password: %v, want ErrInvalidCredentials", err)
+			}
+		}
+	}
+
+	t.Run("below threshold", func(t *testing.T) {
+		svc, attempts := newService()
+		ctx := context.Background()
+		fail(t, svc, ctx, "ann", 2)
+		if _, err := svc.Login(ctx, "ann", "correct horse"); err != nil {
+			t.Fatalf("login below threshold: %v", err)
+		}
+		if n := attempts.failures[userAttemptKey(ctx, "ann")]; n != 0 {
+			t.Fatalf("%d failures after successful login, want 0", n)
+		}
+	})
+
+	t.Run("user locked at threshold", func(t *testing.T) {
+		svc, attempts := newService()
+		ctx := context.Background()
+		start := time.Now()
+		fail(t, svc, ctx, "ann", 3)
+		until := attempts.locked[userAttemptKey(ctx, "ann")]
+		if until.Before(start.Add(time.Minute)) || until.After(time.Now().Add(time.Minute)) {
+			t.Fatalf("locked until %v, want a minute from now", until)
+		}
+		if _, err := svc.Login(ctx, "ann", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("login while locked: %v, want ErrInvalidCredentials", err)
+		}
+		if _, err := svc.Login(ctx, "bob", "correct horse"); err != nil {
+			t.Fatalf("other user locked out too: %v", err)
+		}
+	})
+
+	t.Run("unknown usernames are locked too", func(t *testing.T) {
+		svc, attempts := newService()
+		ctx := context.Background()
+		fail(t, svc, ctx, "nobody", 3)
+		if attempts.locked[userAttemptKey(ctx, "nobody")].IsZero() {
+			t.Fatal("unknown username was not locked")
+		}
+	})
+
+	t.Run("ip locked across usernames", func(t *testing.T) {
+		svc, _ := newService()
+		ctx := WithClientIP(context.Background(), "192.0.2.1")
+		fail(t, svc, ctx, "ann", 2)
+		fail(t, svc, ctx, "bob", 2)
+		fail(t, svc, ctx, "carol", 1)
+		if _, err := svc.Login(ctx, "bob", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("login from locked ip: %v, want ErrInvalidCredentials", err)
+		}
+		other := WithClientIP(context.Background(), "192.0.2.2")
+		if _, err := svc.Login(other, "bob", "correct horse"); err != nil {
+			t.Fatalf("login from another ip: %v", err)
+		}
+	})
+}
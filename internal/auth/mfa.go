@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"hex-postgres-grpc/internal/common/apperr"
+	"hex-postgres-grpc/internal/common/tenant"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// LoginChallengeTTL is how long a user has to enter the second factor.
+	LoginChallengeTTL = 5 * time.Minute
+	// maxChallengeAttempts bounds guessing of TOTP codes per challenge.
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+	totpPeriod           = 30
+	// totpSkew accepts codes from one period either side of now.
+	totpSkew = 1
+)
+
+var (
+	ErrMFANotEnrolled     = apperr.New(apperr.FailedPrecondition, "MFA_NOT_ENROLLED", "mfa is not enrolled")
+	ErrMFAAlreadyEnabled  = apperr.New(apperr.AlreadyExists, "MFA_ALREADY_ENABLED", "mfa is already enabled")
+	ErrInvalidMFACode     = apperr.New(apperr.Unauthenticated, "INVALID_MFA_CODE", "invalid mfa code")
+	ErrInvalidChallenge   = apperr.New(apperr.Unauthenticated, "INVALID_MFA_CHALLENGE", "invalid or expired mfa challenge")
+	ErrMFASetupIncomplete = apperr.New(apperr.FailedPrecondition, "MFA_SETUP_INCOMPLETE", "mfa enrollment has not been started")
+)
+
+var totpOpts = totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+// EnrollMFA starts TOTP enrollment for the calling user. The secret takes
+// effect once ConfirmMFA has verified a code generated from it; starting over
+// replaces an unconfirmed secret.
+func (s *service) EnrollMFA(ctx context.Context, sub Subject) (MFASetup, error) {
+	if sub.ID == "" || sub.APIKeyID != "" {
+		return MFASetup{}, ErrUnauthorized
+	}
+	if sub.ActorID != "" {
+		return MFASetup{}, ErrImpersonationNotAllowed
+	}
+
+	existing, err := s.mfa.Get(ctx, sub.ID)
+	if err != nil && err != ErrMFANotEnrolled {
+		return MFASetup{}, err
+	}
+	if existing != nil && existing.Enabled {
+		return MFASetup{}, ErrMFAAlreadyEnabled
+	}
+
+	u, err := s.repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		return MFASetup{}, err
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.mfaIssuer,
+		AccountName: u.Username,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return MFASetup{}, err
+	}
+
+	if err := s.mfa.Save(ctx, &MFAEnrollment{
+		UserID:    u.ID,
+		Secret:    key.Secret(),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		return MFASetup{}, err
+	}
+	return MFASetup{Secret: key.Secret(), OTPAuthURI: key.URL()}, nil
+}
+
+// ConfirmMFA enables the pending enrollment when code is valid and returns a
+// fresh set of recovery codes. They are not stored in readable form and
+// cannot be shown again.
+func (s *service) ConfirmMFA(ctx context.Context, sub Subject, code string) ([]string, error) {
+	if sub.ID == "" || sub.APIKeyID != "" {
+		return nil, ErrUnauthorized
+	}
+	if sub.ActorID != "" {
+		return nil, ErrImpersonationNotAllowed
+	}
+
+	enrollment, err := s.mfa.Get(ctx, sub.ID)
+	if err == ErrMFANotEnrolled {
+		return nil, ErrMFASetupIncomplete
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	ok, err := s.checkTOTP(ctx, enrollment, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.mfa.ReplaceRecoveryCodes(ctx, sub.ID, hashes); err != nil {
+			return err
+		}
+		enrollment.Enabled = true
+		return s.mfa.Save(ctx, enrollment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetMFA removes a user's enrollment and recovery codes, e.g. after a lost
+// device. The user signs in with the password alone until enrolling again.
+func (s *service) ResetMFA(ctx context.Context, sub Subject, userID string) error {
+	authorized, err := s.Authorize(ctx, sub, ActionDelete, Resource{Type: "mfa", ID: userID})
+	if err != nil {
+		return err
+	}
+	if !authorized {
+		return ErrUnauthorized
+	}
+
+	// Enrollments are keyed by user ID only; make sure the user belongs to
+	// the caller's tenant.
+	if _, err := s.repo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+	return s.mfa.Delete(ctx, userID)
+}
+
+// VerifyMFA completes a login started by Login with a TOTP code or a
+// recovery code.
+func (s *service) VerifyMFA(ctx context.Context, challengeToken, code string) (TokenPair, error) {
+	challenge, err := s.mfa.GetChallenge(ctx, hashToken(challengeToken))
+	if err != nil || time.Now().After(challenge.ExpiresAt) {
+		return TokenPair{}, ErrInvalidChallenge
+	}
+
+	attempts, err := s.mfa.IncrementChallengeAttempts(ctx, challenge.ID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if attempts > maxChallengeAttempts {
+		if err := s.mfa.DeleteChallenge(ctx, challenge.ID); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrInvalidChallenge
+	}
+
+	ctx = tenant.WithID(ctx, challenge.TenantID)
+	u, err := s.repo.GetByID(ctx, challenge.UserID)
+	if err != nil || u.Disabled {
+		return TokenPair{}, ErrInvalidChallenge
+	}
+	// Second-factor guesses count towards the same lockout as passwords.
+	locked, err := s.loginLocked(ctx, u.Username)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if locked {
+		return TokenPair{}, ErrInvalidMFACode
+	}
+
+	enrollment, err := s.mfa.Get(ctx, challenge.UserID)
+	if err != nil || !enrollment.Enabled {
+		return TokenPair{}, ErrInvalidChallenge
+	}
+
+	ok, err := s.checkTOTP(ctx, enrollment, code)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !ok {
+		ok, err = s.mfa.UseRecoveryCode(ctx, challenge.UserID, hashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return TokenPair{}, err
+		}
+	}
+	if !ok {
+		s.recordLoginFailure(ctx, u.Username)
+		return TokenPair{}, ErrInvalidMFACode
+	}
+
+	if err := s.mfa.DeleteChallenge(ctx, challenge.ID); err != nil {
+		return TokenPair{}, err
+	}
+
+	s.resetLoginFailures(ctx, u.Username)
+	return s.issueTokenPair(ctx, u, uuid.NewString(), true)
+}
+
+func (s *service) newLoginChallenge(ctx context.Context, userID string) (*MFAChallenge, error) {
+	raw, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfa.CreateChallenge(ctx, &LoginChallenge{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Hash:      hashToken(raw),
+		ExpiresAt: time.Now().Add(LoginChallengeTTL),
+	}); err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{
+		MFARequired:    true,
+		ChallengeToken: raw,
+		ExpiresIn:      int64(LoginChallengeTTL.Seconds()),
+	}, nil
+}
+
+// checkTOTP accepts code if it matches a time step within the allowed skew
+// that is newer than the last accepted one, and marks that step as used.
+func (s *service) checkTOTP(ctx context.Context, enrollment *MFAEnrollment, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	now := s.now()
+	for i := -totpSkew; i <= totpSkew; i++ {
+		t := now.Add(time.Duration(i*totpPeriod) * time.Second)
+		want, err := totp.GenerateCodeCustom(enrollment.Secret, t, totpOpts)
+		if err != nil {
+			return false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) != 1 {
+			continue
+		}
+		step := t.Unix() / totpPeriod
+		if step <= enrollment.LastUsedStep {
+			return false, nil
+		}
+		return s.mfa.UseStep(ctx, enrollment.UserID, step)
+	}
+	return false, nil
+}
+
+// newRecoveryCodes returns codes formatted as XXXX-XXXX-XXXX-XXXX together
+// with the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := base32.StdEncoding.EncodeToString(b)
+		codes[i] = fmt.Sprintf("%s-%s-%s-%s", raw[0:4], raw[4:8], raw[8:12], raw[12:16])
+		hashes[i] = hashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
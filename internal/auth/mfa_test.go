@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+// testNow is the time of the service clock in the TOTP tests.
+var testNow = time.Date(2026, 1, 1, 12, 0, 10, 0, time.UTC)
+
+func TestCheckTOTPWindow(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		offset int // periods from now
+		want   bool
+	}{
+		{"current step", 0, true},
+		{"previous step", -1, true},
+		{"next step", 1, true},
+		{"two steps back", -2, false},
+		{"two steps ahead", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mfa := newMemMFA(MFAEnrollment{UserID: "u1", Secret: testTOTPSecret, Enabled: true})
+			svc := &service{mfa: mfa, now: func() time.Time { return testNow }}
+			code, err := totp.GenerateCodeCustom(testTOTPSecret, testNow.Add(time.Duration(tt.offset*totpPeriod)*time.Second), totpOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, _ := mfa.Get(context.Background(), "u1")
+			ok, err := svc.checkTOTP(context.Background(), e, code)
+			if err != nil {
+				t.Fatalf("checkTOTP: %v", err)
+			}
+			if ok != tt.want {
+				t.Fatalf("checkTOTP() = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTOTPRejectsReplay(t *testing.T) {
+	ctx := context.Background()
+	mfa := newMemMFA(MFAEnrollment{UserID: "u1", Secret: testTOTPSecret, Enabled: true})
+	svc := &service{mfa: mfa, now: func() time.Time { return testNow }}
+	code := func(offset int) string {
+		c, err := totp.GenerateCodeCustom(testTOTPSecret, testNow.Add(time.Duration(offset*totpPeriod)*time.Second), totpOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	check := func(code string) bool {
+		e, _ := mfa.Get(ctx, "u1")
+		ok, err := svc.checkTOTP(ctx, e, code)
+		if err != nil {
+			t.Fatalf("checkTOTP: %v", err)
+		}
+		return ok
+	}
+
+	if !check(code(0)) {
+		t.Fatal("current code rejected")
+	}
+	if check(code(0)) {
+		t.Fatal("current code accepted twice")
+	}
+	// A code from before the last used step is spent too, even though it is
+	// still inside the window.
+	if check(code(-1)) {
+		t.Fatal("older code accepted after a newer one")
+	}
+	if !check(code(1)) {
+		t.Fatal("next code rejected")
+	}
+	if check(code(1)) {
+		t.Fatal("next code accepted twice")
+	}
+}
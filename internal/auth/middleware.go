@@ -2,12 +2,18 @@ package auth
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
 
+	commonhttp "hex-postgres-grpc/internal/common/adapters/http"
+	"hex-postgres-grpc/internal/common/apperr"
+	"hex-postgres-grpc/internal/common/tenant"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -17,54 +23,201 @@ const (
 	SubjectContextKey contextKey = "subject"
 )
 
+// APIKeyHeader carries an API key over HTTP. Over gRPC the key is sent in the
+// lowercase metadata key of the same name.
+const APIKeyHeader = "X-API-Key"
+
+// TenantHeader names the tenant of a request, over gRPC in the lowercase
+// metadata key of the same name. Authenticated requests belong to the tenant
+// of their credentials, so the header is only needed to log in and for other
+// anonymous requests; when both are present they must match. Requests without
+// either belong to tenant.Default.
+const TenantHeader = "X-Tenant-ID"
+
+var ErrTenantMismatch = apperr.New(apperr.PermissionDenied, "TENANT_MISMATCH", "credentials belong to a different tenant")
+
+// withTenant returns ctx carrying the tenant of the request. sub is nil for
+// anonymous requests.
+func withTenant(ctx context.Context, requested string, sub *Subject) (context.Context, error) {
+	if sub != nil {
+		if requested != "" && requested != sub.TenantID {
+			return nil, ErrTenantMismatch
+		}
+		return tenant.WithID(ctx, sub.TenantID), nil
+	}
+	if requested == "" {
+		requested = tenant.Default
+	}
+	return tenant.WithID(ctx, requested), nil
+}
+
 func (s *service) HTTPMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// X-Forwarded-For is not trusted: it is set by the client unless a
+		// proxy in front of the server overwrites it.
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			r = r.WithContext(WithClientIP(r.Context(), host))
+		}
+
+		requested := r.Header.Get(TenantHeader)
+		authenticated := func(sub Subject) {
+			ctx, err := withTenant(r.Context(), requested, &sub)
+			if err != nil {
+				commonhttp.WriteError(w, r, err)
+				return
+			}
+			ctx = context.WithValue(ctx, SubjectContextKey, sub)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			sub, err := s.ValidateAPIKey(r.Context(), key)
+			if err != nil {
+				commonhttp.WriteProblem(w, r, http.StatusUnauthorized, err.Error())
+				return
+			}
+			authenticated(sub)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			next.ServeHTTP(w, r)
+			ctx, _ := withTenant(r.Context(), requested, nil)
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "invalid auth header", http.StatusUnauthorized)
+			commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "invalid auth header")
 			return
 		}
 
 		sub, err := s.ValidateToken(r.Context(), parts[1])
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			commonhttp.WriteProblem(w, r, http.StatusUnauthorized, err.Error())
 			return
 		}
-
-		ctx := context.WithValue(r.Context(), SubjectContextKey, sub)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		authenticated(sub)
 	})
 }
 
+// GRPCUnaryInterceptor authenticates the caller and enforces the permission
+// mapped to the called method before the handler runs.
 func (s *service) GRPCUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	res := Resource{}
+	if r, ok := req.(interface{ GetId() string }); ok {
+		res.ID = r.GetId()
+	}
+
+	ctx, err := s.authorizeMethod(ctx, info.FullMethod, res)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// GRPCStreamInterceptor is the streaming counterpart of GRPCUnaryInterceptor.
+// The check runs once when the stream opens.
+func (s *service) GRPCStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authorizeMethod(ss.Context(), info.FullMethod, Resource{})
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorizeMethod returns ctx carrying the caller's subject, or a gRPC status
+// error if the caller may not invoke fullMethod.
+func (s *service) authorizeMethod(ctx context.Context, fullMethod string, res Resource) (context.Context, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			ctx = WithClientIP(ctx, host)
+		}
+	}
+
+	perm, ok := s.methods[fullMethod]
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "no permission mapping for %s", fullMethod)
+	}
+
+	sub, authenticated, err := s.subjectFromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var requested string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(strings.ToLower(TenantHeader)); len(v) > 0 {
+			requested = v[0]
+		}
+	}
+	if authenticated {
+		if ctx, err = withTenant(ctx, requested, &sub); err != nil {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		ctx = context.WithValue(ctx, SubjectContextKey, sub)
+	} else {
+		ctx, _ = withTenant(ctx, requested, nil)
+	}
+
+	if perm.Public {
+		return ctx, nil
+	}
+	if !authenticated {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token or api key")
+	}
+	if perm.Action == "" {
+		return ctx, nil
+	}
+
+	res.Type = perm.ResourceType
+	authorized, err := s.Authorize(ctx, sub, perm.Action, res)
+	if err != nil || !authorized {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	return ctx, nil
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// subjectFromMetadata validates the API key or bearer token in the incoming
+// metadata, if any. It reports false when neither was sent.
+func (s *service) subjectFromMetadata(ctx context.Context) (Subject, bool, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return handler(ctx, req)
+		return Subject{}, false, nil
+	}
+
+	if key := md.Get(strings.ToLower(APIKeyHeader)); len(key) > 0 {
+		sub, err := s.ValidateAPIKey(ctx, key[0])
+		if err != nil {
+			return Subject{}, false, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return sub, true, nil
 	}
 
 	authHeader := md.Get("authorization")
 	if len(authHeader) == 0 {
-		return handler(ctx, req)
+		return Subject{}, false, nil
 	}
 
 	parts := strings.Split(authHeader[0], " ")
 	if len(parts) != 2 || parts[0] != "Bearer" {
-		return nil, status.Error(codes.Unauthenticated, "invalid auth header")
+		return Subject{}, false, status.Error(codes.Unauthenticated, "invalid auth header")
 	}
 
 	sub, err := s.ValidateToken(ctx, parts[1])
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, err.Error())
+		return Subject{}, false, status.Error(codes.Unauthenticated, err.Error())
 	}
-
-	newCtx := context.WithValue(ctx, SubjectContextKey, sub)
-	return handler(newCtx, req)
+	return sub, true, nil
 }
 
 // Helper to get subject from context
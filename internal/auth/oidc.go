@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"hex-postgres-grpc/internal/common/apperr"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrIdentityNotAllowed = apperr.New(apperr.PermissionDenied, "IDENTITY_NOT_ALLOWED", "identity is not allowed to sign in")
+	ErrUsernameTaken      = apperr.New(apperr.AlreadyExists, "USERNAME_TAKEN", "username is already used by another account")
+)
+
+// ExternalIdentity is a user authenticated by an external identity provider.
+type ExternalIdentity struct {
+	Issuer  string
+	Subject string
+	Claims  map[string]interface{}
+}
+
+// IdentityProvider runs the authorization-code flow against an external
+// identity provider.
+type IdentityProvider interface {
+	// AuthCodeURL returns the provider URL the browser is sent to. verifier is
+	// the PKCE code verifier; only its challenge is sent.
+	AuthCodeURL(state, nonce, verifier string) string
+	// Exchange redeems the authorization code and returns the verified
+	// identity. The ID token must carry nonce.
+	Exchange(ctx context.Context, code, verifier, nonce string) (ExternalIdentity, error)
+}
+
+// IdentityRepository links external identities to local users.
+type IdentityRepository interface {
+	GetUserID(ctx context.Context, issuer, subject string) (string, bool, error)
+	Link(ctx context.Context, userID, issuer, subject string) error
+}
+
+// ClaimMapping turns identity provider claims into a local role and
+// attributes. Claim names may be dotted paths into nested claims, e.g.
+// "realm_access.roles".
+type ClaimMapping struct {
+	// UsernameClaim defaults to preferred_username, falling back to email and
+	// then to the subject.
+	UsernameClaim string `json:"username_claim" yaml:"username_claim"`
+	// Roles are tried in order; the first matching rule sets the role.
+	Roles []RoleRule `json:"roles" yaml:"roles"`
+	// DefaultRole applies when no rule matches. If empty, such identities may
+	// not sign in.
+	DefaultRole string `json:"default_role" yaml:"default_role"`
+	// Attributes maps attribute names to the claim they are copied from.
+	Attributes map[string]string `json:"attributes" yaml:"attributes"`
+}
+
+// RoleRule matches when Claim equals Value or, for list claims, contains it.
+type RoleRule struct {
+	Claim string `json:"claim" yaml:"claim"`
+	Value string `json:"value" yaml:"value"`
+	Role  string `json:"role" yaml:"role"`
+}
+
+func DefaultClaimMapping() ClaimMapping {
+	return ClaimMapping{
+		DefaultRole: "user",
+		Attributes:  map[string]string{"email": "email", "full_name": "name"},
+	}
+}
+
+// LoginExternal signs in a user authenticated by an identity provider. The
+// first sign-in provisions a local user; later sign-ins refresh its role and
+// mapped attributes from the claims.
+func (s *service) LoginExternal(ctx context.Context, id ExternalIdentity) (TokenPair, error) {
+	role := s.claims.role(id.Claims)
+	if role == "" {
+		return TokenPair{}, ErrIdentityNotAllowed
+	}
+	attrs := s.claims.attributes(id.Claims)
+
+	userID, linked, err := s.identities.GetUserID(ctx, id.Issuer, id.Subject)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	var u *User
+	if linked {
+		u, err = s.repo.GetByID(ctx, userID)
+		if err != nil {
+			return TokenPair{}, err
+		}
+		if u.Disabled {
+			return TokenPair{}, ErrIdentityNotAllowed
+		}
+		if u.Attributes == nil {
+			u.Attributes = map[string]interface{}{}
+		}
+		for k, v := range attrs {
+			u.Attributes[k] = v
+		}
+		u.Role = role
+		if err := s.repo.Update(ctx, u); err != nil {
+			return TokenPair{}, err
+		}
+	} else {
+		username := s.claims.username(id)
+		// Never attach an external identity to an existing local account.
+		if _, err := s.repo.GetByUsername(ctx, username); err == nil {
+			return TokenPair{}, ErrUsernameTaken
+		}
+
+		email, _ := id.Claims["email"].(string)
+		verified, _ := id.Claims["email_verified"].(bool)
+		u = &User{
+			ID:            uuid.NewString(),
+			Username:      username,
+			Role:          role,
+			Attributes:    attrs,
+			Email:         email,
+			EmailVerified: email != "" && verified,
+		}
+		if err := s.repo.Create(ctx, u); err != nil {
+			return TokenPair{}, err
+		}
+		if err := s.identities.Link(ctx, u.ID, id.Issuer, id.Subject); err != nil {
+			return TokenPair{}, err
+		}
+	}
+
+	// Trust the identity provider's own second factor when it reports one.
+	return s.issueTokenPair(ctx, u, uuid.NewString(), claimMatches(id.Claims["amr"], "mfa"))
+}
+
+func (m ClaimMapping) username(id ExternalIdentity) string {
+	claims := []string{"preferred_username", "email"}
+	if m.UsernameClaim != "" {
+		claims = []string{m.UsernameClaim}
+	}
+	for _, c := range claims {
+		if v, ok := lookupClaim(id.Claims, c).(string); ok && v != "" {
+			return v
+		}
+	}
+	return id.Subject
+}
+
+func (m ClaimMapping) role(claims map[string]interface{}) string {
+	for _, r := range m.Roles {
+		if claimMatches(lookupClaim(claims, r.Claim), r.Value) {
+			return r.Role
+		}
+	}
+	return m.DefaultRole
+}
+
+func (m ClaimMapping) attributes(claims map[string]interface{}) map[string]interface{} {
+	attrs := map[string]interface{}{}
+	for name, claim := range m.Attributes {
+		if v := lookupClaim(claims, claim); v != nil {
+			attrs[name] = v
+		}
+	}
+	return attrs
+}
+
+func lookupClaim(claims map[string]interface{}, path string) interface{} {
+	var v interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	return v
+}
+
+func claimMatches(v interface{}, want string) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case []interface{}:
+		for _, item := range v {
+			if fmt.Sprint(item) == want {
+				return true
+			}
+		}
+		return false
+	default:
+		return fmt.Sprint(v) == want
+	}
+}
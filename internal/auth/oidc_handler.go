@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	commonhttp "hex-postgres-grpc/internal/common/adapters/http"
+	"hex-postgres-grpc/internal/common/tenant"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+// OIDCHandler serves the OpenID Connect authorization-code flow with PKCE.
+// The state, nonce and code verifier of a login attempt are kept in a
+// short-lived HttpOnly cookie scoped to the callback path, together with the
+// tenant the user signs in to.
+type OIDCHandler struct {
+	service  Service
+	provider IdentityProvider
+	// secureCookie should be true whenever the API is served over HTTPS.
+	secureCookie bool
+}
+
+func NewOIDCHandler(service Service, provider IdentityProvider, secureCookie bool) *OIDCHandler {
+	return &OIDCHandler{service: service, provider: provider, secureCookie: secureCookie}
+}
+
+func (h *OIDCHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /auth/oidc/login", h.Login)
+	mux.HandleFunc("GET /auth/oidc/callback", h.Callback)
+}
+
+// Login starts an OpenID Connect login
+// @Summary OIDC login
+// @Description Redirect to the identity provider to sign in. Browsers cannot send the X-Tenant-ID header on a redirect, so the tenant may be given as a query parameter instead.
+// @Tags auth
+// @Param tenant query string false "Tenant ID"
+// @Success 302 "Redirect to the identity provider"
+// @Router /auth/oidc/login [get]
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	state, err := randomString()
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+	verifier, err := randomString()
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+	tenantID := r.URL.Query().Get("tenant")
+	if tenantID == "" {
+		tenantID, _ = tenant.FromContext(r.Context())
+	}
+	if tenantID == "" {
+		tenantID = tenant.Default
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    strings.Join([]string{state, nonce, verifier, base64.RawURLEncoding.EncodeToString([]byte(tenantID))}, "."),
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, h.provider.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// Callback completes an OpenID Connect login
+// @Summary OIDC callback
+// @Description Redeem the authorization code returned by the identity provider and return a token pair. Users are provisioned on their first sign-in.
+// @Tags auth
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 200 {object} auth.TokenPair
+// @Failure 400 {object} commonhttp.Problem "Bad Request"
+// @Failure 401 {object} commonhttp.Problem "Unauthorized"
+// @Failure 403 {object} commonhttp.Problem "Forbidden"
+// @Failure 409 {object} commonhttp.Problem "Conflict"
+// @Router /auth/oidc/callback [get]
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "missing login state")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc", MaxAge: -1})
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 4 || r.URL.Query().Get("state") != parts[0] {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "invalid login state")
+		return
+	}
+	tenantID, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil || len(tenantID) == 0 {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "invalid login state")
+		return
+	}
+	ctx := tenant.WithID(r.Context(), string(tenantID))
+	if e := r.URL.Query().Get("error"); e != "" {
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, e)
+		return
+	}
+
+	id, err := h.provider.Exchange(ctx, r.URL.Query().Get("code"), parts[2], parts[1])
+	if err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tokens, err := h.service.LoginExternal(ctx, id)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClaimMapping(t *testing.T) {
+	m := ClaimMapping{
+		UsernameClaim: "upn",
+		Roles: []RoleRule{
+			{Claim: "realm_access.roles", Value: "shop-admin", Role: "admin"},
+			{Claim: "department", Value: "support", Role: "support"},
+		},
+		DefaultRole: "user",
+		Attributes:  map[string]string{"email": "email", "region": "org.region"},
+	}
+
+	tests := []struct {
+		name     string
+		claims   map[string]interface{}
+		role     string
+		username string
+		attrs    map[string]interface{}
+	}{
+		{
+			name:     "nested list claim",
+			claims:   map[string]interface{}{"upn": "ann", "realm_access": map[string]interface{}{"roles": []interface{}{"viewer", "shop-admin"}}},
+			role:     "admin",
+			username: "ann",
+			attrs:    map[string]interface{}{},
+		},
+		{
+			name:     "first matching rule wins",
+			claims:   map[string]interface{}{"upn": "bob", "department": "support", "realm_access": map[string]interface{}{"roles": []interface{}{"shop-admin"}}},
+			role:     "admin",
+			username: "bob",
+			attrs:    map[string]interface{}{},
+		},
+		{
+			name:     "scalar claim",
+			claims:   map[string]interface{}{"upn": "cat", "department": "support", "email": "cat@example.com"},
+			role:     "support",
+			username: "cat",
+			attrs:    map[string]interface{}{"email": "cat@example.com"},
+		},
+		{
+			name:     "default role and subject as username",
+			claims:   map[string]interface{}{"org": map[string]interface{}{"region": "eu"}},
+			role:     "user",
+			username: "sub-1",
+			attrs:    map[string]interface{}{"region": "eu"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.role(tt.claims); got != tt.role {
+				t.Errorf("role = %q, want %q", got, tt.role)
+			}
+			if got := m.username(ExternalIdentity{Subject: "sub-1", Claims: tt.claims}); got != tt.username {
+				t.Errorf("username = %q, want %q", got, tt.username)
+			}
+			got := m.attributes(tt.claims)
+			if len(got) != len(tt.attrs) {
+				t.Fatalf("attributes = %v, want %v", got, tt.attrs)
+			}
+			for k, v := range tt.attrs {
+				if got[k] != v {
+					t.Errorf("attributes = %v, want %v", got, tt.attrs)
+				}
+			}
+		})
+	}
+
+	// Without a default role, unmatched identities may not sign in.
+	m.DefaultRole = ""
+	if got := m.role(map[string]interface{}{"department": "sales"}); got != "" {
+		t.Errorf("role without default = %q, want none", got)
+	}
+}
+
+func TestDefaultClaimMappingUsername(t *testing.T) {
+	m := DefaultClaimMapping()
+	tests := []struct {
+		claims map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"preferred_username": "ann", "email": "ann@example.com"}, "ann"},
+		{map[string]interface{}{"email": "ann@example.com"}, "ann@example.com"},
+		{map[string]interface{}{}, "sub-1"},
+	}
+	for _, tt := range tests {
+		if got := m.username(ExternalIdentity{Subject: "sub-1", Claims: tt.claims}); got != tt.want {
+			t.Errorf("username(%v) = %q, want %q", tt.claims, got, tt.want)
+		}
+	}
+}
+
+func TestLoginExternal(t *testing.T) {
+	ctx := context.Background()
+	users := newMemUsers(User{ID: "local-1", Username: "bob", Role: "user"})
+	identities := newMemIdentities()
+	mapping := ClaimMapping{
+		Roles:       []RoleRule{{Claim: "groups", Value: "admins", Role: "admin"}},
+		DefaultRole: "user",
+		Attributes:  map[string]string{"department": "department"},
+	}
+	svc := newTestService(t, ServiceConfig{Users: users, Identities: identities, ClaimMapping: mapping})
+
+	first := ExternalIdentity{Issuer: "https://idp", Subject: "s1", Claims: map[string]interface{}{
+		"preferred_username": "ann",
+		"email":              "ann@example.com",
+		"email_verified":     true,
+		"department":         "sales",
+	}}
+
+	// The first sign-in provisions a user and links the identity.
+	tokens, err := svc.LoginExternal(ctx, first)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	sub, err := svc.ValidateToken(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	userID, linked, _ := identities.GetUserID(ctx, "https://idp", "s1")
+	if !linked || userID != sub.ID {
+		t.Fatalf("identity linked to %q (%v), token subject %q", userID, linked, sub.ID)
+	}
+	u, err := users.GetByID(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Username != "ann" || u.Role != "user" || u.Email != "ann@example.com" || !u.EmailVerified || u.Attributes["department"] != "sales" {
+		t.Fatalf("provisioned user = %+v", u)
+	}
+
+	// Later sign-ins reuse the linked user and refresh its role and
+	// attributes from the claims.
+	second := first
+	second.Claims = map[string]interface{}{"preferred_username": "renamed", "groups": []interface{}{"admins"}, "department": "support"}
+	if _, err := svc.LoginExternal(ctx, second); err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	all, _ := users.List(ctx)
+	if len(all) != 2 {
+		t.Fatalf("%d users after second login, want 2", len(all))
+	}
+	u, _ = users.GetByID(ctx, userID)
+	if u.Username != "ann" || u.Role != "admin" || u.Attributes["department"] != "support" {
+		t.Fatalf("updated user = %+v", u)
+	}
+
+	// A new identity is never attached to an existing local account.
+	_, err = svc.LoginExternal(ctx, ExternalIdentity{Issuer: "https://idp", Subject: "s2", Claims: map[string]interface{}{"preferred_username": "bob"}})
+	if !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("login as existing username: %v, want ErrUsernameTaken", err)
+	}
+	if _, linked, _ := identities.GetUserID(ctx, "https://idp", "s2"); linked {
+		t.Fatal("identity was linked to the existing local user")
+	}
+
+	// Disabled users cannot sign in through the identity provider either.
+	u.Disabled = true
+	users.Update(ctx, u)
+	if _, err := svc.LoginExternal(ctx, first); !errors.Is(err, ErrIdentityNotAllowed) {
+		t.Fatalf("login of disabled user: %v, want ErrIdentityNotAllowed", err)
+	}
+}
+
+func TestLoginExternalWithoutRole(t *testing.T) {
+	svc := newTestService(t, ServiceConfig{Identities: newMemIdentities(), ClaimMapping: ClaimMapping{}})
+	_, err := svc.LoginExternal(context.Background(), ExternalIdentity{Issuer: "https://idp", Subject: "s1"})
+	if !errors.Is(err, ErrIdentityNotAllowed) {
+		t.Fatalf("LoginExternal() = %v, want ErrIdentityNotAllowed", err)
+	}
+}
+
+func TestOIDCCallbackRejectsInvalidState(t *testing.T) {
+	h := NewOIDCHandler(nil, nil, false)
+	valid := &http.Cookie{Name: oidcStateCookie, Value: "state.nonce.verifier.YWNtZQ"}
+
+	tests := []struct {
+		name   string
+		query  string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"missing cookie", "code=c&state=state", nil, http.StatusBadRequest},
+		{"state mismatch", "code=c&state=other", valid, http.StatusBadRequest},
+		{"malformed cookie", "code=c&state=state", &http.Cookie{Name: oidcStateCookie, Value: "state.nonce"}, http.StatusBadRequest},
+		{"provider error", "error=access_denied&state=state", valid, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+tt.query, nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			rec := httptest.NewRecorder()
+			h.Callback(rec, r)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
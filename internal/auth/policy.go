@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+type compiledPolicy struct {
+	Policy
+	condition exprNode
+}
+
+type compiledPolicySet struct {
+	roles    map[string][]string
+	policies []compiledPolicy
+}
+
+// deletesEverything reports whether an allow policy grants roles delete on
+// every resource type, regardless of its condition.
+func (set *compiledPolicySet) deletesEverything(roles map[string]bool) bool {
+	for _, p := range set.policies {
+		if roles[p.SubjectRole] && p.Effect != EffectDeny && p.ResourceType == "*" && (p.Action == "*" || p.Action == ActionDelete) {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyEngine holds the compiled policy set and periodically reloads it from
+// its source, so permission changes take effect without a restart.
+type PolicyEngine struct {
+	source   PolicySource
+	interval time.Duration
+
+	mu  sync.RWMutex
+	set compiledPolicySet
+}
+
+// NewPolicyEngine loads the initial policy set. It fails if the source cannot
+// be read or a condition does not compile, so a broken policy file never
+// starts a server with an empty (deny-all) set by accident.
+func NewPolicyEngine(ctx context.Context, source PolicySource, interval time.Duration) (*PolicyEngine, error) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	e := &PolicyEngine{source: source, interval: interval}
+	if err := e.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload replaces the policy set with the current content of the source. The
+// previous set stays in effect if loading or compiling fails.
+func (e *PolicyEngine) Reload(ctx context.Context) error {
+	set, err := e.source.LoadPolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("load policies: %w", err)
+	}
+	compiled, err := compilePolicies(set.Policies)
+	if err != nil {
+		return err
+	}
+	if err := checkRoleCycles(set.Roles); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.set = compiledPolicySet{roles: set.Roles, policies: compiled}
+	e.mu.Unlock()
+	return nil
+}
+
+// Run reloads the policies on every interval until ctx is cancelled.
+func (e *PolicyEngine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Reload(ctx); err != nil {
+				log.Printf("auth: reload policies: %v", err)
+			}
+		}
+	}
+}
+
+func (e *PolicyEngine) snapshot() compiledPolicySet {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.set
+}
+
+// Explain evaluates every policy that targets the request with deny-overrides
+// semantics: any matching deny refuses, otherwise any matching allow grants,
+// otherwise the request is refused.
+func (s *service) Explain(ctx context.Context, sub Subject, act Action, res Resource) (Decision, error) {
+	set := s.policies.snapshot()
+	roles := effectiveRoles(set.roles, sub.Role)
+	d := Decision{EffectiveRoles: roles, Evaluations: []PolicyEvaluation{}}
+
+	// API keys narrow what their service account may do; policies still
+	// decide within the key's scopes.
+	if sub.APIKeyID != "" && !scopeAllows(sub.Scopes, act, res.Type) {
+		d.Reason = fmt.Sprintf("%s on %s is outside the scopes of api key %s", act, res.Type, sub.APIKeyID)
+		return d, nil
+	}
+
+	inRoles := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		inRoles[r] = true
+	}
+
+	// Sessions of roles that may delete every resource need a second factor.
+	if !sub.MFA && sub.APIKeyID == "" && set.deletesEverything(inRoles) {
+		d.Reason = fmt.Sprintf("roles %v can delete every resource and require multi-factor authentication", roles)
+		return d, nil
+	}
+
+	env := exprEnv{sub: sub, act: act, res: res}
+	var allow, deny *Policy
+	for i := range set.policies {
+		p := &set.policies[i]
+		if !inRoles[p.SubjectRole] || !(p.ResourceType == "*" || p.ResourceType == res.Type) || !(p.Action == "*" || p.Action == act) {
+			continue
+		}
+
+		ok, err := evalCondition(p.condition, env)
+		ev := PolicyEvaluation{Policy: p.Policy, Condition: ok}
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		d.Evaluations = append(d.Evaluations, ev)
+		if !ok {
+			continue
+		}
+		if p.Effect == EffectDeny && deny == nil {
+			deny = &p.Policy
+		}
+		if p.Effect != EffectDeny && allow == nil {
+			allow = &p.Policy
+		}
+	}
+
+	switch {
+	case deny != nil:
+		d.DecidingPolicy = deny
+		d.Reason = fmt.Sprintf("denied by policy %s", deny.ID)
+	case allow != nil:
+		d.Allowed = true
+		d.DecidingPolicy = allow
+		d.Reason = fmt.Sprintf("allowed by policy %s", allow.ID)
+	case len(d.Evaluations) == 0:
+		d.Reason = fmt.Sprintf("no policy for roles %v grants %s on %s", roles, act, res.Type)
+	default:
+		d.Reason = fmt.Sprintf("%d applicable policies, but none of their conditions held", len(d.Evaluations))
+	}
+	return d, nil
+}
+
+// effectiveRoles returns role followed by every role it inherits from,
+// breadth first.
+func effectiveRoles(hierarchy map[string][]string, role string) []string {
+	roles := []string{role}
+	seen := map[string]bool{role: true}
+	for i := 0; i < len(roles); i++ {
+		for _, parent := range hierarchy[roles[i]] {
+			if !seen[parent] {
+				seen[parent] = true
+				roles = append(roles, parent)
+			}
+		}
+	}
+	return roles
+}
+
+func checkRoleCycles(hierarchy map[string][]string) error {
+	for role := range hierarchy {
+		for _, inherited := range effectiveRoles(hierarchy, role) {
+			for _, parent := range hierarchy[inherited] {
+				if parent == role {
+					return fmt.Errorf("role %s inherits from itself via %s", role, inherited)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// compilePolicies parses every condition, reporting the first invalid one.
+func compilePolicies(policies []Policy) ([]compiledPolicy, error) {
+	compiled := make([]compiledPolicy, 0, len(policies))
+	for i, p := range policies {
+		if p.ID == "" {
+			p.ID = fmt.Sprintf("#%d", i+1)
+		}
+		if p.Effect == "" {
+			p.Effect = EffectAllow
+		}
+		if p.Effect != EffectAllow && p.Effect != EffectDeny {
+			return nil, fmt.Errorf("policy %s: unknown effect %q", p.ID, p.Effect)
+		}
+		cond, err := compileExpr(p.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s (%s %s %s): %w", p.ID, p.SubjectRole, p.Action, p.ResourceType, err)
+		}
+		compiled = append(compiled, compiledPolicy{Policy: p, condition: cond})
+	}
+	return compiled, nil
+}
+
+type staticPolicySource PolicySet
+
+// NewStaticPolicySource serves a fixed policy set, e.g. DefaultPolicySet.
+func NewStaticPolicySource(set PolicySet) PolicySource {
+	return staticPolicySource(set)
+}
+
+func (s staticPolicySource) LoadPolicies(ctx context.Context) (PolicySet, error) {
+	return PolicySet(s), nil
+}
+
+// DefaultPolicySet is the built-in policy set, also shipped as
+// config/policies.yaml and seeded into the policies table.
+func DefaultPolicySet() PolicySet {
+	return PolicySet{
+		Roles: map[string][]string{"admin": {"user"}},
+		Policies: []Policy{
+			{ID: "admin-all", SubjectRole: "admin", Action: "*", ResourceType: "*"},
+			// Only admins may inspect policies and authorization decisions
+			{ID: "policy-admin-only", SubjectRole: "user", Action: "*", ResourceType: "policy", Condition: `subject.role != "admin"`, Effect: EffectDeny},
+			// Only admins may manage API keys
+			{ID: "api-key-admin-only", SubjectRole: "user", Action: "*", ResourceType: "api_key", Condition: `subject.role != "admin"`, Effect: EffectDeny},
+			// Only admins may impersonate, and administrators cannot be impersonated
+			{ID: "impersonation-admin-only", SubjectRole: "user", Action: "*", ResourceType: "impersonation", Condition: `subject.role != "admin" || resource.role == "admin"`, Effect: EffectDeny},
+			// Users may place orders, and only see and change their own
+			{ID: "user-create-order", SubjectRole: "user", Action: ActionCreate, ResourceType: "order"},
+			{ID: "order-owner-only", SubjectRole: "user", Action: "*", ResourceType: "order", Condition: `subject.role != "admin" && resource.owner_id != null && resource.owner_id != subject.id`, Effect: EffectDeny},
+			// User management policies
+			{ID: "user-read-users", SubjectRole: "user", Action: ActionRead, ResourceType: "user"},
+			// Example ABAC policy: Owner can update their own resource
+			{ID: "owner-update-customer", SubjectRole: "user", Action: ActionUpdate, ResourceType: "customer", Condition: "resource.owner_id == subject.id"},
+			// Common policy: Users can read everything
+			{ID: "user-read-all", SubjectRole: "user", Action: ActionRead, ResourceType: "*"},
+		},
+	}
+}
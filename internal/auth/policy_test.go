@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckRoleCycles(t *testing.T) {
+	tests := []struct {
+		name      string
+		hierarchy map[string][]string
+		wantErr   bool
+	}{
+		{"empty", nil, false},
+		{"chain", map[string][]string{"admin": {"editor"}, "editor": {"user"}}, false},
+		{"diamond", map[string][]string{"admin": {"editor", "support"}, "editor": {"user"}, "support": {"user"}}, false},
+		{"self", map[string][]string{"admin": {"admin"}}, true},
+		{"pair", map[string][]string{"admin": {"user"}, "user": {"admin"}}, true},
+		{"long", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"d"}, "d": {"b"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRoleCycles(tt.hierarchy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRoleCycles() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEffectiveRoles(t *testing.T) {
+	hierarchy := map[string][]string{"admin": {"editor", "support"}, "editor": {"user"}, "support": {"user"}}
+	got := effectiveRoles(hierarchy, "admin")
+	want := []string{"admin", "editor", "support", "user"}
+	if len(got) != len(want) {
+		t.Fatalf("effectiveRoles() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("effectiveRoles() = %v, want %v", got, want)
+		}
+	}
+}
+
+// newPolicyService returns a service that only evaluates policies.
+func newPolicyService(t *testing.T, set PolicySet) *service {
+	t.Helper()
+	engine, err := NewPolicyEngine(context.Background(), NewStaticPolicySource(set), 0)
+	if err != nil {
+		t.Fatalf("NewPolicyEngine: %v", err)
+	}
+	return &service{policies: engine}
+}
+
+func TestExplain(t *testing.T) {
+	svc := newPolicyService(t, PolicySet{
+		Roles: map[string][]string{"manager": {"user"}},
+		Policies: []Policy{
+			{ID: "user-read", SubjectRole: "user", Action: ActionRead, ResourceType: "order"},
+			{ID: "user-update-own", SubjectRole: "user", Action: ActionUpdate, ResourceType: "order", Condition: "resource.owner_id == subject.id"},
+			{ID: "deny-locked", SubjectRole: "user", Action: "*", ResourceType: "order", Condition: "resource.locked == true", Effect: EffectDeny},
+			{ID: "manager-update", SubjectRole: "manager", Action: ActionUpdate, ResourceType: "order"},
+			{ID: "deny-contractors", SubjectRole: "manager", Action: ActionUpdate, ResourceType: "order", Condition: `subject.kind == "contractor"`, Effect: EffectDeny},
+		},
+	})
+
+	user := Subject{ID: "u1", Role: "user"}
+	manager := Subject{ID: "m1", Role: "manager"}
+	contractor := Subject{ID: "m2", Role: "manager", Attributes: map[string]interface{}{"kind": "contractor"}}
+	own := Resource{Type: "order", Attributes: map[string]interface{}{"owner_id": "u1"}}
+	other := Resource{Type: "order", Attributes: map[string]interface{}{"owner_id": "u2"}}
+	locked := Resource{Type: "order", Attributes: map[string]interface{}{"owner_id": "u1", "locked": true}}
+
+	tests := []struct {
+		name    string
+		sub     Subject
+		act     Action
+		res     Resource
+		allowed bool
+		policy  string
+	}{
+		{"allow", user, ActionRead, other, true, "user-read"},
+		{"condition holds", user, ActionUpdate, own, true, "user-update-own"},
+		{"condition fails", user, ActionUpdate, other, false, ""},
+		{"no policy for action", user, ActionDelete, own, false, ""},
+		{"no policy for resource type", user, ActionRead, Resource{Type: "product"}, false, ""},
+		{"deny overrides allow", user, ActionRead, locked, false, "deny-locked"},
+		{"deny overrides matching condition", user, ActionUpdate, locked, false, "deny-locked"},
+		{"inherited allow", manager, ActionRead, other, true, "user-read"},
+		{"own allow", manager, ActionUpdate, other, true, "manager-update"},
+		{"inherited deny overrides own allow", manager, ActionUpdate, locked, false, "deny-locked"},
+		{"own deny overrides own allow", contractor, ActionUpdate, other, false, "deny-contractors"},
+		{"api key outside scopes", Subject{ID: "s1", Role: "user", APIKeyID: "k1", Scopes: []string{"product:read"}}, ActionRead, other, false, ""},
+		{"api key within scopes", Subject{ID: "s1", Role: "user", APIKeyID: "k1", Scopes: []string{"order:*"}}, ActionRead, other, true, "user-read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := svc.Explain(context.Background(), tt.sub, tt.act, tt.res)
+			if err != nil {
+				t.Fatalf("Explain: %v", err)
+			}
+			if d.Allowed != tt.allowed {
+				t.Fatalf("Allowed = %v, want %v (%s)", d.Allowed, tt.allowed, d.Reason)
+			}
+			var policy string
+			if d.DecidingPolicy != nil {
+				policy = d.DecidingPolicy.ID
+			}
+			if policy != tt.policy {
+				t.Fatalf("DecidingPolicy = %q, want %q (%s)", policy, tt.policy, d.Reason)
+			}
+			if d.Reason == "" {
+				t.Fatal("Reason is empty")
+			}
+
+			allowed, err := svc.Authorize(context.Background(), tt.sub, tt.act, tt.res)
+			if err != nil || allowed != tt.allowed {
+				t.Fatalf("Authorize = %v, %v; want %v", allowed, err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestPolicyEngineRejectsInvalidSets(t *testing.T) {
+	tests := []struct {
+		name string
+		set  PolicySet
+	}{
+		{"bad condition", PolicySet{Policies: []Policy{{SubjectRole: "user", Action: ActionRead, ResourceType: "order", Condition: "resource.owner_id =="}}}},
+		{"bad effect", PolicySet{Policies: []Policy{{SubjectRole: "user", Action: ActionRead, ResourceType: "order", Effect: "maybe"}}}},
+		{"role cycle", PolicySet{Roles: map[string][]string{"a": {"b"}, "b": {"a"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPolicyEngine(context.Background(), NewStaticPolicySource(tt.set), 0); err == nil {
+				t.Fatal("NewPolicyEngine succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestExplainRequiresMFAForDeleteEverything(t *testing.T) {
+	svc := newPolicyService(t, PolicySet{
+		Roles: map[string][]string{"owner": {"admin"}},
+		Policies: []Policy{
+			{ID: "admin-all", SubjectRole: "admin", Action: "*", ResourceType: "*"},
+			{ID: "user-read", SubjectRole: "user", Action: ActionRead, ResourceType: "order"},
+			{ID: "support-orders", SubjectRole: "support", Action: "*", ResourceType: "order"},
+			{ID: "auditor-deny", SubjectRole: "auditor", Action: ActionDelete, ResourceType: "*", Effect: EffectDeny},
+			{ID: "auditor-read", SubjectRole: "auditor", Action: ActionRead, ResourceType: "*"},
+		},
+	})
+	order := Resource{Type: "order", ID: "o1"}
+
+	tests := []struct {
+		name    string
+		sub     Subject
+		act     Action
+		allowed bool
+		gated   bool
+	}{
+		{"admin without mfa", Subject{ID: "a1", Role: "admin"}, ActionRead, false, true},
+		{"admin with mfa", Subject{ID: "a1", Role: "admin", MFA: true}, ActionDelete, true, false},
+		{"admin api key", Subject{ID: "a1", Role: "admin", APIKeyID: "k1", Scopes: []string{"order:*"}}, ActionDelete, true, false},
+		{"inherited admin without mfa", Subject{ID: "o1", Role: "owner"}, ActionRead, false, true},
+		{"user without mfa", Subject{ID: "u1", Role: "user"}, ActionRead, true, false},
+		{"delete on one resource type", Subject{ID: "s1", Role: "support"}, ActionDelete, true, false},
+		{"deny on every resource type", Subject{ID: "r1", Role: "auditor"}, ActionRead, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := svc.Explain(context.Background(), tt.sub, tt.act, order)
+			if err != nil {
+				t.Fatalf("Explain: %v", err)
+			}
+			if d.Allowed != tt.allowed {
+				t.Fatalf("Allowed = %v, want %v (%s)", d.Allowed, tt.allowed, d.Reason)
+			}
+			if gated := strings.Contains(d.Reason, "multi-factor"); gated != tt.gated {
+				t.Fatalf("Reason = %q, want MFA requirement %v", d.Reason, tt.gated)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"hex-postgres-grpc/internal/common/apperr"
+	"hex-postgres-grpc/internal/common/tenant"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PasswordResetTTL     = time.Hour
+	EmailVerificationTTL = 48 * time.Hour
+
+	// passwordResetTimeout bounds the background work of a reset request.
+	passwordResetTimeout = time.Minute
+)
+
+var ErrInvalidUserToken = apperr.New(apperr.InvalidArgument, "INVALID_USER_TOKEN", "invalid or expired token")
+
+// RequestPasswordReset emails a reset token to the user with the given
+// address. It always returns nil and does the same work on the request path
+// whether or not such a user exists: the token and the email are created in
+// the background, so neither errors nor response times reveal registered
+// addresses.
+func (s *service) RequestPasswordReset(ctx context.Context, email string) error {
+	u, err := s.repo.GetByEmail(ctx, email)
+	if err != nil || u.ServiceAccount || u.Disabled {
+		return nil
+	}
+
+	// The background work keeps the tenant of the request but must not be
+	// cancelled when the response has been written.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), passwordResetTimeout)
+	go func() {
+		defer cancel()
+		s.sendPasswordReset(ctx, u)
+	}()
+	return nil
+}
+
+func (s *service) sendPasswordReset(ctx context.Context, u *User) {
+	// Only the most recent reset link works.
+	if err := s.userTokens.InvalidateUser(ctx, u.ID, PurposePasswordReset); err != nil {
+		log.Printf("auth: invalidate reset tokens for user %s: %v", u.ID, err)
+		return
+	}
+	raw, err := s.newUserToken(ctx, u, PurposePasswordReset, PasswordResetTTL)
+	if err != nil {
+		log.Printf("auth: create reset token for user %s: %v", u.ID, err)
+		return
+	}
+
+	s.sendMail(ctx, Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("A password reset was requested for %s.\n\n%s\n\nThe link expires in %s. If you did not request it, ignore this email.\n",
+			u.Username, s.link("reset-password", raw), PasswordResetTTL),
+	})
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset.
+// Every session of the user is revoked and any login lockout is lifted.
+func (s *service) ResetPassword(ctx context.Context, token, password string) error {
+	if password == "" {
+		return ErrPasswordRequired
+	}
+
+	t, err := s.userTokens.Consume(ctx, hashToken(token), PurposePasswordReset)
+	if err != nil {
+		return ErrInvalidUserToken
+	}
+	ctx = tenant.WithID(ctx, t.TenantID)
+
+	u, err := s.repo.GetByID(ctx, t.UserID)
+	if err != nil {
+		return ErrInvalidUserToken
+	}
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = hash
+	// The reset link reached this address, which proves the user owns it.
+	if u.Email == t.Email {
+		u.EmailVerified = true
+	}
+	if err := s.repo.Update(ctx, u); err != nil {
+		return err
+	}
+
+	if err := s.refresh.RevokeUser(ctx, u.ID); err != nil {
+		return err
+	}
+	s.resetLoginFailures(ctx, u.Username)
+	return nil
+}
+
+// VerifyEmail marks the address a verification token was sent to as
+// verified, provided it is still the user's address.
+func (s *service) VerifyEmail(ctx context.Context, token string) error {
+	t, err := s.userTokens.Consume(ctx, hashToken(token), PurposeEmailVerification)
+	if err != nil {
+		return ErrInvalidUserToken
+	}
+	ctx = tenant.WithID(ctx, t.TenantID)
+
+	u, err := s.repo.GetByID(ctx, t.UserID)
+	if err != nil || u.Email != t.Email {
+		return ErrInvalidUserToken
+	}
+	u.EmailVerified = true
+	return s.repo.Update(ctx, u)
+}
+
+// sendVerificationEmail asks the user to confirm their current address.
+// Failures are logged: the user can still be created or updated.
+func (s *service) sendVerificationEmail(ctx context.Context, u *User) {
+	if err := s.userTokens.InvalidateUser(ctx, u.ID, PurposeEmailVerification); err != nil {
+		log.Printf("auth: invalidate verification tokens for user %s: %v", u.ID, err)
+		return
+	}
+	raw, err := s.newUserToken(ctx, u, PurposeEmailVerification, EmailVerificationTTL)
+	if err != nil {
+		log.Printf("auth: create verification token for user %s: %v", u.ID, err)
+		return
+	}
+
+	s.sendMail(ctx, Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm that %s is the email address of %s.\n\n%s\n\nThe link expires in %s.\n",
+			u.Email, u.Username, s.link("verify-email", raw), EmailVerificationTTL),
+	})
+}
+
+func (s *service) newUserToken(ctx context.Context, u *User, purpose UserTokenPurpose, ttl time.Duration) (string, error) {
+	raw, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if err := s.userTokens.Create(ctx, &UserToken{
+		ID:        uuid.NewString(),
+		UserID:    u.ID,
+		Purpose:   purpose,
+		Hash:      hashToken(raw),
+		Email:     u.Email,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (s *service) sendMail(ctx context.Context, msg Message) {
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		log.Printf("auth: send %q to %s: %v", msg.Subject, msg.To, err)
+	}
+}
+
+// link points at the page of the client application that completes a flow,
+// or is just the token when no application URL is configured.
+func (s *service) link(page, token string) string {
+	if s.appURL == "" {
+		return "Token: " + token
+	}
+	return fmt.Sprintf("%s/%s?token=%s", s.appURL, page, url.QueryEscape(token))
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestPasswordReset(t *testing.T) {
+	users := []User{
+		{ID: "u1", Username: "ann", Email: "ann@example.com", Role: "user"},
+		{ID: "u2", Username: "bob", Email: "bob@example.com", Role: "user", Disabled: true},
+		{ID: "u3", Username: "ci", Email: "ci@example.com", Role: "user", ServiceAccount: true},
+	}
+	tests := []struct {
+		name      string
+		email     string
+		tokensErr error
+		mailed    bool
+	}{
+		{"existing user", "ann@example.com", nil, true},
+		{"unknown address", "nobody@example.com", nil, false},
+		{"disabled user", "bob@example.com", nil, false},
+		{"service account", "ci@example.com", nil, false},
+		{"token store fails", "ann@example.com", errors.New("db down"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := &memUserTokens{err: tt.tokensErr}
+			mailer := make(chanMailer)
+			svc := newTestService(t, ServiceConfig{
+				Users:      newMemUsers(users...),
+				UserTokens: tokens,
+				Mailer:     mailer,
+				AppURL:     "https://shop.example.com",
+			})
+
+			// The mailer blocks until the message is received below, so a
+			// nil result here shows that the request does not wait for it.
+			ctx, cancel := context.WithCancel(context.Background())
+			if err := svc.RequestPasswordReset(ctx, tt.email); err != nil {
+				t.Fatalf("RequestPasswordReset() = %v, want nil", err)
+			}
+			// Ending the request does not abort the email.
+			cancel()
+
+			select {
+			case msg := <-mailer:
+				if !tt.mailed {
+					t.Fatalf("unexpected email to %s", msg.To)
+				}
+				if msg.To != tt.email || !strings.Contains(msg.Body, "https://shop.example.com/reset-password?token=") {
+					t.Fatalf("email = %+v", msg)
+				}
+				if len(tokens.tokens) != 1 || tokens.tokens[0].Purpose != PurposePasswordReset {
+					t.Fatalf("stored tokens = %+v, want one reset token", tokens.tokens)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.mailed {
+					t.Fatal("no email sent")
+				}
+			}
+		})
+	}
+}
@@ -2,168 +2,308 @@ package auth
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"time"
 
+	"hex-postgres-grpc/internal/common/apperr"
+	"hex-postgres-grpc/internal/common/tenant"
+	"hex-postgres-grpc/internal/common/uow"
+
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
-	ErrUnauthorized = errors.New("unauthorized")
-	ErrInvalidToken = errors.New("invalid token")
+	// ErrUnauthorized means that the caller is not allowed to do what it
+	// asked for.
+	ErrUnauthorized       = apperr.New(apperr.PermissionDenied, "UNAUTHORIZED", "unauthorized")
+	ErrInvalidCredentials = apperr.New(apperr.Unauthenticated, "INVALID_CREDENTIALS", "invalid username or password")
+	ErrInvalidToken       = apperr.New(apperr.Unauthenticated, "INVALID_TOKEN", "invalid token")
+	ErrTokenReused        = apperr.New(apperr.Unauthenticated, "TOKEN_REUSED", "refresh token reuse detected")
+	ErrUserNotFound       = apperr.New(apperr.NotFound, "USER_NOT_FOUND", "user not found")
+	ErrAccountDisabled    = apperr.New(apperr.PermissionDenied, "ACCOUNT_DISABLED", "account is disabled")
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
 )
 
 type service struct {
-	secretKey []byte
-	policies  []Policy
-	repo      UserRepository
-}
-
-func NewService(secretKey string, repo UserRepository) Service {
-	s := &service{
-		secretKey: []byte(secretKey),
-		repo:      repo,
-	}
-	s.initPolicies()
-	return s
-}
-
-func (s *service) initPolicies() {
-	s.policies = []Policy{
-		{
-			SubjectRole:  "admin",
-			Action:       ActionCreate,
-			ResourceType: "*",
-			Condition: func(sub Subject, res Resource) bool {
-				return true
-			},
-		},
-		{
-			SubjectRole:  "admin",
-			Action:       ActionRead,
-			ResourceType: "*",
-			Condition: func(sub Subject, res Resource) bool {
-				return true
-			},
-		},
-		{
-			SubjectRole:  "admin",
-			Action:       ActionUpdate,
-			ResourceType: "*",
-			Condition: func(sub Subject, res Resource) bool {
-				return true
-			},
-		},
-		{
-			SubjectRole:  "admin",
-			Action:       ActionDelete,
-			ResourceType: "*",
-			Condition: func(sub Subject, res Resource) bool {
-				return true
-			},
-		},
-		// User management policies
-		{
-			SubjectRole:  "user",
-			Action:       ActionRead,
-			ResourceType: "user",
-			Condition: func(sub Subject, res Resource) bool {
-				return true
-			},
-		},
-		// Example ABAC policy: Owner can update their own resource
-		{
-			SubjectRole:  "user",
-			Action:       ActionUpdate,
-			ResourceType: "customer",
-			Condition: func(sub Subject, res Resource) bool {
-				ownerID, ok := res.Attributes["owner_id"].(string)
-				return ok && ownerID == sub.ID
-			},
-		},
-		// Common policy: Users can read everything
-		{
-			SubjectRole:  "user",
-			Action:       ActionRead,
-			ResourceType: "*",
-			Condition: func(sub Subject, res Resource) bool {
-				return true
-			},
-		},
+	keys     *KeyManager
+	policies *PolicyEngine
+	repo     UserRepository
+	hasher   PasswordHasher
+	refresh  RefreshTokenRepository
+	apiKeys  APIKeyRepository
+	methods  MethodPermissions
+
+	identities IdentityRepository
+	claims     ClaimMapping
+
+	mfa       MFARepository
+	mfaIssuer string
+	// now is the clock TOTP codes are checked against.
+	now func() time.Time
+
+	attempts      LoginAttemptRepository
+	userLockout   LockoutPolicy
+	ipLockout     LockoutPolicy
+	dummyHashOnce sync.Once
+	dummyHash     string
+
+	userTokens UserTokenRepository
+	mailer     Mailer
+	appURL     string
+
+	impersonations ImpersonationRepository
+
+	tx uow.UnitOfWork
+}
+
+// ServiceConfig lists the collaborators of the auth service.
+type ServiceConfig struct {
+	Keys          *KeyManager
+	Policies      *PolicyEngine
+	Users         UserRepository
+	Hasher        PasswordHasher
+	RefreshTokens RefreshTokenRepository
+	APIKeys       APIKeyRepository
+	// Methods maps every gRPC method to the permission it requires.
+	Methods MethodPermissions
+	// Identities and ClaimMapping are used to sign in users authenticated by
+	// an external identity provider.
+	Identities   IdentityRepository
+	ClaimMapping ClaimMapping
+	// MFA stores TOTP enrollments; MFAIssuer names the service in
+	// authenticator apps.
+	MFA       MFARepository
+	MFAIssuer string
+	// LoginAttempts tracks failed logins. Zero lockout policies fall back to
+	// DefaultUserLockout and DefaultIPLockout.
+	LoginAttempts LoginAttemptRepository
+	UserLockout   LockoutPolicy
+	IPLockout     LockoutPolicy
+	// UserTokens and Mailer deliver password reset and email verification
+	// tokens. AppURL is the base URL of the client application that links in
+	// those emails point to; without it the emails contain the bare token.
+	UserTokens UserTokenRepository
+	Mailer     Mailer
+	AppURL     string
+	// Impersonations records the sessions in which administrators act as
+	// other users.
+	Impersonations ImpersonationRepository
+	// UnitOfWork groups writes to several repositories that must succeed or
+	// fail together.
+	UnitOfWork uow.UnitOfWork
+}
+
+func NewService(cfg ServiceConfig) Service {
+	if cfg.UserLockout.Threshold == 0 {
+		cfg.UserLockout = DefaultUserLockout()
+	}
+	if cfg.IPLockout.Threshold == 0 {
+		cfg.IPLockout = DefaultIPLockout()
+	}
+	return &service{
+		keys:     cfg.Keys,
+		policies: cfg.Policies,
+		repo:     cfg.Users,
+		hasher:   cfg.Hasher,
+		refresh:  cfg.RefreshTokens,
+		apiKeys:  cfg.APIKeys,
+		methods:  cfg.Methods,
+
+		identities: cfg.Identities,
+		claims:     cfg.ClaimMapping,
+
+		mfa:       cfg.MFA,
+		mfaIssuer: cfg.MFAIssuer,
+		now:       time.Now,
+
+		attempts:    cfg.LoginAttempts,
+		userLockout: cfg.UserLockout,
+		ipLockout:   cfg.IPLockout,
+
+		userTokens: cfg.UserTokens,
+		mailer:     cfg.Mailer,
+		appURL:     strings.TrimRight(cfg.AppURL, "/"),
+
+		impersonations: cfg.Impersonations,
+
+		tx: cfg.UnitOfWork,
 	}
 }
 
 func (s *service) Authorize(ctx context.Context, sub Subject, act Action, res Resource) (bool, error) {
-	for _, p := range s.policies {
-		if p.SubjectRole == sub.Role && (p.ResourceType == "*" || p.ResourceType == res.Type) && (p.Action == act) {
-			if p.Condition(sub, res) {
-				return true, nil
-			}
-		}
+	d, err := s.Explain(ctx, sub, act, res)
+	if err != nil {
+		return false, err
 	}
-	return false, nil
+	return d.Allowed, nil
 }
 
 func (s *service) GenerateToken(ctx context.Context, sub Subject) (string, error) {
+	return s.signToken(sub, time.Now().Add(AccessTokenTTL))
+}
+
+func (s *service) signToken(sub Subject, expiresAt time.Time) (string, error) {
+	key, err := s.keys.signingKey()
+	if err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{
 		"sub":  sub.ID,
+		"tid":  sub.TenantID,
 		"role": sub.Role,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
+		"iat":  time.Now().Unix(),
+		"exp":  expiresAt.Unix(),
 		"attr": sub.Attributes,
 	}
+	if sub.MFA {
+		claims["amr"] = []string{"mfa"}
+	}
+	// Impersonation tokens name the administrator in the "act" claim of
+	// RFC 8693 and the session in "imp".
+	if sub.ActorID != "" {
+		claims["act"] = map[string]interface{}{"sub": sub.ActorID}
+		claims["imp"] = sub.ImpersonationID
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secretKey)
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
 }
 
 func (s *service) ValidateToken(ctx context.Context, tokenStr string) (Subject, error) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys.verificationKey(kid)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		if token.Method.Alg() != key.Algorithm {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return s.secretKey, nil
-	})
+		return key.PrivateKey.Public(), nil
+	}, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
 
 	if err != nil {
 		return Subject{}, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		subID, _ := claims["sub"].(string)
-		role, _ := claims["role"].(string)
-		attrs, _ := claims["attr"].(map[string]interface{})
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return Subject{}, ErrInvalidToken
+	}
+	subID, _ := claims["sub"].(string)
+	tenantID, _ := claims["tid"].(string)
+	if tenantID == "" {
+		tenantID = tenant.Default
+	}
+	amr, _ := claims["amr"].([]interface{})
+	act, _ := claims["act"].(map[string]interface{})
+	actorID, _ := act["sub"].(string)
+	impersonationID, _ := claims["imp"].(string)
 
-		return Subject{
-			ID:         subID,
-			Role:       role,
-			Attributes: attrs,
-		}, nil
+	// The account is checked on every request so that disabling or deleting
+	// it takes effect before the token expires. Role and attributes are
+	// taken from the account as well, so changes to them apply immediately.
+	ctx = tenant.WithID(ctx, tenantID)
+	u, err := s.activeUser(ctx, subID)
+	if err != nil {
+		return Subject{}, err
+	}
+	if actorID != "" || impersonationID != "" {
+		if err := s.checkImpersonation(ctx, impersonationID, u.ID, actorID); err != nil {
+			return Subject{}, err
+		}
 	}
 
-	return Subject{}, ErrInvalidToken
+	return Subject{
+		ID:              u.ID,
+		TenantID:        u.TenantID,
+		Username:        u.Username,
+		Role:            u.Role,
+		Attributes:      u.Attributes,
+		MFA:             claimMatches(amr, "mfa"),
+		ActorID:         actorID,
+		ImpersonationID: impersonationID,
+	}, nil
 }
 
-func (s *service) Login(ctx context.Context, username, password string) (string, error) {
-	u, err := s.repo.GetByUsername(ctx, username)
+// activeUser returns the user unless it is deleted or disabled.
+func (s *service) activeUser(ctx context.Context, id string) (*User, error) {
+	u, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		return "", ErrUnauthorized
+		return nil, ErrInvalidToken
+	}
+	if u.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	return u, nil
+}
+
+// Login refuses locked-out, unknown and wrong-password attempts alike with
+// ErrInvalidCredentials, taking about the same time for each, so that responses do
+// not reveal which usernames exist.
+func (s *service) Login(ctx context.Context, username, password string) (LoginResult, error) {
+	locked, err := s.loginLocked(ctx, username)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if locked {
+		s.equalizeTiming(password)
+		return LoginResult{}, ErrInvalidCredentials
+	}
+
+	u, err := s.repo.GetByUsername(ctx, username)
+	if err != nil || u.ServiceAccount {
+		s.equalizeTiming(password)
+		s.recordLoginFailure(ctx, username)
+		return LoginResult{}, ErrInvalidCredentials
+	}
+
+	if err := s.hasher.Compare(u.PasswordHash, password); err != nil {
+		s.recordLoginFailure(ctx, username)
+		return LoginResult{}, ErrInvalidCredentials
+	}
+	if u.Disabled {
+		return LoginResult{}, ErrInvalidCredentials
 	}
 
-	// In a real app, use bcrypt.CompareHashAndPassword here.
-	// For this demonstration, we'll assume the password matches if it's the same as the hash (as seeded).
-	if u.PasswordHash != password {
-		return "", ErrUnauthorized
+	// Transparently upgrade hashes created with outdated cost parameters.
+	if s.hasher.NeedsRehash(u.PasswordHash) {
+		if hash, err := s.hasher.Hash(password); err == nil {
+			u.PasswordHash = hash
+			if err := s.repo.Update(ctx, u); err != nil {
+				log.Printf("auth: rehash password for user %s: %v", u.ID, err)
+			}
+		}
 	}
 
-	sub := Subject{
-		ID:         u.ID,
-		Username:   u.Username,
-		Role:       u.Role,
-		Attributes: u.Attributes,
+	enrollment, err := s.mfa.Get(ctx, u.ID)
+	if err != nil && err != ErrMFANotEnrolled {
+		return LoginResult{}, err
+	}
+	if enrollment != nil && enrollment.Enabled {
+		challenge, err := s.newLoginChallenge(ctx, u.ID)
+		if err != nil {
+			return LoginResult{}, err
+		}
+		return LoginResult{Challenge: challenge}, nil
 	}
 
-	return s.GenerateToken(ctx, sub)
+	s.resetLoginFailures(ctx, u.Username)
+	tokens, err := s.issueTokenPair(ctx, u, uuid.NewString(), false)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{Tokens: &tokens}, nil
 }
 
 func (s *service) CreateUser(ctx context.Context, sub Subject, user *User) error {
@@ -175,7 +315,33 @@ func (s *service) CreateUser(ctx context.Context, sub Subject, user *User) error
 		return ErrUnauthorized
 	}
 
-	return s.repo.Create(ctx, user)
+	if user.ID == "" {
+		user.ID = uuid.NewString()
+	}
+	// Addresses are verified through the emailed link only.
+	user.EmailVerified = false
+
+	if user.ServiceAccount {
+		if user.Password != "" {
+			return ErrServiceAccountAuth
+		}
+		user.PasswordHash = ""
+	} else {
+		hash, err := s.hasher.Hash(user.Password)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hash
+		user.Password = ""
+	}
+
+	if err := s.repo.Create(ctx, user); err != nil {
+		return err
+	}
+	if user.Email != "" {
+		s.sendVerificationEmail(ctx, user)
+	}
+	return nil
 }
 
 func (s *service) UpdateUser(ctx context.Context, sub Subject, user *User) error {
@@ -187,7 +353,43 @@ func (s *service) UpdateUser(ctx context.Context, sub Subject, user *User) error
 		return ErrUnauthorized
 	}
 
-	return s.repo.Update(ctx, user)
+	existing, err := s.repo.GetByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// Whether a user is a service account is fixed when it is created.
+	user.ServiceAccount = existing.ServiceAccount
+
+	// Never store a client-supplied hash: keep the current one unless a new
+	// password was provided.
+	if user.ServiceAccount && user.Password != "" {
+		return ErrServiceAccountAuth
+	}
+	if user.Password == "" {
+		user.PasswordHash = existing.PasswordHash
+	} else {
+		hash, err := s.hasher.Hash(user.Password)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hash
+		user.Password = ""
+	}
+
+	emailChanged := !strings.EqualFold(user.Email, existing.Email)
+	user.EmailVerified = existing.EmailVerified && !emailChanged
+	// Activation has its own endpoints; a profile update never flips it.
+	user.Disabled = existing.Disabled
+	user.CreatedAt = existing.CreatedAt
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		return err
+	}
+	if emailChanged && user.Email != "" {
+		s.sendVerificationEmail(ctx, user)
+	}
+	return nil
 }
 
 func (s *service) GetUser(ctx context.Context, sub Subject, id string) (*User, error) {
@@ -213,3 +415,100 @@ func (s *service) ListUsers(ctx context.Context, sub Subject) ([]*User, error) {
 
 	return s.repo.List(ctx)
 }
+
+// DeleteUser soft-deletes a user and signs out all of its sessions.
+func (s *service) DeleteUser(ctx context.Context, sub Subject, id string) error {
+	authorized, err := s.Authorize(ctx, sub, ActionDelete, Resource{Type: "user", ID: id})
+	if err != nil {
+		return err
+	}
+	if !authorized {
+		return ErrUnauthorized
+	}
+
+	return s.tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.refresh.RevokeUser(ctx, id)
+	})
+}
+
+// SetUserDisabled deactivates or reactivates a user. Deactivation also signs
+// out all of the user's sessions.
+func (s *service) SetUserDisabled(ctx context.Context, sub Subject, id string, disabled bool) error {
+	authorized, err := s.Authorize(ctx, sub, ActionUpdate, Resource{Type: "user", ID: id})
+	if err != nil {
+		return err
+	}
+	if !authorized {
+		return ErrUnauthorized
+	}
+
+	return s.tx.Do(ctx, func(ctx context.Context) error {
+		u, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		u.Disabled = disabled
+		if err := s.repo.Update(ctx, u); err != nil {
+			return err
+		}
+		if disabled {
+			return s.refresh.RevokeUser(ctx, id)
+		}
+		return nil
+	})
+}
+
+func (s *service) GetMe(ctx context.Context, sub Subject) (*User, error) {
+	if sub.ID == "" {
+		return nil, ErrUnauthorized
+	}
+	return s.repo.GetByID(ctx, sub.ID)
+}
+
+// UpdateMe applies a self-service profile change. Role, attributes and other
+// fields that drive authorization can only be changed by UpdateUser. An
+// impersonating administrator cannot change the user's credentials.
+func (s *service) UpdateMe(ctx context.Context, sub Subject, update ProfileUpdate) (*User, error) {
+	if sub.ID == "" || sub.APIKeyID != "" {
+		return nil, ErrUnauthorized
+	}
+	if sub.ActorID != "" {
+		return nil, ErrImpersonationNotAllowed
+	}
+
+	u, err := s.repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Password != nil {
+		if u.ServiceAccount {
+			return nil, ErrServiceAccountAuth
+		}
+		if err := s.hasher.Compare(u.PasswordHash, update.CurrentPassword); err != nil {
+			return nil, ErrUnauthorized
+		}
+		hash, err := s.hasher.Hash(*update.Password)
+		if err != nil {
+			return nil, err
+		}
+		u.PasswordHash = hash
+	}
+
+	emailChanged := update.Email != nil && !strings.EqualFold(*update.Email, u.Email)
+	if emailChanged {
+		u.Email = *update.Email
+		u.EmailVerified = false
+	}
+
+	if err := s.repo.Update(ctx, u); err != nil {
+		return nil, err
+	}
+	if emailChanged && u.Email != "" {
+		s.sendVerificationEmail(ctx, u)
+	}
+	return u, nil
+}
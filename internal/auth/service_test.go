@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUpdateUserKeepsServiceAccount(t *testing.T) {
+	admin := Subject{ID: "admin", Role: "admin", MFA: true}
+	tests := []struct {
+		name     string
+		existing User
+		update   User
+	}{
+		{"service account", User{ID: "u1", Username: "robot", Role: "user", ServiceAccount: true}, User{ID: "u1", Username: "robot", Role: "user"}},
+		{"user", User{ID: "u1", Username: "ann", Role: "user", PasswordHash: "hash"}, User{ID: "u1", Username: "ann", Role: "user", ServiceAccount: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newMemUsers(tt.existing)
+			svc := newTestService(t, ServiceConfig{Users: users})
+
+			update := tt.update
+			if err := svc.UpdateUser(context.Background(), admin, &update); err != nil {
+				t.Fatalf("UpdateUser: %v", err)
+			}
+			got, err := users.GetByID(context.Background(), tt.existing.ID)
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if got.ServiceAccount != tt.existing.ServiceAccount || got.PasswordHash != tt.existing.PasswordHash {
+				t.Fatalf("stored user = %+v, want service account %v and password hash %q", got, tt.existing.ServiceAccount, tt.existing.PasswordHash)
+			}
+		})
+	}
+}
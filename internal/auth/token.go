@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"hex-postgres-grpc/internal/common/tenant"
+
+	"github.com/google/uuid"
+)
+
+// issueTokenPair signs an access token for u and stores a new refresh token in
+// the given family. mfa records whether the login used a second factor.
+func (s *service) issueTokenPair(ctx context.Context, u *User, familyID string, mfa bool) (TokenPair, error) {
+	rt, raw, err := newRefreshToken(u.ID, familyID, mfa)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if err := s.refresh.Create(ctx, rt); err != nil {
+		return TokenPair{}, err
+	}
+	return s.tokenPair(ctx, u, raw, mfa)
+}
+
+func (s *service) tokenPair(ctx context.Context, u *User, refreshToken string, mfa bool) (TokenPair, error) {
+	access, err := s.GenerateToken(ctx, Subject{
+		ID:         u.ID,
+		TenantID:   u.TenantID,
+		Username:   u.Username,
+		Role:       u.Role,
+		Attributes: u.Attributes,
+		MFA:        mfa,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+func (s *service) JWKS(ctx context.Context) JWKSet {
+	return s.keys.JWKS()
+}
+
+func (s *service) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	current, err := s.refresh.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return TokenPair{}, ErrInvalidToken
+	}
+
+	// A revoked token being presented again means it leaked: kill the family.
+	if current.RevokedAt != nil {
+		if err := s.refresh.RevokeFamily(ctx, current.FamilyID); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrTokenReused
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return TokenPair{}, ErrInvalidToken
+	}
+
+	ctx = tenant.WithID(ctx, current.TenantID)
+	u, err := s.activeUser(ctx, current.UserID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	next, raw, err := newRefreshToken(u.ID, current.FamilyID, current.MFA)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	// The successor is only stored if current could be marked as rotated.
+	err = s.tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.refresh.Create(ctx, next); err != nil {
+			return err
+		}
+		rotated, err := s.refresh.MarkRotated(ctx, current.ID, next.ID)
+		if err != nil {
+			return err
+		}
+		if !rotated {
+			return ErrTokenReused
+		}
+		return nil
+	})
+	if err == ErrTokenReused {
+		// Lost a race against another use of the same token.
+		if err := s.refresh.RevokeFamily(ctx, current.FamilyID); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrTokenReused
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return s.tokenPair(ctx, u, raw, current.MFA)
+}
+
+func (s *service) Logout(ctx context.Context, refreshToken string) error {
+	current, err := s.refresh.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	return s.refresh.RevokeFamily(ctx, current.FamilyID)
+}
+
+func newRefreshToken(userID, familyID string, mfa bool) (*RefreshToken, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	return &RefreshToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(RefreshTokenTTL),
+		CreatedAt: now,
+		MFA:       mfa,
+	}, raw, nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
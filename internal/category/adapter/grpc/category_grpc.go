@@ -4,6 +4,7 @@ import (
 	"context"
 	"hex-postgres-grpc/internal/auth"
 	"hex-postgres-grpc/internal/category/domain"
+	domain_common "hex-postgres-grpc/internal/common/domain"
 	categorypb "hex-postgres-grpc/proto/category"
 
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -21,14 +22,10 @@ func NewCategoryGRPCServer(service domain.Service) *Server {
 }
 
 func (s *Server) CreateCategory(ctx context.Context, req *categorypb.CreateCategoryRequest) (*categorypb.CreateCategoryResponse, error) {
-	// For gRPC, we might need a generic user for now if interceptor isn't setting subject
+	// The auth interceptor only lets authenticated callers through.
 	sub, _ := auth.SubjectFromContext(ctx)
-	userID := sub.ID
-	if userID == "" {
-		userID = "grpc-system" // fallback
-	}
 
-	cat, err := s.service.CreateCategory(ctx, req.Name, userID)
+	cat, err := s.service.CreateCategory(ctx, req.Name, sub.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -43,6 +40,7 @@ func (s *Server) CreateCategory(ctx context.Context, req *categorypb.CreateCateg
 				}
 				return nil
 			}(),
+			Etag: cat.ETag(),
 		},
 	}, nil
 }
@@ -63,18 +61,20 @@ func (s *Server) GetCategory(ctx context.Context, req *categorypb.GetCategoryReq
 				}
 				return nil
 			}(),
+			Etag: cat.ETag(),
 		},
 	}, nil
 }
 
 func (s *Server) UpdateCategory(ctx context.Context, req *categorypb.UpdateCategoryRequest) (*categorypb.UpdateCategoryResponse, error) {
 	sub, _ := auth.SubjectFromContext(ctx)
-	userID := sub.ID
-	if userID == "" {
-		userID = "grpc-system"
+
+	version, err := domain_common.ParseETag(req.Etag)
+	if err != nil {
+		return nil, err
 	}
 
-	cat, err := s.service.UpdateCategory(ctx, req.Id, req.Name, userID)
+	cat, err := s.service.UpdateCategory(ctx, req.Id, req.Name, sub.ID, version)
 	if err != nil {
 		return nil, err
 	}
@@ -89,18 +89,15 @@ func (s *Server) UpdateCategory(ctx context.Context, req *categorypb.UpdateCateg
 				}
 				return nil
 			}(),
+			Etag: cat.ETag(),
 		},
 	}, nil
 }
 
 func (s *Server) DeleteCategory(ctx context.Context, req *categorypb.DeleteCategoryRequest) (*categorypb.DeleteCategoryResponse, error) {
 	sub, _ := auth.SubjectFromContext(ctx)
-	userID := sub.ID
-	if userID == "" {
-		userID = "grpc-system"
-	}
 
-	if err := s.service.DeleteCategory(ctx, req.Id, userID); err != nil {
+	if err := s.service.DeleteCategory(ctx, req.Id, sub.ID); err != nil {
 		return nil, err
 	}
 	return &categorypb.DeleteCategoryResponse{Success: true}, nil
@@ -124,6 +121,7 @@ func (s *Server) ListCategories(ctx context.Context, req *categorypb.ListCategor
 				}
 				return nil
 			}(),
+			Etag: cat.ETag(),
 		})
 	}
 
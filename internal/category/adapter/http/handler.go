@@ -6,6 +6,8 @@ import (
 
 	"hex-postgres-grpc/internal/auth"
 	"hex-postgres-grpc/internal/category/domain"
+	commonhttp "hex-postgres-grpc/internal/common/adapters/http"
+	domain_common "hex-postgres-grpc/internal/common/domain"
 )
 
 type Handler struct {
@@ -37,19 +39,20 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 // @Security BearerAuth
 // @Param request body struct{Name string `json:"name"`} true "Create Category Request"
 // @Success 200 {object} domain.Category
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
+// @Header 200 {string} ETag "Version of the category"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
 // @Router /categories [post]
 func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionCreate, auth.Resource{Type: "category"})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
@@ -57,15 +60,16 @@ func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 		Name string `json:"name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 	category, err := h.service.CreateCategory(r.Context(), req.Name, sub.ID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", category.ETag())
 	json.NewEncoder(w).Encode(category)
 }
 
@@ -77,58 +81,71 @@ func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param id path string true "Category ID"
 // @Success 200 {object} domain.Category
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
-// @Failure 404 {string} string "not found"
+// @Header 200 {string} ETag "Version of the category"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
 // @Router /categories/{id} [get]
 func (h *Handler) GetCategory(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionRead, auth.Resource{Type: "category", ID: id})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
 	cat, err := h.service.GetCategory(r.Context(), id)
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", cat.ETag())
 	json.NewEncoder(w).Encode(cat)
 }
 
 // UpdateCategory updates an existing category
 // @Summary Update Category
-// @Description Update the name of an existing category
+// @Description Update the name of an existing category. With If-Match the update only applies to that version of the category.
 // @Tags category
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Category ID"
+// @Param If-Match header string false "ETag of the version being updated"
 // @Param request body struct{Name string `json:"name"`} true "Update Category Request"
 // @Success 200 {object} domain.Category
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
-// @Failure 404 {string} string "not found"
+// @Header 200 {string} ETag "Version of the category"
+// @Failure 400 {object} commonhttp.Problem "invalid If-Match header"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Failure 409 {object} commonhttp.Problem "category was modified concurrently"
+// @Failure 412 {object} commonhttp.Problem "category has changed since the If-Match version"
 // @Router /categories/{id} [put]
 func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionUpdate, auth.Resource{Type: "category", ID: id})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	version, err := domain_common.ParseETag(r.Header.Get("If-Match"))
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
@@ -136,15 +153,16 @@ func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 		Name string `json:"name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	cat, err := h.service.UpdateCategory(r.Context(), id, req.Name, sub.ID)
+	cat, err := h.service.UpdateCategory(r.Context(), id, req.Name, sub.ID, version)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", cat.ETag())
 	json.NewEncoder(w).Encode(cat)
 }
 
@@ -155,26 +173,26 @@ func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param id path string true "Category ID"
 // @Success 204 "No Content"
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
 // @Router /categories/{id} [delete]
 func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionDelete, auth.Resource{Type: "category", ID: id})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
 	err = h.service.DeleteCategory(r.Context(), id, sub.ID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -187,25 +205,25 @@ func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {array} domain.Category
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
 // @Router /categories [get]
 func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionRead, auth.Resource{Type: "category"})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
 	cats, err := h.service.ListCategories(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
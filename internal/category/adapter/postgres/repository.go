@@ -3,27 +3,44 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 
 	"hex-postgres-grpc/internal/category/domain"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/tenant"
 )
 
 type CategoryRepoPG struct {
-	db *sql.DB
+	db *commonpg.DB
 }
 
 func NewRepository(db *sql.DB) *CategoryRepoPG {
-	return &CategoryRepoPG{db: db}
+	return &CategoryRepoPG{db: commonpg.Wrap(db)}
 }
 func (c *CategoryRepoPG) Save(ctx context.Context, p *domain.Category) error {
-	const q = `INSERT INTO category (id, name, created_at, updated_at, created_by, updated_by) VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := c.db.ExecContext(ctx, q, p.ID, p.Name, p.CreatedAt, p.UpdatedAt, p.CreatedBy, p.UpdatedBy)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+	p.TenantID = tenantID
+
+	const q = `INSERT INTO category (id, tenant_id, name, created_at, updated_at, created_by, updated_by, created_by_actor, updated_by_actor, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err = c.db.ExecContext(ctx, q, p.ID, p.TenantID, p.Name, p.CreatedAt, p.UpdatedAt, p.CreatedBy, p.UpdatedBy, p.CreatedByActor, p.UpdatedByActor, p.Version)
 	return err
 }
 
 func (c *CategoryRepoPG) FindByID(ctx context.Context, id string) (*domain.Category, error) {
-	const q = `SELECT id, name, created_at, updated_at, created_by, updated_by FROM category WHERE id = $1 AND deleted_at IS NULL`
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `SELECT id, tenant_id, name, created_at, updated_at, created_by, updated_by, created_by_actor, updated_by_actor, version FROM category WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL`
 	var cat domain.Category
-	err := c.db.QueryRowContext(ctx, q, id).Scan(&cat.ID, &cat.Name, &cat.CreatedAt, &cat.UpdatedAt, &cat.CreatedBy, &cat.UpdatedBy)
+	err = c.db.QueryRowContext(ctx, q, id, tenantID).Scan(&cat.ID, &cat.TenantID, &cat.Name, &cat.CreatedAt, &cat.UpdatedAt, &cat.CreatedBy, &cat.UpdatedBy, &cat.CreatedByActor, &cat.UpdatedByActor, &cat.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -31,20 +48,46 @@ func (c *CategoryRepoPG) FindByID(ctx context.Context, id string) (*domain.Categ
 }
 
 func (c *CategoryRepoPG) Update(ctx context.Context, p *domain.Category) error {
-	const q = `UPDATE category SET name = $1, updated_at = $2, updated_by = $3 WHERE id = $4 AND deleted_at IS NULL`
-	_, err := c.db.ExecContext(ctx, q, p.Name, p.UpdatedAt, p.UpdatedBy, p.ID)
-	return err
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE category SET name = $1, updated_at = $2, updated_by = $3, updated_by_actor = $4, version = version + 1 WHERE id = $5 AND tenant_id = $6 AND version = $7 AND deleted_at IS NULL`
+	res, err := c.db.ExecContext(ctx, q, p.Name, p.UpdatedAt, p.UpdatedBy, p.UpdatedByActor, p.ID, tenantID, p.Version)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrConflict
+	}
+	p.Version++
+	return nil
 }
 
 func (c *CategoryRepoPG) Delete(ctx context.Context, id string) error {
-	const q = `UPDATE category SET deleted_at = NOW() WHERE id = $1`
-	_, err := c.db.ExecContext(ctx, q, id)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE category SET deleted_at = NOW() WHERE id = $1 AND tenant_id = $2`
+	_, err = c.db.ExecContext(ctx, q, id, tenantID)
 	return err
 }
 
 func (c *CategoryRepoPG) FindAll(ctx context.Context) ([]*domain.Category, error) {
-	const q = `SELECT id, name, created_at, updated_at, created_by, updated_by FROM category WHERE deleted_at IS NULL`
-	rows, err := c.db.QueryContext(ctx, q)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `SELECT id, tenant_id, name, created_at, updated_at, created_by, updated_by, created_by_actor, updated_by_actor, version FROM category WHERE tenant_id = $1 AND deleted_at IS NULL`
+	rows, err := c.db.QueryContext(ctx, q, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +96,7 @@ func (c *CategoryRepoPG) FindAll(ctx context.Context) ([]*domain.Category, error
 	var categories []*domain.Category
 	for rows.Next() {
 		var cat domain.Category
-		if err := rows.Scan(&cat.ID, &cat.Name, &cat.CreatedAt, &cat.UpdatedAt, &cat.CreatedBy, &cat.UpdatedBy); err != nil {
+		if err := rows.Scan(&cat.ID, &cat.TenantID, &cat.Name, &cat.CreatedAt, &cat.UpdatedAt, &cat.CreatedBy, &cat.UpdatedBy, &cat.CreatedByActor, &cat.UpdatedByActor, &cat.Version); err != nil {
 			return nil, err
 		}
 		categories = append(categories, &cat)
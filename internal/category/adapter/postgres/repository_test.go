@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"hex-postgres-grpc/internal/category/domain"
+	"hex-postgres-grpc/internal/category/usecase"
+	"hex-postgres-grpc/internal/common/adapters/postgres/pgtest"
+	domain_common "hex-postgres-grpc/internal/common/domain"
+
+	"github.com/google/uuid"
+)
+
+const testUserID = "00000000-0000-0000-0000-000000000001"
+
+func TestRepositoryTenantIsolation(t *testing.T) {
+	repo := NewRepository(pgtest.Open(t))
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	c := &domain.Category{
+		BaseEntity: domain_common.BaseEntity{ID: uuid.NewString(), CreatedAt: time.Now(), CreatedBy: testUserID, Version: 1},
+		Name:       "Lighting",
+	}
+	if err := repo.Save(ctxA, c); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if _, err := repo.FindByID(ctxB, c.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("FindByID in other tenant: %v, want ErrNotFound", err)
+	}
+	list, err := repo.FindAll(ctxB)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("other tenant lists %d categories, want none", len(list))
+	}
+	changed := *c
+	changed.Name = "Stolen"
+	if err := repo.Update(ctxB, &changed); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("Update in other tenant: %v, want ErrConflict", err)
+	}
+	if err := repo.Delete(ctxB, c.ID); err != nil {
+		t.Fatalf("Delete in other tenant: %v", err)
+	}
+
+	got, err := repo.FindByID(ctxA, c.ID)
+	if err != nil {
+		t.Fatalf("FindByID in own tenant: %v", err)
+	}
+	if got.Name != "Lighting" || got.Version != 1 {
+		t.Fatalf("category changed by other tenant: %+v", got)
+	}
+	if list, err := repo.FindAll(ctxA); err != nil || len(list) != 1 {
+		t.Fatalf("own tenant lists %d categories (%v), want 1", len(list), err)
+	}
+}
+
+func TestServiceTenantIsolation(t *testing.T) {
+	svc := usecase.NewService(NewRepository(pgtest.Open(t)))
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	c, err := svc.CreateCategory(ctxA, "Lighting", testUserID)
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+
+	if _, err := svc.GetCategory(ctxB, c.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetCategory in other tenant: %v, want ErrNotFound", err)
+	}
+	list, err := svc.ListCategories(ctxB)
+	if err != nil {
+		t.Fatalf("ListCategories: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("other tenant lists %d categories, want none", len(list))
+	}
+	if _, err := svc.UpdateCategory(ctxB, c.ID, "Stolen", testUserID, 0); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("UpdateCategory in other tenant: %v, want ErrNotFound", err)
+	}
+	if err := svc.DeleteCategory(ctxB, c.ID, testUserID); err != nil {
+		t.Fatalf("DeleteCategory in other tenant: %v", err)
+	}
+
+	got, err := svc.GetCategory(ctxA, c.ID)
+	if err != nil {
+		t.Fatalf("GetCategory in own tenant: %v", err)
+	}
+	if got.Name != "Lighting" || got.Version != 1 {
+		t.Fatalf("category changed by other tenant: %+v", got)
+	}
+}
@@ -1,9 +1,20 @@
 package domain
 
 import (
+	"hex-postgres-grpc/internal/common/apperr"
 	"hex-postgres-grpc/internal/common/domain"
 )
 
+var ErrNotFound = apperr.New(apperr.NotFound, "CATEGORY_NOT_FOUND", "category not found")
+
+// ErrConflict is returned when a category was changed while an update was
+// being applied.
+var ErrConflict = apperr.New(apperr.Conflict, "CATEGORY_CONFLICT", "category was modified concurrently")
+
+// ErrVersionMismatch is returned when an update only applies to a version of
+// a category that is no longer current.
+var ErrVersionMismatch = apperr.New(apperr.PreconditionFailed, "CATEGORY_VERSION_MISMATCH", "category has changed since the given version")
+
 type Category struct {
 	domain.BaseEntity
 	Name string `json:"name"`
@@ -5,6 +5,8 @@ import "context"
 type Repository interface {
 	Save(ctx context.Context, category *Category) error
 	FindByID(ctx context.Context, id string) (*Category, error)
+	// Update stores category if the stored version still equals category.Version,
+	// and increments the version. It returns ErrConflict otherwise.
 	Update(ctx context.Context, category *Category) error
 	Delete(ctx context.Context, id string) error
 	FindAll(ctx context.Context) ([]*Category, error)
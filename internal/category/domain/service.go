@@ -5,7 +5,10 @@ import "context"
 type Service interface {
 	CreateCategory(ctx context.Context, name, userID string) (*Category, error)
 	GetCategory(ctx context.Context, id string) (*Category, error)
-	UpdateCategory(ctx context.Context, id, name, userID string) (*Category, error)
+	// UpdateCategory applies only to the given version of the category and
+	// returns ErrVersionMismatch if it is not current; 0 updates whatever
+	// version is current.
+	UpdateCategory(ctx context.Context, id, name, userID string, version int64) (*Category, error)
 	DeleteCategory(ctx context.Context, id, userID string) error
 	ListCategories(ctx context.Context) ([]*Category, error)
 }
@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"hex-postgres-grpc/internal/auth"
 	"hex-postgres-grpc/internal/category/domain"
 	domain_common "hex-postgres-grpc/internal/common/domain"
 	"time"
@@ -18,18 +19,22 @@ func NewService(repo domain.Repository) domain.Service {
 }
 
 func (s *service) CreateCategory(ctx context.Context, name, userID string) (*domain.Category, error) {
+	sub, _ := auth.SubjectFromContext(ctx)
 	id := uuid.NewString()
 	category := domain.Category{
 		BaseEntity: domain_common.BaseEntity{
-			ID:        id,
-			CreatedAt: time.Now(),
-			CreatedBy: userID,
+			ID:             id,
+			CreatedAt:      time.Now(),
+			CreatedBy:      userID,
+			CreatedByActor: sub.Actor(),
+			Version:        1,
 		},
 		Name: name,
 	}
 	now := time.Now()
 	category.UpdatedAt = &now
 	category.UpdatedBy = &userID
+	category.UpdatedByActor = sub.Actor()
 	err := s.repo.Save(ctx, &category)
 	if err != nil {
 		return nil, err
@@ -41,15 +46,20 @@ func (s *service) GetCategory(ctx context.Context, id string) (*domain.Category,
 	return s.repo.FindByID(ctx, id)
 }
 
-func (s *service) UpdateCategory(ctx context.Context, id, name, userID string) (*domain.Category, error) {
+func (s *service) UpdateCategory(ctx context.Context, id, name, userID string, version int64) (*domain.Category, error) {
 	category, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if version != 0 && category.Version != version {
+		return nil, domain.ErrVersionMismatch
+	}
+	sub, _ := auth.SubjectFromContext(ctx)
 	category.Name = name
 	now := time.Now()
 	category.UpdatedAt = &now
 	category.UpdatedBy = &userID
+	category.UpdatedByActor = sub.Actor()
 
 	err = s.repo.Update(ctx, category)
 	if err != nil {
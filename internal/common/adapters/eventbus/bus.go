@@ -0,0 +1,54 @@
+// Package eventbus provides an in-process events.EventPublisher: events are
+// handed to handlers subscribed in the same process.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"hex-postgres-grpc/internal/common/events"
+)
+
+// All subscribes a handler to every event type.
+const All = "*"
+
+type Handler func(ctx context.Context, e events.Event) error
+
+// Bus dispatches events to subscribed handlers. It implements
+// events.EventPublisher.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+var _ events.EventPublisher = (*Bus)(nil)
+
+func New() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe calls h for every event of eventType, or of any type for All.
+func (b *Bus) Subscribe(eventType string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+// Publish calls the handlers of e in the order they subscribed. All of them
+// run even when one fails; the event is then published again later, to all
+// handlers, so handlers must tolerate duplicates.
+func (b *Bus) Publish(ctx context.Context, e events.Event) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler(nil), b.handlers[e.Type]...), b.handlers[All]...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("handle %s: %w", e.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
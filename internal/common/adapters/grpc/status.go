@@ -0,0 +1,66 @@
+// Package grpc turns domain errors into gRPC statuses. Every status carries
+// an errdetails.ErrorInfo with the reason of the error, and InvalidArgument
+// statuses about a field carry an errdetails.BadRequest as well.
+package grpc
+
+import (
+	"log"
+
+	"hex-postgres-grpc/internal/common/apperr"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Domain is the ErrorInfo domain of all errors of the application.
+const Domain = "hex-postgres-grpc"
+
+var codesByKind = map[apperr.Kind]codes.Code{
+	apperr.Internal:           codes.Internal,
+	apperr.InvalidArgument:    codes.InvalidArgument,
+	apperr.NotFound:           codes.NotFound,
+	apperr.AlreadyExists:      codes.AlreadyExists,
+	apperr.Conflict:           codes.Aborted,
+	apperr.FailedPrecondition: codes.FailedPrecondition,
+	apperr.Unauthenticated:    codes.Unauthenticated,
+	apperr.PermissionDenied:   codes.PermissionDenied,
+	apperr.ResourceExhausted:  codes.ResourceExhausted,
+	apperr.PreconditionFailed: codes.Aborted,
+}
+
+// Code returns the gRPC code for the kind of err.
+func Code(err error) codes.Code {
+	return codesByKind[apperr.KindOf(err)]
+}
+
+// Error returns err as a gRPC status error. Errors that already are
+// statuses, and nil, are returned unchanged. The message of an internal
+// error is logged instead of being sent.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	e, ok := apperr.As(err)
+	if !ok || e.Kind == apperr.Internal {
+		log.Printf("grpc: %v", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	st := status.New(codesByKind[e.Kind], err.Error())
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: e.Reason, Domain: Domain}}
+	if e.Field != "" {
+		details = append(details, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: e.Field, Description: e.Message}},
+		})
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
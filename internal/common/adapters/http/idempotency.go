@@ -0,0 +1,136 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"hex-postgres-grpc/internal/common/idempotency"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed for a retried request.
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// replayedHeaders are the response headers stored with the body.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+type storedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// Idempotent returns a middleware that honours the Idempotency-Key header on
+// the given routes, written as "POST /orders" and matched exactly against
+// the method and path of requests. Successful responses are stored and
+// replayed to retries with the same key and body; other responses free the
+// key again. subjectID names the caller, to whom keys are scoped; anonymous
+// requests are passed on unchanged. The middleware must run after
+// authentication.
+func Idempotent(svc *idempotency.Service, subjectID func(ctx context.Context) string, routes ...string) func(http.Handler) http.Handler {
+	guarded := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		guarded[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			value := r.Header.Get(IdempotencyKeyHeader)
+			subject := subjectID(ctx)
+			if value == "" || subject == "" || !guarded[r.Method+" "+r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				WriteProblem(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key := idempotency.Key{SubjectID: subject, Value: value}
+			stored, err := svc.Begin(ctx, key, idempotency.Hash([]byte(r.Method+" "+r.URL.Path), body))
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			if stored != nil {
+				replay(w, r, stored)
+				return
+			}
+
+			rec := &recorder{header: http.Header{}, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.status >= 200 && rec.status < 300 {
+				resp := storedResponse{Status: rec.status, Header: http.Header{}, Body: rec.body.Bytes()}
+				for _, h := range replayedHeaders {
+					if v := rec.header.Values(h); len(v) > 0 {
+						resp.Header[http.CanonicalHeaderKey(h)] = v
+					}
+				}
+				data, err := json.Marshal(resp)
+				if err == nil {
+					err = svc.Complete(ctx, key, data)
+				}
+				if err != nil {
+					log.Printf("http %s %s: store idempotent response: %v", r.Method, r.URL.Path, err)
+				}
+			} else if err := svc.Release(ctx, key); err != nil {
+				log.Printf("http %s %s: release idempotency key: %v", r.Method, r.URL.Path, err)
+			}
+
+			for h, v := range rec.header {
+				w.Header()[h] = v
+			}
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, stored []byte) {
+	var resp storedResponse
+	if err := json.Unmarshal(stored, &resp); err != nil {
+		log.Printf("http %s %s: decode idempotent response: %v", r.Method, r.URL.Path, err)
+		WriteProblem(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	for h, v := range resp.Header {
+		w.Header()[h] = v
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// recorder buffers a response so that it can be stored before it is sent.
+type recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
@@ -0,0 +1,91 @@
+// Package http writes errors as RFC 7807 problem details
+// (application/problem+json), so that every HTTP module reports errors in
+// the same shape.
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"hex-postgres-grpc/internal/common/apperr"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Code and InvalidParams are
+// extension members.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Code is the reason of the domain error, e.g. PRODUCT_NOT_FOUND.
+	Code          string         `json:"code,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+var statuses = map[apperr.Kind]int{
+	apperr.Internal:           http.StatusInternalServerError,
+	apperr.InvalidArgument:    http.StatusBadRequest,
+	apperr.NotFound:           http.StatusNotFound,
+	apperr.AlreadyExists:      http.StatusConflict,
+	apperr.Conflict:           http.StatusConflict,
+	apperr.FailedPrecondition: http.StatusBadRequest,
+	apperr.Unauthenticated:    http.StatusUnauthorized,
+	apperr.PermissionDenied:   http.StatusForbidden,
+	apperr.ResourceExhausted:  http.StatusTooManyRequests,
+	apperr.PreconditionFailed: http.StatusPreconditionFailed,
+}
+
+// Status returns the HTTP status for the kind of err.
+func Status(err error) int {
+	return statuses[apperr.KindOf(err)]
+}
+
+// WriteError writes err with the status of its kind. The message of an
+// internal error is logged instead of being sent.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e, ok := apperr.As(err)
+	if !ok || e.Kind == apperr.Internal {
+		log.Printf("http %s %s: %v", r.Method, r.URL.Path, err)
+		WriteProblem(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	p := newProblem(r, statuses[e.Kind], err.Error())
+	p.Code = e.Reason
+	if e.Field != "" {
+		p.InvalidParams = []InvalidParam{{Name: e.Field, Reason: e.Message}}
+	}
+	write(w, p)
+}
+
+// WriteProblem writes a problem with an explicit status, for errors raised
+// by the handler itself such as a malformed request body.
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	write(w, newProblem(r, status, detail))
+}
+
+func newProblem(r *http.Request, status int, detail string) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+}
+
+func write(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
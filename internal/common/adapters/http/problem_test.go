@@ -0,0 +1,32 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"hex-postgres-grpc/internal/common/apperr"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", apperr.New(apperr.NotFound, "THING_NOT_FOUND", "thing not found"), http.StatusNotFound},
+		{"wrapped", fmt.Errorf("loading: %w", apperr.New(apperr.NotFound, "THING_NOT_FOUND", "thing not found")), http.StatusNotFound},
+		{"field", apperr.NewField("name", "NAME_REQUIRED", "name is required"), http.StatusBadRequest},
+		{"conflict", apperr.New(apperr.Conflict, "THING_CONFLICT", "thing was modified concurrently"), http.StatusConflict},
+		{"precondition failed", apperr.New(apperr.PreconditionFailed, "THING_VERSION_MISMATCH", "thing has changed"), http.StatusPreconditionFailed},
+		{"untyped", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Status(tt.err); got != tt.want {
+				t.Fatalf("Status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"hex-postgres-grpc/internal/common/idempotency"
+	"hex-postgres-grpc/internal/common/tenant"
+)
+
+// IdempotencyStore implements idempotency.Store on the idempotency_keys
+// table.
+type IdempotencyStore struct {
+	db *DB
+}
+
+func NewIdempotencyStore(db *sql.DB) *IdempotencyStore {
+	return &IdempotencyStore{db: Wrap(db)}
+}
+
+func (s *IdempotencyStore) Reserve(ctx context.Context, key idempotency.Key, requestHash string, expiresAt, staleBefore time.Time) (*idempotency.Record, error) {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the key unless a live record holds it. The conflict clause takes
+	// over expired records and requests that never completed.
+	const claim = `INSERT INTO idempotency_keys (tenant_id, subject_id, idempotency_key, request_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NOW(), $5)
+		ON CONFLICT (tenant_id, subject_id, idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, response = NULL, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW() OR (idempotency_keys.response IS NULL AND idempotency_keys.created_at < $6)`
+	res, err := s.db.ExecContext(ctx, claim, tenantID, key.SubjectID, key.Value, requestHash, expiresAt, staleBefore)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 1 {
+		return nil, nil
+	}
+
+	const q = `SELECT request_hash, response, created_at, expires_at FROM idempotency_keys
+		WHERE tenant_id = $1 AND subject_id = $2 AND idempotency_key = $3`
+	rec := idempotency.Record{Key: key}
+	err = s.db.QueryRowContext(ctx, q, tenantID, key.SubjectID, key.Value).Scan(&rec.RequestHash, &rec.Response, &rec.CreatedAt, &rec.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released in the meantime; the caller's retry will claim it.
+		return nil, idempotency.ErrInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, key idempotency.Key, response []byte) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE idempotency_keys SET response = $1 WHERE tenant_id = $2 AND subject_id = $3 AND idempotency_key = $4`
+	_, err = s.db.ExecContext(ctx, q, response, tenantID, key.SubjectID, key.Value)
+	return err
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key idempotency.Key) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const q = `DELETE FROM idempotency_keys WHERE tenant_id = $1 AND subject_id = $2 AND idempotency_key = $3 AND response IS NULL`
+	_, err = s.db.ExecContext(ctx, q, tenantID, key.SubjectID, key.Value)
+	return err
+}
+
+func (s *IdempotencyStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"hex-postgres-grpc/internal/common/events"
+	"hex-postgres-grpc/internal/common/tenant"
+)
+
+// OutboxStore implements events.Store on the outbox_events and
+// outbox_dead_letters tables. Record joins the unit of work of the context,
+// so events are committed with the write they describe.
+type OutboxStore struct {
+	db *DB
+}
+
+var _ events.Store = (*OutboxStore)(nil)
+
+func NewOutboxStore(db *sql.DB) *OutboxStore {
+	return &OutboxStore{db: Wrap(db)}
+}
+
+func (s *OutboxStore) Record(ctx context.Context, evts ...events.Event) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const q = `INSERT INTO outbox_events (id, tenant_id, event_type, aggregate_type, aggregate_id, payload, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	return s.db.InTx(ctx, func(ctx context.Context) error {
+		for _, e := range evts {
+			if _, err := s.db.ExecContext(ctx, q, e.ID, tenantID, e.Type, e.AggregateType, e.AggregateID, string(e.Payload), e.OccurredAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *OutboxStore) Claim(ctx context.Context, now, lockedUntil time.Time, limit int) ([]events.Message, error) {
+	// SKIP LOCKED lets concurrent relays claim disjoint batches.
+	const q = `UPDATE outbox_events SET locked_until = $2
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE next_attempt_at <= $1 AND (locked_until IS NULL OR locked_until <= $1)
+			ORDER BY occurred_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, tenant_id, event_type, aggregate_type, aggregate_id, payload, occurred_at, attempts, COALESCE(last_error, '')`
+	rows, err := s.db.QueryContext(ctx, q, now, lockedUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []events.Message
+	for rows.Next() {
+		var m events.Message
+		var payload []byte
+		if err := rows.Scan(&m.ID, &m.TenantID, &m.Type, &m.AggregateType, &m.AggregateID, &payload, &m.OccurredAt, &m.Attempts, &m.LastError); err != nil {
+			return nil, err
+		}
+		m.Payload = payload
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+func (s *OutboxStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM outbox_events WHERE id = $1`, id)
+	return err
+}
+
+func (s *OutboxStore) Retry(ctx context.Context, id string, attempts int, next time.Time, lastErr string) error {
+	const q = `UPDATE outbox_events SET attempts = $2, next_attempt_at = $3, last_error = $4, locked_until = NULL WHERE id = $1`
+	_, err := s.db.ExecContext(ctx, q, id, attempts, next, lastErr)
+	return err
+}
+
+func (s *OutboxStore) DeadLetter(ctx context.Context, id string, attempts int, lastErr string) error {
+	return s.db.InTx(ctx, func(ctx context.Context) error {
+		const move = `INSERT INTO outbox_dead_letters (id, tenant_id, event_type, aggregate_type, aggregate_id, payload, occurred_at, attempts, last_error, dead_lettered_at)
+			SELECT id, tenant_id, event_type, aggregate_type, aggregate_id, payload, occurred_at, $2, $3, NOW()
+			FROM outbox_events WHERE id = $1`
+		if _, err := s.db.ExecContext(ctx, move, id, attempts, lastErr); err != nil {
+			return err
+		}
+		_, err := s.db.ExecContext(ctx, `DELETE FROM outbox_events WHERE id = $1`, id)
+		return err
+	})
+}
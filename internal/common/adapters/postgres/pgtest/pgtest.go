@@ -0,0 +1,49 @@
+// Package pgtest connects integration tests to the Postgres database named
+// by TEST_DATABASE_URL. Tests that use it are skipped when the variable is
+// not set.
+package pgtest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"hex-postgres-grpc/internal/common/tenant"
+	"hex-postgres-grpc/internal/migrate"
+	"hex-postgres-grpc/migrations"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// Open returns a connection to the test database with every migration
+// applied. The connection is closed when the test ends.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+// Tenant returns a context for a tenant of its own, so that tests neither
+// see each other's rows nor depend on what earlier runs left behind.
+func Tenant() context.Context {
+	return tenant.WithID(context.Background(), "test-"+uuid.NewString())
+}
@@ -0,0 +1,130 @@
+// Package postgres provides the Postgres implementation of the unit of work
+// and the database handle that repositories use to take part in it, and the
+// Postgres stores of the other shared ports.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"hex-postgres-grpc/internal/common/uow"
+
+	"github.com/lib/pq"
+)
+
+type txKey struct{}
+
+// DB runs queries in the transaction of the context when there is one and
+// on the pool otherwise. Repositories hold a DB instead of a *sql.DB, so
+// they join a unit of work without further changes.
+type DB struct {
+	db *sql.DB
+}
+
+func Wrap(db *sql.DB) *DB {
+	return &DB{db: db}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return d.conn(ctx).ExecContext(ctx, query, args...)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return d.conn(ctx).QueryContext(ctx, query, args...)
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return d.conn(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// InTx runs fn in the transaction of ctx, or in a new one, for repository
+// methods that issue several statements.
+func (d *DB) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return NewTxManager(d.db, TxConfig{}).Do(ctx, fn)
+}
+
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (d *DB) conn(ctx context.Context) executor {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return d.db
+}
+
+type TxConfig struct {
+	// Isolation defaults to the database default, read committed.
+	// Serialization failures, which are retried, mostly occur with
+	// sql.LevelRepeatableRead and sql.LevelSerializable.
+	Isolation sql.IsolationLevel
+	// MaxAttempts bounds how often a transaction is tried when it fails
+	// with a serialization failure or a deadlock. It defaults to 3.
+	MaxAttempts int
+}
+
+// TxManager implements uow.UnitOfWork on a *sql.DB.
+type TxManager struct {
+	db  *sql.DB
+	cfg TxConfig
+}
+
+var _ uow.UnitOfWork = (*TxManager)(nil)
+
+func NewTxManager(db *sql.DB, cfg TxConfig) *TxManager {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	return &TxManager{db: db, cfg: cfg}
+}
+
+func (m *TxManager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	// Nested units of work join the outer transaction, which is retried as
+	// a whole.
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := m.run(ctx, fn)
+		if err == nil || !retryable(err) || attempt >= m.cfg.MaxAttempts {
+			return err
+		}
+
+		backoff := time.Duration(attempt)*10*time.Millisecond + time.Duration(rand.Int63n(int64(10*time.Millisecond)))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (m *TxManager) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := m.db.BeginTx(ctx, &sql.TxOptions{Isolation: m.cfg.Isolation})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// retryable reports whether err is a serialization failure or a deadlock,
+// after which the transaction can succeed when run again.
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
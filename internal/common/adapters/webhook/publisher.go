@@ -0,0 +1,75 @@
+// Package webhook provides an events.EventPublisher that POSTs events as
+// JSON to an HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"hex-postgres-grpc/internal/common/events"
+)
+
+type Config struct {
+	URL string
+	// Secret signs request bodies when set. The signature is sent as
+	// X-Webhook-Signature: sha256=<hex HMAC-SHA256 of the body>.
+	Secret string
+	// Timeout bounds each request. It defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+// Publisher delivers each event in a request of its own. Any response other
+// than 2xx counts as a failed delivery.
+type Publisher struct {
+	cfg    Config
+	client *http.Client
+}
+
+var _ events.EventPublisher = (*Publisher)(nil)
+
+func NewPublisher(cfg Config) *Publisher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Publisher{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+func (p *Publisher) Publish(ctx context.Context, e events.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", e.ID)
+	req.Header.Set("X-Event-Type", e.Type)
+	if p.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(p.cfg.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so that the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s responded %s", p.cfg.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Package apperr defines the typed errors that domain errors are built from.
+// The kind of an error decides how it is reported to clients: the HTTP
+// adapters turn it into a problem+json response and the gRPC adapters into a
+// status code, both through the translators in internal/common/adapters.
+// Errors without a kind are reported as internal errors and their message is
+// not shown to clients.
+package apperr
+
+import "errors"
+
+type Kind int
+
+const (
+	// Internal is the kind of every error that is not an *Error.
+	Internal Kind = iota
+	InvalidArgument
+	NotFound
+	AlreadyExists
+	// Conflict means that the resource changed while the request was being
+	// applied. Reading it again and retrying may succeed.
+	Conflict
+	// FailedPrecondition means that the resource is not in a state that
+	// allows the operation.
+	FailedPrecondition
+	Unauthenticated
+	PermissionDenied
+	ResourceExhausted
+	// PreconditionFailed means that the request only applies to a version of
+	// the resource, e.g. with If-Match, and the resource has another version.
+	PreconditionFailed
+)
+
+var kindNames = map[Kind]string{
+	Internal:           "internal",
+	InvalidArgument:    "invalid_argument",
+	NotFound:           "not_found",
+	AlreadyExists:      "already_exists",
+	Conflict:           "conflict",
+	FailedPrecondition: "failed_precondition",
+	Unauthenticated:    "unauthenticated",
+	PermissionDenied:   "permission_denied",
+	ResourceExhausted:  "resource_exhausted",
+	PreconditionFailed: "precondition_failed",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Error is a domain error with a kind. Domain packages declare their errors
+// as *Error values, so they can still be compared with == and errors.Is.
+type Error struct {
+	Kind Kind
+	// Reason identifies the error for programs, e.g. PRODUCT_NOT_FOUND. It
+	// is unique across the application.
+	Reason  string
+	Message string
+	// Field names the invalid request field of InvalidArgument errors.
+	Field string
+}
+
+func New(kind Kind, reason, message string) *Error {
+	return &Error{Kind: kind, Reason: reason, Message: message}
+}
+
+// NewField returns an InvalidArgument error about a request field.
+func NewField(field, reason, message string) *Error {
+	return &Error{Kind: InvalidArgument, Reason: reason, Message: message, Field: field}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// As returns the *Error in err's chain, if any.
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// KindOf returns the kind of the *Error in err's chain, or Internal.
+func KindOf(err error) Kind {
+	if e, ok := As(err); ok {
+		return e.Kind
+	}
+	return Internal
+}
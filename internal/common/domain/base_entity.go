@@ -2,12 +2,25 @@ package domain
 
 import "time"
 
+// SystemUserID is recorded as the author of writes made without an
+// authenticated subject.
+const SystemUserID = "00000000-0000-0000-0000-000000000000"
+
 type BaseEntity struct {
 	ID        string     `json:"id"`
+	TenantID  string     `json:"tenant_id"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt *time.Time `json:"updated_at,omitempty"`
 	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 	CreatedBy string     `json:"created_by,omitempty"`
 	UpdatedBy *string    `json:"updated_by,omitempty"`
 	DeletedBy *string    `json:"deleted_by,omitempty"`
+	// The *ByActor fields hold the administrator behind a write made while
+	// impersonating the user recorded in the matching *By field.
+	CreatedByActor *string `json:"created_by_actor,omitempty"`
+	UpdatedByActor *string `json:"updated_by_actor,omitempty"`
+	DeletedByActor *string `json:"deleted_by_actor,omitempty"`
+	// Version starts at 1 and is incremented by every update. Updates only
+	// apply to the version they were based on; see ETag.
+	Version int64 `json:"version"`
 }
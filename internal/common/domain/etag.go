@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+
+	"hex-postgres-grpc/internal/common/apperr"
+)
+
+var ErrInvalidETag = apperr.New(apperr.InvalidArgument, "INVALID_ETAG", "invalid etag")
+
+// ETag returns the entity tag of the current version, e.g. "3" including
+// the quotes.
+func (e BaseEntity) ETag() string {
+	return strconv.Quote(strconv.FormatInt(e.Version, 10))
+}
+
+// ParseETag returns the version named by an entity tag as sent in an
+// If-Match header or an etag field. An empty tag or "*" match any version
+// and yield 0. Weak tags are accepted as well.
+func ParseETag(tag string) (int64, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || tag == "*" {
+		return 0, nil
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0, ErrInvalidETag
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, ErrInvalidETag
+	}
+	return version, nil
+}
@@ -0,0 +1,58 @@
+// Package events lets modules tell other systems what happened to their
+// aggregates. Use cases record domain events in the outbox in the
+// transaction of their write, so an event is stored exactly when the write
+// commits. The Relay then hands the stored events to an EventPublisher, at
+// least once: consumers may see an event more than once and should
+// deduplicate by its ID.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is a domain event. Payload is the JSON of an event type of the
+// module that raised it, such as order.OrderCreated.
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	TenantID      string          `json:"tenant_id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// New returns an event of the given type about an aggregate, with payload
+// encoded as JSON. The tenant is set when the event is recorded.
+func New(eventType, aggregateType, aggregateID string, payload interface{}) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:            uuid.NewString(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		OccurredAt:    time.Now(),
+		Payload:       data,
+	}, nil
+}
+
+// Outbox is where use cases record events.
+type Outbox interface {
+	// Record stores events for publishing, in the transaction of ctx when
+	// there is one, under the tenant of ctx. Run it in the unit of work of
+	// the write the events describe.
+	Record(ctx context.Context, events ...Event) error
+}
+
+// EventPublisher delivers events to other systems.
+type EventPublisher interface {
+	// Publish delivers e. An error makes the relay try again later.
+	Publish(ctx context.Context, e Event) error
+}
@@ -0,0 +1,152 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// Message is an event waiting in the outbox.
+type Message struct {
+	Event
+	// Attempts counts the failed deliveries so far.
+	Attempts  int
+	LastError string
+}
+
+// Store is the outbox as the relay sees it. It spans all tenants.
+type Store interface {
+	Outbox
+	// Claim returns up to limit events that are due at now and hides them
+	// from other claims until lockedUntil, so that several relays can run
+	// side by side. Events claimed by a relay that stopped before settling
+	// them are claimed again once the lock expires.
+	Claim(ctx context.Context, now, lockedUntil time.Time, limit int) ([]Message, error)
+	// Delete removes a delivered event.
+	Delete(ctx context.Context, id string) error
+	// Retry records a failed delivery and makes the event due again at next.
+	Retry(ctx context.Context, id string, attempts int, next time.Time, lastErr string) error
+	// DeadLetter moves an event that failed for the last time to the dead
+	// letters, where it is kept for inspection and is no longer delivered.
+	DeadLetter(ctx context.Context, id string, attempts int, lastErr string) error
+}
+
+type RelayConfig struct {
+	// Interval is how often the outbox is polled. It defaults to a second.
+	Interval time.Duration
+	// BatchSize bounds the events claimed per poll. It defaults to 100.
+	BatchSize int
+	// MaxAttempts is how often delivery of an event is tried before it is
+	// dead-lettered. It defaults to 10.
+	MaxAttempts int
+	// Backoff is the delay after the first failed delivery; it doubles
+	// with every further failure up to MaxBackoff. They default to 5
+	// seconds and an hour.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// claimTimeout is how long a relay may take to deliver a batch before
+// another relay claims its events again.
+const claimTimeout = 5 * time.Minute
+
+// Relay moves events from the outbox to a publisher.
+type Relay struct {
+	store     Store
+	publisher EventPublisher
+	cfg       RelayConfig
+}
+
+func NewRelay(store Store, publisher EventPublisher, cfg RelayConfig) *Relay {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 5 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Hour
+	}
+	return &Relay{store: store, publisher: publisher, cfg: cfg}
+}
+
+// Run delivers due events every interval until ctx is cancelled. A full
+// batch is followed by the next one right away.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := r.Flush(ctx)
+				if err != nil {
+					log.Printf("events: relay: %v", err)
+				}
+				if err != nil || n < r.cfg.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Flush delivers one batch of due events, oldest first, and returns how
+// many were claimed. Failed deliveries are retried with backoff and
+// dead-lettered after MaxAttempts.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	now := time.Now()
+	msgs, err := r.store.Claim(ctx, now, now.Add(claimTimeout), r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].OccurredAt.Before(msgs[j].OccurredAt) })
+
+	for _, m := range msgs {
+		if err := r.deliver(ctx, m); err != nil {
+			return len(msgs), err
+		}
+	}
+	return len(msgs), nil
+}
+
+// deliver publishes m and settles it in the store. Only store errors are
+// returned; a failed publish is recorded on the event.
+func (r *Relay) deliver(ctx context.Context, m Message) error {
+	pubErr := r.publisher.Publish(ctx, m.Event)
+	if pubErr == nil {
+		return r.store.Delete(ctx, m.ID)
+	}
+	if ctx.Err() != nil {
+		// Shutting down; the event is claimed again when the lock expires.
+		return ctx.Err()
+	}
+
+	attempts := m.Attempts + 1
+	if attempts >= r.cfg.MaxAttempts {
+		log.Printf("events: dead-lettering %s %s after %d attempts: %v", m.Type, m.ID, attempts, pubErr)
+		return r.store.DeadLetter(ctx, m.ID, attempts, pubErr.Error())
+	}
+	return r.store.Retry(ctx, m.ID, attempts, time.Now().Add(r.backoff(attempts)), pubErr.Error())
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.cfg.Backoff
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.cfg.MaxBackoff {
+		d = r.cfg.MaxBackoff
+	}
+	return d
+}
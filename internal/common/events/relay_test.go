@@ -0,0 +1,221 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type outboxEntry struct {
+	Message
+	due time.Time
+}
+
+// memStore is an outbox in memory. Claims do not lock events; the tests
+// run one relay.
+type memStore struct {
+	mu          sync.Mutex
+	entries     map[string]*outboxEntry
+	deadLetters map[string]Message
+}
+
+func newMemStore(events ...Event) *memStore {
+	s := &memStore{entries: map[string]*outboxEntry{}, deadLetters: map[string]Message{}}
+	s.Record(context.Background(), events...)
+	return s
+}
+
+func (s *memStore) Record(ctx context.Context, events ...Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		s.entries[e.ID] = &outboxEntry{Message: Message{Event: e}, due: e.OccurredAt}
+	}
+	return nil
+}
+
+func (s *memStore) Claim(ctx context.Context, now, lockedUntil time.Time, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var msgs []Message
+	for _, e := range s.entries {
+		if !e.due.After(now) {
+			msgs = append(msgs, e.Message)
+		}
+	}
+	// Return the newest first, so the relay has to order them.
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].OccurredAt.After(msgs[j].OccurredAt) })
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+func (s *memStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+func (s *memStore) Retry(ctx context.Context, id string, attempts int, next time.Time, lastErr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[id]
+	e.Attempts, e.due, e.LastError = attempts, next, lastErr
+	return nil
+}
+
+func (s *memStore) DeadLetter(ctx context.Context, id string, attempts int, lastErr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.entries[id].Message
+	m.Attempts, m.LastError = attempts, lastErr
+	s.deadLetters[id] = m
+	delete(s.entries, id)
+	return nil
+}
+
+// makeDue makes every waiting event due now.
+func (s *memStore) makeDue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		e.due = time.Now()
+	}
+}
+
+// recordingPublisher records the published events and fails with err when
+// it is set.
+type recordingPublisher struct {
+	published []string
+	err       error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, e Event) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, e.ID)
+	return nil
+}
+
+func testEvent(id string, occurredAt time.Time) Event {
+	return Event{ID: id, Type: "order.created", TenantID: "t1", OccurredAt: occurredAt}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	r := NewRelay(newMemStore(), &recordingPublisher{}, RelayConfig{Backoff: 5 * time.Second, MaxBackoff: time.Minute})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{4, 40 * time.Second},
+		{5, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := r.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestFlushDeliversOldestFirst(t *testing.T) {
+	now := time.Now()
+	store := newMemStore(testEvent("e2", now.Add(-time.Second)), testEvent("e1", now.Add(-time.Minute)), testEvent("e3", now))
+	pub := &recordingPublisher{}
+	r := NewRelay(store, pub, RelayConfig{})
+
+	n, err := r.Flush(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("Flush() = %d, %v; want 3, nil", n, err)
+	}
+	if len(pub.published) != 3 || pub.published[0] != "e1" || pub.published[1] != "e2" || pub.published[2] != "e3" {
+		t.Fatalf("published %v, want [e1 e2 e3]", pub.published)
+	}
+	if len(store.entries) != 0 {
+		t.Fatalf("%d events left in the outbox, want none", len(store.entries))
+	}
+}
+
+func TestFlushRetriesWithBackoff(t *testing.T) {
+	store := newMemStore(testEvent("e1", time.Now()))
+	pub := &recordingPublisher{err: errors.New("webhook down")}
+	r := NewRelay(store, pub, RelayConfig{Backoff: time.Minute, MaxBackoff: time.Hour})
+
+	start := time.Now()
+	if _, err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	e := store.entries["e1"]
+	if e == nil || e.Attempts != 1 || e.LastError != "webhook down" {
+		t.Fatalf("outbox entry = %+v, want one failed attempt", e)
+	}
+	if e.due.Before(start.Add(time.Minute)) || e.due.After(time.Now().Add(time.Minute)) {
+		t.Fatalf("retry due at %v, want a minute from now", e.due)
+	}
+
+	// The event is not due again before the backoff has passed.
+	if n, err := r.Flush(context.Background()); err != nil || n != 0 {
+		t.Fatalf("second Flush() = %d, %v; want 0, nil", n, err)
+	}
+
+	// The next failure doubles the delay.
+	store.makeDue()
+	start = time.Now()
+	if _, err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if e := store.entries["e1"]; e.Attempts != 2 || e.due.Before(start.Add(2*time.Minute)) {
+		t.Fatalf("outbox entry = %+v, want a second attempt due in two minutes", e)
+	}
+
+	// Once the publisher recovers, the event is delivered and removed.
+	pub.err = nil
+	store.makeDue()
+	if _, err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if len(pub.published) != 1 || len(store.entries) != 0 || len(store.deadLetters) != 0 {
+		t.Fatalf("published %v, outbox %v, dead letters %v; want e1 delivered", pub.published, store.entries, store.deadLetters)
+	}
+}
+
+func TestFlushDeadLettersAfterMaxAttempts(t *testing.T) {
+	store := newMemStore(testEvent("e1", time.Now()), testEvent("e2", time.Now()))
+	pub := &recordingPublisher{err: errors.New("webhook down")}
+	r := NewRelay(store, pub, RelayConfig{MaxAttempts: 3})
+
+	for i := 1; i <= 3; i++ {
+		if _, err := r.Flush(context.Background()); err != nil {
+			t.Fatalf("Flush %d: %v", i, err)
+		}
+		if i < 3 && len(store.deadLetters) != 0 {
+			t.Fatalf("dead-lettered after %d attempts, want 3", i)
+		}
+		store.makeDue()
+	}
+
+	if len(store.entries) != 0 {
+		t.Fatalf("%d events left in the outbox, want none", len(store.entries))
+	}
+	for _, id := range []string{"e1", "e2"} {
+		m, ok := store.deadLetters[id]
+		if !ok || m.Attempts != 3 || m.LastError != "webhook down" {
+			t.Fatalf("dead letter %s = %+v (%v), want 3 attempts", id, m, ok)
+		}
+	}
+
+	// Dead letters are not delivered any more.
+	pub.err = nil
+	if n, err := r.Flush(context.Background()); err != nil || n != 0 || len(pub.published) != 0 {
+		t.Fatalf("Flush() after dead-lettering = %d, %v; published %v", n, err, pub.published)
+	}
+}
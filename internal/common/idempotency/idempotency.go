@@ -0,0 +1,140 @@
+// Package idempotency makes retried requests safe. A client names a request
+// with an idempotency key; the first request with a key runs and its response
+// is stored, and retries with the same key get that response replayed
+// instead of running again. The HTTP and gRPC adapters in
+// internal/common/adapters apply it to the create operations.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"hex-postgres-grpc/internal/common/apperr"
+)
+
+// MaxKeyLength bounds the length of idempotency keys.
+const MaxKeyLength = 255
+
+var (
+	ErrInvalidKey = apperr.New(apperr.InvalidArgument, "INVALID_IDEMPOTENCY_KEY", "idempotency key must be at most 255 characters")
+	// ErrKeyReused is returned when a key is sent again with a different
+	// request.
+	ErrKeyReused = apperr.New(apperr.AlreadyExists, "IDEMPOTENCY_KEY_REUSED", "idempotency key was already used for a different request")
+	// ErrInProgress is returned when a key is sent again while the first
+	// request with it is still running.
+	ErrInProgress = apperr.New(apperr.AlreadyExists, "IDEMPOTENCY_KEY_IN_USE", "a request with this idempotency key is in progress")
+)
+
+// Key identifies a request. Keys are chosen by clients, so they are scoped
+// to the subject that sent them; repositories scope them to the tenant.
+type Key struct {
+	SubjectID string
+	Value     string
+}
+
+// Record is the stored state of a key.
+type Record struct {
+	Key         Key
+	RequestHash string
+	// Response is nil while the first request is running.
+	Response  []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Store keeps the records of idempotency keys.
+type Store interface {
+	// Reserve claims key for a request with the given hash until expiresAt.
+	// It returns nil when the key was free, expired, or held by a request
+	// that started before staleBefore and never completed. Otherwise it
+	// returns the record that holds the key.
+	Reserve(ctx context.Context, key Key, requestHash string, expiresAt, staleBefore time.Time) (*Record, error)
+	// Complete stores the response of the request holding key.
+	Complete(ctx context.Context, key Key, response []byte) error
+	// Release frees key, so that the request can be retried.
+	Release(ctx context.Context, key Key) error
+	// DeleteExpired removes the records of all tenants that expired before
+	// now.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// lockTimeout is how long a request may hold a key without completing
+// before a retry takes the key over, e.g. after the server crashed.
+const lockTimeout = time.Minute
+
+type Service struct {
+	store Store
+	ttl   time.Duration
+}
+
+// NewService keeps responses for ttl.
+func NewService(store Store, ttl time.Duration) *Service {
+	return &Service{store: store, ttl: ttl}
+}
+
+// Begin claims key for a request. It returns the stored response when a
+// request with the same hash already completed, and ErrKeyReused or
+// ErrInProgress when the key is held otherwise. When Begin returns nil and
+// no error, the caller runs the request and then calls Complete, or Release
+// when the request failed.
+func (s *Service) Begin(ctx context.Context, key Key, requestHash string) ([]byte, error) {
+	if len(key.Value) > MaxKeyLength {
+		return nil, ErrInvalidKey
+	}
+
+	now := time.Now()
+	rec, err := s.store.Reserve(ctx, key, requestHash, now.Add(s.ttl), now.Add(-lockTimeout))
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	if rec.RequestHash != requestHash {
+		return nil, ErrKeyReused
+	}
+	if rec.Response == nil {
+		return nil, ErrInProgress
+	}
+	return rec.Response, nil
+}
+
+func (s *Service) Complete(ctx context.Context, key Key, response []byte) error {
+	return s.store.Complete(ctx, key, response)
+}
+
+func (s *Service) Release(ctx context.Context, key Key) error {
+	return s.store.Release(ctx, key)
+}
+
+// Run deletes expired records every interval until ctx is cancelled.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.store.DeleteExpired(ctx, time.Now()); err != nil {
+				log.Printf("idempotency: delete expired keys: %v", err)
+			}
+		}
+	}
+}
+
+// Hash returns the request hash of the given parts, e.g. an operation and a
+// request body.
+func Hash(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		// Prefix each part with its length so that parts cannot run into
+		// each other.
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(p)))
+		h.Write(n[:])
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
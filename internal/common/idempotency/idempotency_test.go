@@ -0,0 +1,123 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore keeps records in memory with the semantics of the Postgres store.
+type memStore struct {
+	mu      sync.Mutex
+	records map[Key]*Record
+}
+
+func newMemStore() *memStore {
+	return &memStore{records: map[Key]*Record{}}
+}
+
+func (s *memStore) Reserve(ctx context.Context, key Key, requestHash string, expiresAt, staleBefore time.Time) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if rec, ok := s.records[key]; ok && rec.ExpiresAt.After(now) && (rec.Response != nil || !rec.CreatedAt.Before(staleBefore)) {
+		c := *rec
+		return &c, nil
+	}
+	s.records[key] = &Record{Key: key, RequestHash: requestHash, CreatedAt: now, ExpiresAt: expiresAt}
+	return nil, nil
+}
+
+func (s *memStore) Complete(ctx context.Context, key Key, response []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[key]; ok {
+		rec.Response = response
+	}
+	return nil
+}
+
+func (s *memStore) Release(ctx context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func (s *memStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for k, rec := range s.records {
+		if rec.ExpiresAt.Before(now) {
+			delete(s.records, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestBegin(t *testing.T) {
+	ctx := context.Background()
+	key := Key{SubjectID: "u1", Value: "k1"}
+	hash := Hash([]byte("POST /orders"), []byte(`{"customer_id":"c1"}`))
+	otherHash := Hash([]byte("POST /orders"), []byte(`{"customer_id":"c2"}`))
+
+	tests := []struct {
+		name string
+		// setup runs against a fresh service after the first Begin of key
+		// with hash.
+		setup    func(s *Service, store *memStore)
+		key      Key
+		hash     string
+		response string
+		wantErr  error
+	}{
+		{"in flight", nil, key, hash, "", ErrInProgress},
+		{"in flight with different payload", nil, key, otherHash, "", ErrKeyReused},
+		{"replay", func(s *Service, _ *memStore) { s.Complete(ctx, key, []byte("created")) }, key, hash, "created", nil},
+		{"completed with different payload", func(s *Service, _ *memStore) { s.Complete(ctx, key, []byte("created")) }, key, otherHash, "", ErrKeyReused},
+		{"released", func(s *Service, _ *memStore) { s.Release(ctx, key) }, key, hash, "", nil},
+		{"other subject", nil, Key{SubjectID: "u2", Value: "k1"}, hash, "", nil},
+		{"stale lock", func(_ *Service, store *memStore) {
+			store.records[key].CreatedAt = time.Now().Add(-2 * lockTimeout)
+		}, key, hash, "", nil},
+		{"expired response", func(s *Service, store *memStore) {
+			s.Complete(ctx, key, []byte("created"))
+			store.records[key].ExpiresAt = time.Now().Add(-time.Second)
+		}, key, otherHash, "", nil},
+		{"key too long", nil, Key{SubjectID: "u1", Value: strings.Repeat("k", MaxKeyLength+1)}, hash, "", ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStore()
+			s := NewService(store, time.Hour)
+			if resp, err := s.Begin(ctx, key, hash); resp != nil || err != nil {
+				t.Fatalf("first Begin = %q, %v; want nil, nil", resp, err)
+			}
+			if tt.setup != nil {
+				tt.setup(s, store)
+			}
+
+			resp, err := s.Begin(ctx, tt.key, tt.hash)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Begin() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(resp) != tt.response {
+				t.Fatalf("Begin() = %q, want %q", resp, tt.response)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	if Hash([]byte("ab"), []byte("c")) == Hash([]byte("a"), []byte("bc")) {
+		t.Fatal("parts that concatenate to the same bytes hash equally")
+	}
+	if Hash([]byte("a"), []byte("b")) != Hash([]byte("a"), []byte("b")) {
+		t.Fatal("Hash is not deterministic")
+	}
+}
@@ -0,0 +1,99 @@
+package interceptors
+
+import (
+	"context"
+	"log"
+
+	"hex-postgres-grpc/internal/common/idempotency"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	IdempotencyKeyMetadata = "idempotency-key"
+	// ReplayedMetadata is set in the response header of replayed calls.
+	ReplayedMetadata = "idempotent-replayed"
+)
+
+// UnaryIdempotency honours the idempotency-key metadata on the given
+// methods. Successful responses are stored and replayed to retries with the
+// same key and request; failed calls free the key again. subjectID names the
+// caller, to whom keys are scoped, so the interceptor must run after
+// authentication; anonymous calls are passed on unchanged.
+func UnaryIdempotency(svc *idempotency.Service, subjectID func(ctx context.Context) string, methods ...string) grpc.UnaryServerInterceptor {
+	guarded := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		guarded[m] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		msg, ok := req.(proto.Message)
+		if !ok || !guarded[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(IdempotencyKeyMetadata)
+		subject := subjectID(ctx)
+		if len(values) == 0 || values[0] == "" || subject == "" {
+			return handler(ctx, req)
+		}
+
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		key := idempotency.Key{SubjectID: subject, Value: values[0]}
+		stored, err := svc.Begin(ctx, key, idempotency.Hash([]byte(info.FullMethod), body))
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			return replayed(ctx, stored)
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			if err := svc.Release(ctx, key); err != nil {
+				log.Printf("grpc %s: release idempotency key: %v", info.FullMethod, err)
+			}
+			return resp, err
+		}
+
+		if err := complete(ctx, svc, key, resp); err != nil {
+			log.Printf("grpc %s: store idempotent response: %v", info.FullMethod, err)
+		}
+		return resp, nil
+	}
+}
+
+func complete(ctx context.Context, svc *idempotency.Service, key idempotency.Key, resp interface{}) error {
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return svc.Release(ctx, key)
+	}
+	a, err := anypb.New(msg)
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return svc.Complete(ctx, key, data)
+}
+
+func replayed(ctx context.Context, stored []byte) (interface{}, error) {
+	var a anypb.Any
+	if err := proto.Unmarshal(stored, &a); err != nil {
+		return nil, err
+	}
+	resp, err := a.UnmarshalNew()
+	if err != nil {
+		return nil, err
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(ReplayedMetadata, "true"))
+	return resp, nil
+}
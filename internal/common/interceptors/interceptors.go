@@ -0,0 +1,98 @@
+package interceptors
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"runtime/debug"
+	"time"
+
+	commongrpc "hex-postgres-grpc/internal/common/adapters/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics are published through expvar (GET /debug/vars on the debug
+// listener) under "grpc_server": request counts keyed by "<method> <code>"
+// and cumulative latency in milliseconds keyed by method.
+var (
+	metrics        = expvar.NewMap("grpc_server")
+	requestsTotal  = new(expvar.Map).Init()
+	latencyMsTotal = new(expvar.Map).Init()
+)
+
+func init() {
+	metrics.Set("requests_total", requestsTotal)
+	metrics.Set("latency_ms_total", latencyMsTotal)
+}
+
+func UnaryLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	log.Printf("grpc %s code=%s duration=%s", info.FullMethod, status.Code(err), time.Since(start))
+	return resp, err
+}
+
+func StreamLogging(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	log.Printf("grpc stream %s code=%s duration=%s", info.FullMethod, status.Code(err), time.Since(start))
+	return err
+}
+
+func UnaryMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	record(info.FullMethod, err, start)
+	return resp, err
+}
+
+func StreamMetrics(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	record(info.FullMethod, err, start)
+	return err
+}
+
+func record(method string, err error, start time.Time) {
+	requestsTotal.Add(method+" "+status.Code(err).String(), 1)
+	latencyMsTotal.Add(method, time.Since(start).Milliseconds())
+}
+
+// UnaryErrors turns domain errors returned by handlers into gRPC statuses,
+// so that servers can return them unchanged.
+func UnaryErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, commongrpc.Error(err)
+}
+
+func StreamErrors(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return commongrpc.Error(handler(srv, ss))
+}
+
+// UnaryRecovery turns a panic in a handler into an Internal error instead of
+// crashing the server.
+func UnaryRecovery(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func StreamRecovery(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(info.FullMethod, r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recovered(method string, r interface{}) error {
+	log.Printf("grpc %s panic: %v\n%s", method, r, debug.Stack())
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,39 @@
+// Package tenant carries the tenant of a request through its context.
+// Repositories read it to scope every query, so data of one tenant is never
+// visible to another.
+package tenant
+
+import (
+	"context"
+	"errors"
+)
+
+// Default is the tenant of requests that do not name one, and of the data
+// that existed before tenants were introduced.
+const Default = "default"
+
+// ErrMissing is returned by repositories called without a tenant in the
+// context. Queries are never run unscoped.
+var ErrMissing = errors.New("tenant not set")
+
+type contextKey struct{}
+
+// WithID returns a copy of ctx that carries the tenant id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant of ctx, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// ID returns the tenant of ctx, or ErrMissing.
+func ID(ctx context.Context) (string, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrMissing
+	}
+	return id, nil
+}
@@ -0,0 +1,17 @@
+// Package uow defines the unit-of-work port. Use cases that change several
+// aggregates, possibly through repositories of different modules, run those
+// changes through a UnitOfWork so that they are committed together or not at
+// all.
+package uow
+
+import "context"
+
+type UnitOfWork interface {
+	// Do runs fn in a transaction that is committed when fn returns nil and
+	// rolled back otherwise. Repositories called with the context passed to
+	// fn take part in the transaction. A Do inside fn joins the transaction.
+	//
+	// fn may be called more than once when the transaction conflicts with a
+	// concurrent one, so it must not have side effects outside the database.
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,276 @@
+// Package config holds the settings of the server. They are read, in
+// increasing order of precedence, from built-in defaults, an optional YAML
+// file, environment variables and command-line flags; see Load.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Secret is a string that is never printed. It reads as "[redacted]" when
+// formatted or marshalled; use string(s) to get the value.
+type Secret string
+
+const redacted = "[redacted]"
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Auth     AuthConfig     `yaml:"auth"`
+	OIDC     OIDCConfig     `yaml:"oidc"`
+	Mail     MailConfig     `yaml:"mail"`
+	Events   EventsConfig   `yaml:"events"`
+}
+
+type ServerConfig struct {
+	HTTPAddr string `yaml:"http_addr"`
+	GRPCAddr string `yaml:"grpc_addr"`
+	// DebugAddr serves the expvar metrics at /debug/vars on a listener of
+	// its own, to be kept off the public network. Empty disables it.
+	DebugAddr string `yaml:"debug_addr"`
+	// ReadTimeout, WriteTimeout and IdleTimeout apply to the HTTP server.
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	// after a shutdown signal.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// IdempotencyTTL is how long the response to a request with an
+	// Idempotency-Key is kept for replaying retries.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+	TLS            TLSConfig     `yaml:"tls"`
+}
+
+// TLSConfig enables TLS on both the HTTP and the gRPC listener when a
+// certificate and key are given.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+type DatabaseConfig struct {
+	User     string `yaml:"user"`
+	Password Secret `yaml:"password"`
+	HostPort string `yaml:"host"`
+	Name     string `yaml:"name"`
+	// SSLMode is passed to lib/pq: disable, require, verify-ca or
+	// verify-full. SSLRootCert is the CA used by the verify modes.
+	SSLMode         string        `yaml:"sslmode"`
+	SSLRootCert     string        `yaml:"sslrootcert"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// AutoMigrate applies pending migrations on startup.
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// DSN returns the lib/pq connection URL, including the password.
+func (c DatabaseConfig) DSN() string {
+	user := url.User(c.User)
+	if c.Password != "" {
+		user = url.UserPassword(c.User, string(c.Password))
+	}
+	q := url.Values{}
+	q.Set("sslmode", c.SSLMode)
+	if c.SSLRootCert != "" {
+		q.Set("sslrootcert", c.SSLRootCert)
+	}
+	if c.ConnectTimeout > 0 {
+		q.Set("connect_timeout", strconv.Itoa(int(c.ConnectTimeout.Seconds())))
+	}
+	dsn := url.URL{Scheme: "postgres", User: user, Host: c.HostPort, Path: "/" + c.Name, RawQuery: q.Encode()}
+	return dsn.String()
+}
+
+type AuthConfig struct {
+	// KeyAlgorithm signs access tokens: RS256 or EdDSA.
+	KeyAlgorithm        string        `yaml:"key_algorithm"`
+	KeyRotationInterval time.Duration `yaml:"key_rotation_interval"`
+	KeyOverlap          time.Duration `yaml:"key_overlap"`
+	// PolicyFile loads policies from a YAML file instead of the policies
+	// table.
+	PolicyFile           string        `yaml:"policy_file"`
+	PolicyReloadInterval time.Duration `yaml:"policy_reload_interval"`
+	BcryptCost           int           `yaml:"bcrypt_cost"`
+	MFAIssuer            string        `yaml:"mfa_issuer"`
+	// AppURL is the base URL for links in emails.
+	AppURL string `yaml:"app_url"`
+}
+
+// OIDCConfig enables OpenID Connect login when IssuerURL is set.
+type OIDCConfig struct {
+	IssuerURL    string `yaml:"issuer_url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret Secret `yaml:"client_secret"`
+	RedirectURL  string `yaml:"redirect_url"`
+	ClaimMapping string `yaml:"claim_mapping"`
+}
+
+// MailConfig sends mail through SMTPAddr when set; otherwise it is appended
+// to File, or logged.
+type MailConfig struct {
+	SMTPAddr     string `yaml:"smtp_addr"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword Secret `yaml:"smtp_password"`
+	From         string `yaml:"from"`
+	File         string `yaml:"file"`
+}
+
+// EventsConfig controls the delivery of domain events from the outbox.
+// Events are POSTed to WebhookURL when set; otherwise they are published to
+// in-process subscribers.
+type EventsConfig struct {
+	WebhookURL     string        `yaml:"webhook_url"`
+	WebhookSecret  Secret        `yaml:"webhook_secret"`
+	WebhookTimeout time.Duration `yaml:"webhook_timeout"`
+	RelayInterval  time.Duration `yaml:"relay_interval"`
+	RelayBatchSize int           `yaml:"relay_batch_size"`
+	// MaxAttempts is how often delivery of an event is tried before it is
+	// moved to the dead letters.
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+// Default returns the built-in settings, suitable for local development
+// against a database without a password.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			HTTPAddr:        ":8080",
+			GRPCAddr:        ":50051",
+			DebugAddr:       "localhost:6060",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+			IdempotencyTTL:  24 * time.Hour,
+		},
+		Database: DatabaseConfig{
+			User:            "admin",
+			HostPort:        "localhost:5432",
+			Name:            "ordersdb",
+			SSLMode:         "disable",
+			ConnectTimeout:  5 * time.Second,
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Auth: AuthConfig{
+			KeyAlgorithm:         "RS256",
+			KeyRotationInterval:  30 * 24 * time.Hour,
+			KeyOverlap:           24 * time.Hour,
+			PolicyReloadInterval: 10 * time.Second,
+			BcryptCost:           12,
+			MFAIssuer:            "hex-postgres-grpc",
+		},
+		Events: EventsConfig{
+			WebhookTimeout: 10 * time.Second,
+			RelayInterval:  time.Second,
+			RelayBatchSize: 100,
+			MaxAttempts:    10,
+		},
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validAddr(c.Server.HTTPAddr), "server.http_addr: invalid address %q", c.Server.HTTPAddr)
+	check(validAddr(c.Server.GRPCAddr), "server.grpc_addr: invalid address %q", c.Server.GRPCAddr)
+	check(c.Server.HTTPAddr != c.Server.GRPCAddr, "server.grpc_addr: must differ from server.http_addr")
+	if c.Server.DebugAddr != "" {
+		check(validAddr(c.Server.DebugAddr), "server.debug_addr: invalid address %q", c.Server.DebugAddr)
+		check(c.Server.DebugAddr != c.Server.HTTPAddr && c.Server.DebugAddr != c.Server.GRPCAddr, "server.debug_addr: must differ from server.http_addr and server.grpc_addr")
+	}
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout: must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout: must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout: must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout: must be positive")
+	check(c.Server.IdempotencyTTL > 0, "server.idempotency_ttl: must be positive")
+	check((c.Server.TLS.CertFile == "") == (c.Server.TLS.KeyFile == ""), "server.tls: cert_file and key_file must be set together")
+
+	check(c.Database.User != "", "database.user: required")
+	check(validAddr(c.Database.HostPort) && !strings.HasPrefix(c.Database.HostPort, ":"), "database.host: must have the form host:port, got %q", c.Database.HostPort)
+	check(c.Database.Name != "", "database.name: required")
+	switch c.Database.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		check(false, "database.sslmode: unsupported value %q", c.Database.SSLMode)
+	}
+	check(c.Database.ConnectTimeout >= 0, "database.connect_timeout: must not be negative")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns: must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns: must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns, "database.max_idle_conns: must not exceed max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime: must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time: must not be negative")
+
+	check(c.Auth.KeyAlgorithm == "RS256" || c.Auth.KeyAlgorithm == "EdDSA", "auth.key_algorithm: must be RS256 or EdDSA, got %q", c.Auth.KeyAlgorithm)
+	check(c.Auth.KeyRotationInterval > 0, "auth.key_rotation_interval: must be positive")
+	check(c.Auth.KeyOverlap >= 0, "auth.key_overlap: must not be negative")
+	check(c.Auth.PolicyReloadInterval > 0, "auth.policy_reload_interval: must be positive")
+	check(c.Auth.BcryptCost >= 10 && c.Auth.BcryptCost <= 31, "auth.bcrypt_cost: must be between 10 and 31")
+
+	if c.OIDC.IssuerURL != "" {
+		check(c.OIDC.ClientID != "", "oidc.client_id: required with oidc.issuer_url")
+		check(c.OIDC.RedirectURL != "", "oidc.redirect_url: required with oidc.issuer_url")
+	}
+	if c.Mail.SMTPAddr != "" {
+		check(c.Mail.From != "", "mail.from: required with mail.smtp_addr")
+	}
+
+	if c.Events.WebhookURL != "" {
+		u, err := url.Parse(c.Events.WebhookURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "events.webhook_url: must be an http or https URL, got %q", c.Events.WebhookURL)
+	}
+	check(c.Events.WebhookTimeout > 0, "events.webhook_timeout: must be positive")
+	check(c.Events.RelayInterval > 0, "events.relay_interval: must be positive")
+	check(c.Events.RelayBatchSize > 0, "events.relay_batch_size: must be positive")
+	check(c.Events.MaxAttempts > 0, "events.max_attempts: must be positive")
+
+	return errors.Join(errs...)
+}
+
+// String renders the configuration as YAML with secrets redacted.
+func (c Config) String() string {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("config: %v", err)
+	}
+	return string(out)
+}
+
+func validAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && port != ""
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FileEnv names the environment variable that points to a YAML file, as an
+// alternative to the -config flag.
+const FileEnv = "CONFIG_FILE"
+
+// setting binds one field of Config to an environment variable and a flag.
+// Secrets have no flag so that they do not show up in process listings.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	field func(c *Config) interface{}
+}
+
+var settings = []setting{
+	{"HTTP_ADDR", "http-addr", "HTTP listen address", func(c *Config) interface{} { return &c.Server.HTTPAddr }},
+	{"GRPC_ADDR", "grpc-addr", "gRPC listen address", func(c *Config) interface{} { return &c.Server.GRPCAddr }},
+	{"DEBUG_ADDR", "debug-addr", "internal listen address for /debug/vars metrics; empty disables it", func(c *Config) interface{} { return &c.Server.DebugAddr }},
+	{"HTTP_READ_TIMEOUT", "http-read-timeout", "HTTP read timeout", func(c *Config) interface{} { return &c.Server.ReadTimeout }},
+	{"HTTP_WRITE_TIMEOUT", "http-write-timeout", "HTTP write timeout", func(c *Config) interface{} { return &c.Server.WriteTimeout }},
+	{"HTTP_IDLE_TIMEOUT", "http-idle-timeout", "HTTP keep-alive idle timeout", func(c *Config) interface{} { return &c.Server.IdleTimeout }},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time allowed for in-flight requests on shutdown", func(c *Config) interface{} { return &c.Server.ShutdownTimeout }},
+	{"IDEMPOTENCY_TTL", "idempotency-ttl", "how long responses to requests with an Idempotency-Key are kept", func(c *Config) interface{} { return &c.Server.IdempotencyTTL }},
+	{"TLS_CERT_FILE", "tls-cert-file", "TLS certificate for the HTTP and gRPC listeners", func(c *Config) interface{} { return &c.Server.TLS.CertFile }},
+	{"TLS_KEY_FILE", "tls-key-file", "TLS private key for the HTTP and gRPC listeners", func(c *Config) interface{} { return &c.Server.TLS.KeyFile }},
+
+	{"DB_USER", "db-user", "database user", func(c *Config) interface{} { return &c.Database.User }},
+	{"DB_PASSWORD", "", "database password", func(c *Config) interface{} { return &c.Database.Password }},
+	{"DB_HOST", "db-host", "database host:port", func(c *Config) interface{} { return &c.Database.HostPort }},
+	{"DB_NAME", "db-name", "database name", func(c *Config) interface{} { return &c.Database.Name }},
+	{"DB_SSLMODE", "db-sslmode", "database sslmode: disable, require, verify-ca or verify-full", func(c *Config) interface{} { return &c.Database.SSLMode }},
+	{"DB_SSLROOTCERT", "db-sslrootcert", "CA certificate for verifying the database server", func(c *Config) interface{} { return &c.Database.SSLRootCert }},
+	{"DB_CONNECT_TIMEOUT", "db-connect-timeout", "database connect timeout", func(c *Config) interface{} { return &c.Database.ConnectTimeout }},
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections, 0 for unlimited", func(c *Config) interface{} { return &c.Database.MaxOpenConns }},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", func(c *Config) interface{} { return &c.Database.MaxIdleConns }},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum lifetime of a database connection", func(c *Config) interface{} { return &c.Database.ConnMaxLifetime }},
+	{"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "maximum idle time of a database connection", func(c *Config) interface{} { return &c.Database.ConnMaxIdleTime }},
+	{"DB_AUTO_MIGRATE", "db-auto-migrate", "apply pending migrations on startup", func(c *Config) interface{} { return &c.Database.AutoMigrate }},
+
+	{"AUTH_KEY_ALGORITHM", "auth-key-algorithm", "token signing algorithm: RS256 or EdDSA", func(c *Config) interface{} { return &c.Auth.KeyAlgorithm }},
+	{"AUTH_KEY_ROTATION_INTERVAL", "auth-key-rotation-interval", "how long a signing key is used", func(c *Config) interface{} { return &c.Auth.KeyRotationInterval }},
+	{"AUTH_KEY_OVERLAP", "auth-key-overlap", "how long a retired signing key still verifies tokens", func(c *Config) interface{} { return &c.Auth.KeyOverlap }},
+	{"POLICY_FILE", "policy-file", "load policies from this YAML file instead of the database", func(c *Config) interface{} { return &c.Auth.PolicyFile }},
+	{"POLICY_RELOAD_INTERVAL", "policy-reload-interval", "how often policies are reloaded", func(c *Config) interface{} { return &c.Auth.PolicyReloadInterval }},
+	{"BCRYPT_COST", "bcrypt-cost", "bcrypt cost for password hashes", func(c *Config) interface{} { return &c.Auth.BcryptCost }},
+	{"MFA_ISSUER", "mfa-issuer", "issuer shown in authenticator apps", func(c *Config) interface{} { return &c.Auth.MFAIssuer }},
+	{"APP_URL", "app-url", "base URL for links in emails", func(c *Config) interface{} { return &c.Auth.AppURL }},
+
+	{"OIDC_ISSUER_URL", "oidc-issuer-url", "OpenID Connect issuer; enables OIDC login", func(c *Config) interface{} { return &c.OIDC.IssuerURL }},
+	{"OIDC_CLIENT_ID", "oidc-client-id", "OpenID Connect client ID", func(c *Config) interface{} { return &c.OIDC.ClientID }},
+	{"OIDC_CLIENT_SECRET", "", "OpenID Connect client secret", func(c *Config) interface{} { return &c.OIDC.ClientSecret }},
+	{"OIDC_REDIRECT_URL", "oidc-redirect-url", "OpenID Connect callback URL", func(c *Config) interface{} { return &c.OIDC.RedirectURL }},
+	{"OIDC_CLAIM_MAPPING", "oidc-claim-mapping", "YAML file mapping OIDC claims to users", func(c *Config) interface{} { return &c.OIDC.ClaimMapping }},
+
+	{"SMTP_ADDR", "smtp-addr", "SMTP server host:port; enables sending mail", func(c *Config) interface{} { return &c.Mail.SMTPAddr }},
+	{"SMTP_USERNAME", "smtp-username", "SMTP user", func(c *Config) interface{} { return &c.Mail.SMTPUsername }},
+	{"SMTP_PASSWORD", "", "SMTP password", func(c *Config) interface{} { return &c.Mail.SMTPPassword }},
+	{"SMTP_FROM", "smtp-from", "sender address of mail", func(c *Config) interface{} { return &c.Mail.From }},
+	{"MAIL_FILE", "mail-file", "append mail to this file when SMTP is not configured", func(c *Config) interface{} { return &c.Mail.File }},
+
+	{"EVENTS_WEBHOOK_URL", "events-webhook-url", "POST domain events to this URL instead of publishing them in process", func(c *Config) interface{} { return &c.Events.WebhookURL }},
+	{"EVENTS_WEBHOOK_SECRET", "", "HMAC key signing webhook requests", func(c *Config) interface{} { return &c.Events.WebhookSecret }},
+	{"EVENTS_WEBHOOK_TIMEOUT", "events-webhook-timeout", "timeout of a webhook request", func(c *Config) interface{} { return &c.Events.WebhookTimeout }},
+	{"EVENTS_RELAY_INTERVAL", "events-relay-interval", "how often the outbox is polled for events to publish", func(c *Config) interface{} { return &c.Events.RelayInterval }},
+	{"EVENTS_RELAY_BATCH_SIZE", "events-relay-batch-size", "events published per poll of the outbox", func(c *Config) interface{} { return &c.Events.RelayBatchSize }},
+	{"EVENTS_MAX_ATTEMPTS", "events-max-attempts", "delivery attempts before an event is dead-lettered", func(c *Config) interface{} { return &c.Events.MaxAttempts }},
+}
+
+// Load builds the configuration from, in increasing order of precedence:
+//
+//  1. the defaults of Default,
+//  2. the YAML file named by the -config flag or CONFIG_FILE, if any,
+//  3. environment variables,
+//  4. command-line flags in args (typically os.Args[1:]).
+//
+// The result is validated. The arguments left after the flags, such as a
+// subcommand, are returned as well. flag.ErrHelp is returned when args ask
+// for help.
+func Load(args []string) (Config, []string, error) {
+	cfg := Default()
+
+	// Flags are applied last, but the file they may name is read first.
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv(FileEnv), "YAML configuration file (env "+FileEnv+")")
+	var flags []func(*Config) error
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		s := s
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		parse := func(v string) error {
+			if err := set(s.field(&Config{}), v); err != nil {
+				return err
+			}
+			flags = append(flags, func(c *Config) error { return set(s.field(c), v) })
+			return nil
+		}
+		if _, ok := s.field(&Config{}).(*bool); ok {
+			fs.BoolFunc(s.flag, usage, parse)
+		} else {
+			fs.Func(s.flag, usage, parse)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *file != "" {
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+			return Config{}, nil, fmt.Errorf("parse config file %s: %w", *file, err)
+		}
+	}
+
+	for _, s := range settings {
+		v, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err := set(s.field(&cfg), v); err != nil {
+			return Config{}, nil, fmt.Errorf("%s: %w", s.env, err)
+		}
+	}
+
+	for _, apply := range flags {
+		if err := apply(&cfg); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, fs.Args(), nil
+}
+
+func set(field interface{}, v string) error {
+	switch f := field.(type) {
+	case *string:
+		*f = v
+	case *Secret:
+		*f = Secret(v)
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		*f = n
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*f = b
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*f = d
+	default:
+		return fmt.Errorf("unsupported setting type %T", field)
+	}
+	return nil
+}
@@ -59,11 +59,7 @@ func (s *Server) GetCustomer(ctx context.Context, req *customerpb.GetCustomerReq
 
 	c, err := s.service.GetCustomer(ctx, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "get customer: %v", err)
-	}
-
-	if c == nil {
-		return nil, status.Error(codes.NotFound, "customer not found")
+		return nil, err
 	}
 
 	// ABAC check: Owner or Admin
@@ -103,7 +99,7 @@ func (s *Server) ListCustomers(ctx context.Context, req *customerpb.ListCustomer
 
 	customers, err := s.service.ListCustomers(ctx)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "list customers: %v", err)
+		return nil, err
 	}
 
 	var msgs []*customerpb.CustomerMessage
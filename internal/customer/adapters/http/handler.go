@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"hex-postgres-grpc/internal/auth"
+	commonhttp "hex-postgres-grpc/internal/common/adapters/http"
 	"hex-postgres-grpc/internal/customer/domain"
 	"net/http"
 )
@@ -22,7 +23,7 @@ func NewHandler(service domain.Service, auth auth.Service) *Handler {
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /customer", h.Create)
 	mux.HandleFunc("GET /customer", h.List)
-	mux.HandleFunc("GET /customer/:id", h.Get)
+	mux.HandleFunc("GET /customer/{id}", h.Get)
 }
 
 // List returns all customers
@@ -32,25 +33,25 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {array} domain.Customer
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
 // @Router /customer [get]
 func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionRead, auth.Resource{Type: "customer"})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
 	customers, err := h.service.ListCustomers(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
@@ -67,20 +68,20 @@ func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param request body object{name=string,email=string,address=string} true "Create Customer Request"
 // @Success 200 {object} domain.Customer
-// @Failure 400 {string} string "bad request"
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
+// @Failure 400 {object} commonhttp.Problem "bad request"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
 // @Router /customer [post]
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionCreate, auth.Resource{Type: "customer"})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
@@ -91,13 +92,13 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 	cust, err := h.service.CreateCustomer(r.Context(), req.Name, req.Email, req.Address)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
@@ -113,21 +114,21 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param id path string true "Customer ID"
 // @Success 200 {object} domain.Customer
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
-// @Failure 404 {string} string "not found"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
 // @Router /customer/{id} [get]
 func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
-	id := r.URL.Query().Get("id")
+	id := r.PathValue("id")
 	cust, err := h.service.GetCustomer(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
@@ -140,7 +141,7 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
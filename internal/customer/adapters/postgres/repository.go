@@ -3,20 +3,28 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/tenant"
 	"hex-postgres-grpc/internal/customer/domain"
 )
 
 type Repository struct {
-	db *sql.DB
+	db *commonpg.DB
 }
 
 func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: commonpg.Wrap(db)}
 }
 
 func (r *Repository) Save(ctx context.Context, c *domain.Customer) error {
-	query := "INSERT INTO customers (id, name, email, address, created_at) VALUES ($1, $2, $3, $4, $5)"
-	_, err := r.db.ExecContext(ctx, query, c.ID, c.Name, c.Email, c.Address, c.CreatedAt)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+	c.TenantID = tenantID
+
+	query := "INSERT INTO customers (id, tenant_id, name, email, address, created_at, created_by, created_by_actor) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+	_, err = r.db.ExecContext(ctx, query, c.ID, c.TenantID, c.Name, c.Email, c.Address, c.CreatedAt, c.CreatedBy, c.CreatedByActor)
 
 	// rows, _ := res.RowsAffected()
 	// fmt.Println(rows)
@@ -24,14 +32,19 @@ func (r *Repository) Save(ctx context.Context, c *domain.Customer) error {
 }
 
 func (r *Repository) FindByID(ctx context.Context, id string) (*domain.Customer, error) {
-	query := "SELECT id, name, email, address, created_at FROM customers WHERE id = $1"
-	row := r.db.QueryRowContext(ctx, query, id)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT id, tenant_id, name, email, address, created_at, created_by, created_by_actor FROM customers WHERE id = $1 AND tenant_id = $2"
+	row := r.db.QueryRowContext(ctx, query, id, tenantID)
 
 	var c domain.Customer
-	err := row.Scan(&c.ID, &c.Name, &c.Email, &c.Address, &c.CreatedAt)
+	err = row.Scan(&c.ID, &c.TenantID, &c.Name, &c.Email, &c.Address, &c.CreatedAt, &c.CreatedBy, &c.CreatedByActor)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, nil // or specific not found error
+			return nil, domain.ErrNotFound
 		}
 		return nil, err
 	}
@@ -39,8 +52,13 @@ func (r *Repository) FindByID(ctx context.Context, id string) (*domain.Customer,
 }
 
 func (r *Repository) FindAll(ctx context.Context) ([]domain.Customer, error) {
-	query := "SELECT id, name, email, address, created_at FROM customers"
-	rows, err := r.db.QueryContext(ctx, query)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT id, tenant_id, name, email, address, created_at, created_by, created_by_actor FROM customers WHERE tenant_id = $1"
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +67,7 @@ func (r *Repository) FindAll(ctx context.Context) ([]domain.Customer, error) {
 	customers := []domain.Customer{}
 	for rows.Next() {
 		var c domain.Customer
-		err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Address, &c.CreatedAt)
+		err := rows.Scan(&c.ID, &c.TenantID, &c.Name, &c.Email, &c.Address, &c.CreatedAt, &c.CreatedBy, &c.CreatedByActor)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/adapters/postgres/pgtest"
+	domain_common "hex-postgres-grpc/internal/common/domain"
+	"hex-postgres-grpc/internal/customer/domain"
+	"hex-postgres-grpc/internal/customer/usecase"
+
+	"github.com/google/uuid"
+)
+
+// Customers cannot be updated or deleted, so only reads are checked.
+
+func TestRepositoryTenantIsolation(t *testing.T) {
+	repo := NewRepository(pgtest.Open(t))
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	id := uuid.NewString()
+	c := &domain.Customer{
+		BaseEntity: domain_common.BaseEntity{ID: id, CreatedAt: time.Now()},
+		Name:       "Ann",
+		Email:      id + "@example.com",
+		Address:    "1 Main St",
+	}
+	if err := repo.Save(ctxA, c); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if _, err := repo.FindByID(ctxB, c.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("FindByID in other tenant: %v, want ErrNotFound", err)
+	}
+	list, err := repo.FindAll(ctxB)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("other tenant lists %d customers, want none", len(list))
+	}
+
+	// Email addresses are unique per tenant only.
+	other := *c
+	other.ID = uuid.NewString()
+	if err := repo.Save(ctxB, &other); err != nil {
+		t.Fatalf("Save with the same email in other tenant: %v", err)
+	}
+
+	if got, err := repo.FindByID(ctxA, c.ID); err != nil || got.Name != "Ann" {
+		t.Fatalf("FindByID in own tenant = %+v, %v", got, err)
+	}
+	if list, err := repo.FindAll(ctxA); err != nil || len(list) != 1 || list[0].ID != c.ID {
+		t.Fatalf("own tenant lists %+v (%v), want only its customer", list, err)
+	}
+}
+
+func TestServiceTenantIsolation(t *testing.T) {
+	db := pgtest.Open(t)
+	svc := usecase.NewService(NewRepository(db), commonpg.NewTxManager(db, commonpg.TxConfig{}), commonpg.NewOutboxStore(db))
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	c, err := svc.CreateCustomer(ctxA, "Ann", uuid.NewString()+"@example.com", "1 Main St")
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+
+	if _, err := svc.GetCustomer(ctxB, c.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetCustomer in other tenant: %v, want ErrNotFound", err)
+	}
+	list, err := svc.ListCustomers(ctxB)
+	if err != nil {
+		t.Fatalf("ListCustomers: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("other tenant lists %d customers, want none", len(list))
+	}
+
+	if _, err := svc.GetCustomer(ctxA, c.ID); err != nil {
+		t.Fatalf("GetCustomer in own tenant: %v", err)
+	}
+}
+
+func TestServiceRecordsAuthor(t *testing.T) {
+	db := pgtest.Open(t)
+	svc := usecase.NewService(NewRepository(db), commonpg.NewTxManager(db, commonpg.TxConfig{}), commonpg.NewOutboxStore(db))
+	sub := auth.Subject{ID: uuid.NewString(), Role: "user", ActorID: uuid.NewString()}
+	ctx := context.WithValue(pgtest.Tenant(), auth.SubjectContextKey, sub)
+
+	c, err := svc.CreateCustomer(ctx, "Ann", uuid.NewString()+"@example.com", "1 Main St")
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	got, err := svc.GetCustomer(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("GetCustomer: %v", err)
+	}
+	if got.CreatedBy != sub.ID || got.CreatedByActor == nil || *got.CreatedByActor != sub.ActorID {
+		t.Fatalf("customer created by %q (actor %v), want %q (actor %q)", got.CreatedBy, got.CreatedByActor, sub.ID, sub.ActorID)
+	}
+}
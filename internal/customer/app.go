@@ -3,25 +3,30 @@ package customer
 import (
 	"database/sql"
 	"hex-postgres-grpc/internal/auth"
+	"hex-postgres-grpc/internal/common/events"
+	"hex-postgres-grpc/internal/common/uow"
 	"hex-postgres-grpc/internal/customer/adapters/grpc"
 	"hex-postgres-grpc/internal/customer/adapters/http"
 	"hex-postgres-grpc/internal/customer/adapters/postgres"
+	"hex-postgres-grpc/internal/customer/domain"
 	"hex-postgres-grpc/internal/customer/usecase"
 )
 
 type Components struct {
+	Service     domain.Service
 	HTTPHandler *http.Handler
 	GRPCServer  *grpc.Server
 }
 
-func Init(db *sql.DB, authSvc auth.Service) Components {
+func Init(db *sql.DB, authSvc auth.Service, unitOfWork uow.UnitOfWork, outbox events.Outbox) Components {
 	repo := postgres.NewRepository(db)
-	service := usecase.NewService(repo)
+	service := usecase.NewService(repo, unitOfWork, outbox)
 
 	httpHandler := http.NewHandler(service, authSvc)
 	grpcServer := grpc.NewServer(service, authSvc)
 
 	return Components{
+		Service:     service,
 		HTTPHandler: httpHandler,
 		GRPCServer:  grpcServer,
 	}
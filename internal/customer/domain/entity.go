@@ -1,9 +1,17 @@
 package domain
 
 import (
+	"hex-postgres-grpc/internal/common/apperr"
 	domain_common "hex-postgres-grpc/internal/common/domain"
 )
 
+var (
+	ErrNotFound        = apperr.New(apperr.NotFound, "CUSTOMER_NOT_FOUND", "customer not found")
+	ErrNameRequired    = apperr.NewField("name", "NAME_REQUIRED", "name is required")
+	ErrEmailRequired   = apperr.NewField("email", "EMAIL_REQUIRED", "email is required")
+	ErrAddressRequired = apperr.NewField("address", "ADDRESS_REQUIRED", "address is required")
+)
+
 type Customer struct {
 	domain_common.BaseEntity
 	Name    string `json:"name"`
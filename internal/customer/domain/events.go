@@ -0,0 +1,14 @@
+package domain
+
+// Event types raised by customers, and the payloads they carry.
+const (
+	AggregateType = "customer"
+
+	EventCustomerRegistered = "customer.registered"
+)
+
+type CustomerRegistered struct {
+	CustomerID string `json:"customer_id"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+}
@@ -2,8 +2,10 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"hex-postgres-grpc/internal/auth"
 	domain_common "hex-postgres-grpc/internal/common/domain"
+	"hex-postgres-grpc/internal/common/events"
+	"hex-postgres-grpc/internal/common/uow"
 	"hex-postgres-grpc/internal/customer/domain"
 	"time"
 
@@ -11,35 +13,60 @@ import (
 )
 
 type service struct {
-	repo domain.Repository
+	repo   domain.Repository
+	uow    uow.UnitOfWork
+	outbox events.Outbox
 }
 
-func NewService(repo domain.Repository) domain.Service {
-	return &service{repo: repo}
+// NewService records a CustomerRegistered event in outbox, in the unit of
+// work that saves the customer.
+func NewService(repo domain.Repository, unitOfWork uow.UnitOfWork, outbox events.Outbox) domain.Service {
+	return &service{repo: repo, uow: unitOfWork, outbox: outbox}
 }
 
 func (s *service) CreateCustomer(ctx context.Context, name, email, address string) (*domain.Customer, error) {
 	if name == "" {
-		return nil, errors.New("name is required")
+		return nil, domain.ErrNameRequired
 	}
 	if email == "" {
-		return nil, errors.New("email is required")
+		return nil, domain.ErrEmailRequired
 	}
 	if address == "" {
-		return nil, errors.New("address is required")
+		return nil, domain.ErrAddressRequired
+	}
+
+	sub, _ := auth.SubjectFromContext(ctx)
+	createdBy := domain_common.SystemUserID
+	if sub.ID != "" {
+		createdBy = sub.ID
 	}
 
 	id := uuid.NewString()
 	customer := domain.Customer{
 		BaseEntity: domain_common.BaseEntity{
-			ID:        id,
-			CreatedAt: time.Now(),
+			ID:             id,
+			CreatedAt:      time.Now(),
+			CreatedBy:      createdBy,
+			CreatedByActor: sub.Actor(),
 		},
 		Name:    name,
 		Email:   email,
 		Address: address,
 	}
-	err := s.repo.Save(ctx, &customer)
+	event, err := events.New(domain.EventCustomerRegistered, domain.AggregateType, id, domain.CustomerRegistered{
+		CustomerID: id,
+		Name:       name,
+		Email:      email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.Save(ctx, &customer); err != nil {
+			return err
+		}
+		return s.outbox.Record(ctx, event)
+	})
 	if err != nil {
 		return nil, err
 	}
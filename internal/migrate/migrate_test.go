@@ -0,0 +1,132 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"hex-postgres-grpc/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_add_email.sql":         {Data: []byte("ALTER TABLE users ADD email TEXT;")},
+		"001_create_users.sql":      {Data: []byte("CREATE TABLE users (id INT);")},
+		"001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"README.md":                 {Data: []byte("not a migration")},
+		"optional/extra.sql":        {Data: []byte("SELECT 1;")},
+	}
+	got, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Load() returned %d migrations, want 2", len(got))
+	}
+
+	sum := sha256.Sum256([]byte("CREATE TABLE users (id INT);"))
+	first := got[0]
+	if first.Version != 1 || first.Name != "create_users" || first.Down != "DROP TABLE users;" || first.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("first migration = %+v", first)
+	}
+	if second := got[1]; second.Version != 2 || second.Name != "add_email" || second.Down != "" {
+		t.Errorf("second migration = %+v", second)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"no version", fstest.MapFS{"create_users.sql": {}}},
+		{"zero version", fstest.MapFS{"000_create_users.sql": {}}},
+		{"conflicting names", fstest.MapFS{"001_create_users.sql": {Data: []byte("x")}, "001_create_accounts.down.sql": {}}},
+		{"down without up", fstest.MapFS{"001_create_users.down.sql": {Data: []byte("x")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.fsys); err == nil {
+				t.Fatal("Load succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestLoadSchemaMigrations(t *testing.T) {
+	got, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for i, m := range got {
+		if m.Version != i+1 {
+			t.Fatalf("migration %03d_%s follows version %d; versions must not have gaps", m.Version, m.Name, i)
+		}
+	}
+}
+
+func testMigrator() *Migrator {
+	return &Migrator{migrations: []Migration{
+		{Version: 1, Name: "create_users", Up: "up 1", Down: "down 1", Checksum: "c1"},
+		{Version: 2, Name: "hash_passwords", Up: "up 2", Checksum: "c2"},
+		{Version: 3, Name: "add_email", Up: "up 3", Down: "down 3", Checksum: "c3"},
+		{Version: 4, Name: "add_status", Up: "up 4", Down: "down 4", Checksum: "c4"},
+	}}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied map[int]appliedMigration
+		wantErr error
+	}{
+		{"nothing applied", map[int]appliedMigration{}, nil},
+		{"unchanged", map[int]appliedMigration{1: {checksum: "c1"}, 2: {checksum: "c2"}}, nil},
+		{"modified", map[int]appliedMigration{1: {checksum: "c1"}, 2: {checksum: "edited"}}, ErrChecksumMismatch},
+		{"unknown to the binary", map[int]appliedMigration{1: {checksum: "c1"}, 9: {checksum: "c9"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testMigrator().verify(tt.applied)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReverts(t *testing.T) {
+	all := map[int]appliedMigration{1: {}, 2: {}, 3: {}, 4: {}}
+	tests := []struct {
+		name    string
+		applied map[int]appliedMigration
+		steps   int
+		want    []int
+		wantErr error
+	}{
+		{"latest", all, 1, []int{4}, nil},
+		{"irreversible within steps", all, 3, nil, ErrIrreversible},
+		{"irreversible beyond steps", all, 2, []int{4, 3}, nil},
+		{"skips pending", map[int]appliedMigration{1: {}, 2: {}, 3: {}}, 1, []int{3}, nil},
+		{"fewer applied than steps", map[int]appliedMigration{1: {}}, 5, []int{1}, nil},
+		{"nothing applied", map[int]appliedMigration{}, 1, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testMigrator().reverts(tt.applied, tt.steps)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("reverts() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("reverts() = %+v, want versions %v", got, tt.want)
+			}
+			for i, v := range tt.want {
+				if got[i].Version != v {
+					t.Fatalf("reverts() = %+v, want versions %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
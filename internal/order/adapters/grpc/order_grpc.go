@@ -2,85 +2,240 @@ package grpc
 
 import (
 	"context"
+	"hex-postgres-grpc/internal/auth"
+	domain_common "hex-postgres-grpc/internal/common/domain"
 	order "hex-postgres-grpc/internal/order/domain"
 	orderpb "hex-postgres-grpc/proto/order"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// Server serves orders over gRPC. The permission to call each method is
+// checked by the auth interceptor; Server additionally checks the owner of
+// the orders it reads or changes.
 type Server struct {
 	orderpb.UnimplementedORderServiceServer
-	svc order.Service
+	svc  order.Service
+	auth auth.Service
 }
 
-func NewOrderGRPCServer(svc order.Service) *Server {
-	return &Server{svc: svc}
+func NewOrderGRPCServer(svc order.Service, authSvc auth.Service) *Server {
+	return &Server{svc: svc, auth: authSvc}
 }
 
 func (s *Server) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.CreateOrderResponse, error) {
-	o, err := s.svc.CreateOrder(ctx, req.Amount)
+	o, err := s.svc.CreateOrder(ctx, req.CustomerId, toLineItems(req.Items))
 	if err != nil {
-
 		return nil, err
 	}
-	return &orderpb.CreateOrderResponse{
-		Order: &orderpb.OrderMessage{
-			Id:        o.ID,
-			Amount:    o.Amount,
-			CreatedAt: timestamppb.New(o.CreatedAt),
-		},
-	}, nil
+	return &orderpb.CreateOrderResponse{Order: toOrderMessage(o)}, nil
 }
 
 func (s *Server) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.GetOrderResponse, error) {
-	o, err := s.svc.GetOrder(ctx, req.Id)
+	o, err := s.authorizedOrder(ctx, auth.ActionRead, req.Id)
 	if err != nil {
 		return nil, err
 	}
-	return &orderpb.GetOrderResponse{
-		Order: &orderpb.OrderMessage{
-			Id:        o.ID,
-			Amount:    o.Amount,
-			CreatedAt: timestamppb.New(o.CreatedAt),
-		},
-	}, nil
+	return &orderpb.GetOrderResponse{Order: toOrderMessage(o)}, nil
 }
 
 func (s *Server) UpdateOrder(ctx context.Context, req *orderpb.UpdateOrderRequest) (*orderpb.UpdateOrderResponse, error) {
-	o, err := s.svc.UpdateOrder(ctx, req.Id, req.Amount)
+	version, err := domain_common.ParseETag(req.Etag)
 	if err != nil {
 		return nil, err
 	}
-	return &orderpb.UpdateOrderResponse{
-		Order: &orderpb.OrderMessage{
-			Id:        o.ID,
-			Amount:    o.Amount,
-			CreatedAt: timestamppb.New(o.CreatedAt),
-		},
-	}, nil
+
+	if _, err := s.authorizedOrder(ctx, auth.ActionUpdate, req.Id); err != nil {
+		return nil, err
+	}
+
+	o, err := s.svc.UpdateOrder(ctx, req.Id, toLineItems(req.Items), version)
+	if err != nil {
+		return nil, err
+	}
+	return &orderpb.UpdateOrderResponse{Order: toOrderMessage(o)}, nil
 }
 
 func (s *Server) DeleteOrder(ctx context.Context, req *orderpb.DeleteOrderRequest) (*orderpb.DeleteOrderResponse, error) {
+	if _, err := s.authorizedOrder(ctx, auth.ActionDelete, req.Id); err != nil {
+		return nil, err
+	}
+
 	if err := s.svc.DeleteOrder(ctx, req.Id); err != nil {
 		return nil, err
 	}
 	return &orderpb.DeleteOrderResponse{Success: true}, nil
 }
 
+func (s *Server) RestoreOrder(ctx context.Context, req *orderpb.RestoreOrderRequest) (*orderpb.RestoreOrderResponse, error) {
+	o, err := s.svc.RestoreOrder(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &orderpb.RestoreOrderResponse{Order: toOrderMessage(o)}, nil
+}
+
 func (s *Server) ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error) {
-	orders, err := s.svc.ListOrders(ctx)
+	owner, err := s.listOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.svc.ListOrders(ctx, req.CustomerId, owner)
 	if err != nil {
 		return nil, err
 	}
 
 	var pbOrders []*orderpb.OrderMessage
 	for _, o := range orders {
-		pbOrders = append(pbOrders, &orderpb.OrderMessage{
-			Id:        o.ID,
-			Amount:    o.Amount,
-			CreatedAt: timestamppb.New(o.CreatedAt),
-		})
+		pbOrders = append(pbOrders, toOrderMessage(o))
 	}
 
 	return &orderpb.ListOrdersResponse{Orders: pbOrders}, nil
 }
+
+func (s *Server) TransitionOrder(ctx context.Context, req *orderpb.TransitionOrderRequest) (*orderpb.TransitionOrderResponse, error) {
+	version, err := domain_common.ParseETag(req.Etag)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.authorizedOrder(ctx, auth.ActionUpdate, req.Id); err != nil {
+		return nil, err
+	}
+
+	o, err := s.svc.TransitionOrder(ctx, req.Id, order.Status(req.Status), req.Reason, version)
+	if err != nil {
+		return nil, err
+	}
+	return &orderpb.TransitionOrderResponse{Order: toOrderMessage(o)}, nil
+}
+
+func (s *Server) ListOrderTransitions(ctx context.Context, req *orderpb.ListOrderTransitionsRequest) (*orderpb.ListOrderTransitionsResponse, error) {
+	if _, err := s.authorizedOrder(ctx, auth.ActionRead, req.Id); err != nil {
+		return nil, err
+	}
+
+	changes, err := s.svc.OrderHistory(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbChanges []*orderpb.StatusChangeMessage
+	for _, c := range changes {
+		pbChanges = append(pbChanges, &orderpb.StatusChangeMessage{
+			From:           string(c.From),
+			To:             string(c.To),
+			Reason:         c.Reason,
+			ChangedBy:      c.ChangedBy,
+			ChangedByActor: stringValue(c.ChangedByActor),
+			ChangedAt:      timestamppb.New(c.ChangedAt),
+		})
+	}
+
+	return &orderpb.ListOrderTransitionsResponse{Transitions: pbChanges}, nil
+}
+
+// authorizedOrder loads an order and checks that the caller may perform act
+// on it, taking its owner into account.
+func (s *Server) authorizedOrder(ctx context.Context, act auth.Action, id string) (order.Order, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return order.Order{}, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	o, err := s.svc.GetOrder(ctx, id)
+	if err != nil {
+		return order.Order{}, err
+	}
+
+	authorized, err := s.auth.Authorize(ctx, sub, act, orderResource(o))
+	if err != nil || !authorized {
+		return order.Order{}, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	return o, nil
+}
+
+// listOwner returns the user whose orders the caller may list: the caller
+// itself, or "" for all users if the policies let it read orders owned by
+// others.
+func (s *Server) listOwner(ctx context.Context) (string, error) {
+	sub, ok := auth.SubjectFromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	all, err := s.auth.Authorize(ctx, sub, auth.ActionRead, othersOrder)
+	if err != nil {
+		return "", err
+	}
+	if all {
+		return "", nil
+	}
+	return sub.ID, nil
+}
+
+// othersOrder describes an order owned by another user than the caller. No
+// user has an empty ID.
+var othersOrder = auth.Resource{
+	Type: "order",
+	Attributes: map[string]interface{}{
+		"owner_id": "",
+	},
+}
+
+// orderResource describes o for authorization. The user who created an
+// order owns it.
+func orderResource(o order.Order) auth.Resource {
+	return auth.Resource{
+		Type: "order",
+		ID:   o.ID,
+		Attributes: map[string]interface{}{
+			"owner_id": o.CreatedBy,
+		},
+	}
+}
+
+func toOrderMessage(o order.Order) *orderpb.OrderMessage {
+	items := make([]*orderpb.LineItem, len(o.Items))
+	for i, item := range o.Items {
+		items[i] = &orderpb.LineItem{
+			ProductId: item.ProductID,
+			Quantity:  int32(item.Quantity),
+			UnitPrice: item.UnitPrice,
+		}
+	}
+	msg := &orderpb.OrderMessage{
+		Id:         o.ID,
+		Amount:     o.Amount,
+		CreatedAt:  timestamppb.New(o.CreatedAt),
+		Etag:       o.ETag(),
+		CustomerId: o.CustomerID,
+		Items:      items,
+		Status:     string(o.Status),
+		CreatedBy:  o.CreatedBy,
+		UpdatedBy:  stringValue(o.UpdatedBy),
+	}
+	if o.UpdatedAt != nil {
+		msg.UpdatedAt = timestamppb.New(*o.UpdatedAt)
+	}
+	return msg
+}
+
+// toLineItems converts requested items; their unit prices are ignored.
+func toLineItems(pbItems []*orderpb.LineItem) []order.LineItem {
+	items := make([]order.LineItem, len(pbItems))
+	for i, item := range pbItems {
+		items[i] = order.LineItem{ProductID: item.GetProductId(), Quantity: int(item.GetQuantity())}
+	}
+	return items
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
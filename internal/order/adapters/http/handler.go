@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"hex-postgres-grpc/internal/auth"
+	commonhttp "hex-postgres-grpc/internal/common/adapters/http"
+	domain_common "hex-postgres-grpc/internal/common/domain"
 	order "hex-postgres-grpc/internal/order/domain"
 )
 
@@ -21,11 +23,33 @@ func NewHandler(svc order.Service, authSvc auth.Service) *Handler {
 }
 
 type CreateOrderRequest struct {
-	Amount float64 `json:"amount"`
+	CustomerID string            `json:"customer_id"`
+	Items      []LineItemRequest `json:"items"`
 }
 
 type UpdateOrderRequest struct {
-	Amount float64 `json:"amount"`
+	Items []LineItemRequest `json:"items"`
+}
+
+type TransitionOrderRequest struct {
+	Status order.Status `json:"status"`
+	// Reason explains the transition. Cancelling and refunding require it.
+	Reason string `json:"reason"`
+}
+
+// LineItemRequest names a product and a quantity. The unit price is taken
+// from the product.
+type LineItemRequest struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+func toLineItems(reqs []LineItemRequest) []order.LineItem {
+	items := make([]order.LineItem, len(reqs))
+	for i, req := range reqs {
+		items[i] = order.LineItem{ProductID: req.ProductID, Quantity: req.Quantity}
+	}
+	return items
 }
 
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
@@ -33,127 +57,243 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /orders/{id}", h.GetOrder)
 	mux.HandleFunc("PUT /orders/{id}", h.UpdateOrder)
 	mux.HandleFunc("DELETE /orders/{id}", h.DeleteOrder)
+	mux.HandleFunc("POST /orders/{id}/restore", h.RestoreOrder)
+	mux.HandleFunc("POST /orders/{id}/transitions", h.TransitionOrder)
+	mux.HandleFunc("GET /orders/{id}/transitions", h.ListOrderTransitions)
 	mux.HandleFunc("GET /orders", h.ListOrders)
 }
 
 // CreateOrder creates a new order
 // @Summary Create Order
-// @Description Create a new order with the given amount
+// @Description Create a new order for a customer. The unit prices of the items are taken from the products and the amount is computed from them.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param Idempotency-Key header string false "Makes retries with the same key and body return the first response instead of creating another order"
 // @Param request body CreateOrderRequest true "Create Order Request"
 // @Success 200 {object} order.Order
-// @Failure 401 {string} string "unauthorized"
+// @Header 200 {string} ETag "Version of the order"
+// @Header 200 {string} Idempotent-Replayed "true when the response is replayed for a retry"
+// @Failure 400 {object} commonhttp.Problem "invalid customer or items"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 409 {object} commonhttp.Problem "idempotency key reused for a different request, or in use"
 // @Router /orders [post]
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r, auth.ActionCreate, auth.Resource{Type: "order"}) {
+		return
+	}
+
 	var req CreateOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	o, err := h.svc.CreateOrder(r.Context(), req.Amount)
+	o, err := h.svc.CreateOrder(r.Context(), req.CustomerID, toLineItems(req.Items))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", o.ETag())
 	json.NewEncoder(w).Encode(o)
 }
 
 // GetOrder returns a single order by ID
 // @Summary Get Order
-// @Description Get details of a single order by ID
+// @Description Get details of a single order by ID. Users of the user role can only read their own orders.
 // @Tags orders
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Order ID"
 // @Success 200 {object} order.Order
-// @Failure 401 {string} string "unauthorized"
-// @Failure 404 {string} string "not found"
+// @Header 200 {string} ETag "Version of the order"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
 // @Router /orders/{id} [get]
 func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if id == "" {
-		http.Error(w, "id parameter required", http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "id parameter required")
 		return
 	}
 
-	o, err := h.svc.GetOrder(r.Context(), id)
-	if err != nil {
-		if err == order.ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	o, ok := h.authorizedOrder(w, r, auth.ActionRead, id)
+	if !ok {
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", o.ETag())
 	json.NewEncoder(w).Encode(o)
 }
 
 // UpdateOrder updates an existing order
 // @Summary Update Order
-// @Description Update the amount of an existing order
+// @Description Replace the items of a pending order at current prices. With If-Match the update only applies to that version of the order.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Order ID"
+// @Param If-Match header string false "ETag of the version being updated"
 // @Param request body UpdateOrderRequest true "Update Order Request"
 // @Success 200 {object} order.Order
-// @Failure 401 {string} string "unauthorized"
-// @Failure 404 {string} string "not found"
+// @Header 200 {string} ETag "Version of the order"
+// @Failure 400 {object} commonhttp.Problem "invalid items or If-Match header, or order is no longer pending"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Failure 409 {object} commonhttp.Problem "order was modified concurrently"
+// @Failure 412 {object} commonhttp.Problem "order has changed since the If-Match version"
 // @Router /orders/{id} [put]
 func (h *Handler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if id == "" {
-		http.Error(w, "id parameter required", http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "id parameter required")
+		return
+	}
+
+	if _, ok := h.authorizedOrder(w, r, auth.ActionUpdate, id); !ok {
+		return
+	}
+
+	version, err := domain_common.ParseETag(r.Header.Get("If-Match"))
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	var req UpdateOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	o, err := h.svc.UpdateOrder(r.Context(), id, req.Amount)
+	o, err := h.svc.UpdateOrder(r.Context(), id, toLineItems(req.Items), version)
 	if err != nil {
-		if err == order.ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", o.ETag())
 	json.NewEncoder(w).Encode(o)
 }
 
+// TransitionOrder moves an order to another status
+// @Summary Transition Order
+// @Description Move an order along its lifecycle: pending → confirmed → paid → shipped → delivered. Pending and confirmed orders can be cancelled, paid and delivered orders refunded; both need a reason. With If-Match the transition only applies to that version of the order.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param If-Match header string false "ETag of the version being updated"
+// @Param request body TransitionOrderRequest true "Transition Order Request"
+// @Success 200 {object} order.Order
+// @Header 200 {string} ETag "Version of the order"
+// @Failure 400 {object} commonhttp.Problem "invalid status, missing reason or transition not allowed"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Failure 409 {object} commonhttp.Problem "order was modified concurrently"
+// @Failure 412 {object} commonhttp.Problem "order has changed since the If-Match version"
+// @Router /orders/{id}/transitions [post]
+func (h *Handler) TransitionOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "id parameter required")
+		return
+	}
+
+	if _, ok := h.authorizedOrder(w, r, auth.ActionUpdate, id); !ok {
+		return
+	}
+
+	version, err := domain_common.ParseETag(r.Header.Get("If-Match"))
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	var req TransitionOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	o, err := h.svc.TransitionOrder(r.Context(), id, req.Status, req.Reason, version)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", o.ETag())
+	json.NewEncoder(w).Encode(o)
+}
+
+// ListOrderTransitions returns the status history of an order
+// @Summary List Order Transitions
+// @Description Get the status changes of an order, oldest first
+// @Tags orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {array} order.StatusChange
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Router /orders/{id}/transitions [get]
+func (h *Handler) ListOrderTransitions(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "id parameter required")
+		return
+	}
+
+	if _, ok := h.authorizedOrder(w, r, auth.ActionRead, id); !ok {
+		return
+	}
+
+	changes, err := h.svc.OrderHistory(r.Context(), id)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
+
 // DeleteOrder deletes an order by ID
 // @Summary Delete Order
-// @Description Delete an order by ID
+// @Description Mark an order as deleted. It can be restored with POST /orders/{id}/restore.
 // @Tags orders
 // @Security BearerAuth
 // @Param id path string true "Order ID"
 // @Success 204 "No Content"
-// @Failure 401 {string} string "unauthorized"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
 // @Router /orders/{id} [delete]
 func (h *Handler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if id == "" {
-		http.Error(w, "id parameter required", http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "id parameter required")
+		return
+	}
+
+	if _, ok := h.authorizedOrder(w, r, auth.ActionDelete, id); !ok {
 		return
 	}
 
 	if err := h.svc.DeleteOrder(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
@@ -162,20 +302,141 @@ func (h *Handler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 
 // ListOrders returns all orders
 // @Summary List Orders
-// @Description Get a list of all orders
+// @Description Get a list of the orders the caller may read, optionally only those of one customer. Callers only see the orders of other users if the policies let them read those orders.
 // @Tags orders
 // @Produce json
 // @Security BearerAuth
+// @Param customer_id query string false "Only orders of this customer"
 // @Success 200 {array} order.Order
-// @Failure 401 {string} string "unauthorized"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
 // @Router /orders [get]
 func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) {
-	orders, err := h.svc.ListOrders(r.Context())
+	if !h.authorize(w, r, auth.ActionRead, auth.Resource{Type: "order"}) {
+		return
+	}
+
+	owner, err := h.listOwner(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	orders, err := h.svc.ListOrders(r.Context(), r.URL.Query().Get("customer_id"), owner)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(orders)
 }
+
+// RestoreOrder undeletes an order
+// @Summary Restore Order
+// @Description Restore a deleted order
+// @Tags orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} order.Order
+// @Header 200 {string} ETag "Version of the order"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "order is not deleted"
+// @Router /orders/{id}/restore [post]
+func (h *Handler) RestoreOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "id parameter required")
+		return
+	}
+
+	// Deleted orders cannot be read, so restoring takes the permission to
+	// delete any order rather than an ownership check.
+	if !h.authorize(w, r, auth.ActionDelete, auth.Resource{Type: "order", ID: id}) {
+		return
+	}
+
+	o, err := h.svc.RestoreOrder(r.Context(), id)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", o.ETag())
+	json.NewEncoder(w).Encode(o)
+}
+
+// authorize writes an error response and returns false unless the subject of
+// r may perform act on res.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, act auth.Action, res auth.Resource) bool {
+	sub, ok := auth.SubjectFromContext(r.Context())
+	if !ok {
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return false
+	}
+
+	authorized, err := h.auth.Authorize(r.Context(), sub, act, res)
+	if err != nil || !authorized {
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
+		return false
+	}
+	return true
+}
+
+// authorizedOrder loads an order and checks that the subject of r may
+// perform act on it, taking its owner into account. It writes an error
+// response and returns false otherwise.
+func (h *Handler) authorizedOrder(w http.ResponseWriter, r *http.Request, act auth.Action, id string) (order.Order, bool) {
+	if !h.authorize(w, r, act, auth.Resource{Type: "order", ID: id}) {
+		return order.Order{}, false
+	}
+
+	o, err := h.svc.GetOrder(r.Context(), id)
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
+		return order.Order{}, false
+	}
+	if !h.authorize(w, r, act, orderResource(o)) {
+		return order.Order{}, false
+	}
+	return o, true
+}
+
+// listOwner returns the user whose orders the subject of r may list: the
+// subject itself, or "" for all users if the policies let it read orders
+// owned by others.
+func (h *Handler) listOwner(r *http.Request) (string, error) {
+	sub, _ := auth.SubjectFromContext(r.Context())
+	all, err := h.auth.Authorize(r.Context(), sub, auth.ActionRead, othersOrder)
+	if err != nil {
+		return "", err
+	}
+	if all {
+		return "", nil
+	}
+	return sub.ID, nil
+}
+
+// othersOrder describes an order owned by another user than the subject.
+// No user has an empty ID.
+var othersOrder = auth.Resource{
+	Type: "order",
+	Attributes: map[string]interface{}{
+		"owner_id": "",
+	},
+}
+
+// orderResource describes o for authorization. The user who created an
+// order owns it.
+func orderResource(o order.Order) auth.Resource {
+	return auth.Resource{
+		Type: "order",
+		ID:   o.ID,
+		Attributes: map[string]interface{}{
+			"owner_id": o.CreatedBy,
+		},
+	}
+}
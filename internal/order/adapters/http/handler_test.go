@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hex-postgres-grpc/internal/auth"
+	order "hex-postgres-grpc/internal/order/domain"
+)
+
+// listService records the filters of ListOrders. Its other methods are not
+// used by the handler under test.
+type listService struct {
+	order.Service
+	customerID, createdBy string
+}
+
+func (s *listService) ListOrders(ctx context.Context, customerID, createdBy string) ([]order.Order, error) {
+	s.customerID, s.createdBy = customerID, createdBy
+	return []order.Order{}, nil
+}
+
+func TestListOrdersFiltersByOwner(t *testing.T) {
+	support := auth.PolicySet{
+		Roles: map[string][]string{"support": {"user"}},
+		Policies: []auth.Policy{
+			{ID: "user-read", SubjectRole: "user", Action: auth.ActionRead, ResourceType: "order", Condition: "resource.owner_id == null || resource.owner_id == subject.id"},
+			{ID: "support-read", SubjectRole: "support", Action: auth.ActionRead, ResourceType: "order"},
+		},
+	}
+	tests := []struct {
+		name      string
+		set       auth.PolicySet
+		sub       auth.Subject
+		createdBy string
+	}{
+		{"user", auth.DefaultPolicySet(), auth.Subject{ID: "u1", Role: "user"}, "u1"},
+		{"impersonated user", auth.DefaultPolicySet(), auth.Subject{ID: "u1", Role: "user", ActorID: "a1"}, "u1"},
+		{"admin", auth.DefaultPolicySet(), auth.Subject{ID: "a1", Role: "admin", MFA: true}, ""},
+		{"role allowed to read all orders", support, auth.Subject{ID: "s1", Role: "support"}, ""},
+		{"user under the same policies", support, auth.Subject{ID: "u2", Role: "user"}, "u2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := auth.NewPolicyEngine(context.Background(), auth.NewStaticPolicySource(tt.set), 0)
+			if err != nil {
+				t.Fatalf("NewPolicyEngine: %v", err)
+			}
+			svc := &listService{}
+			h := NewHandler(svc, auth.NewService(auth.ServiceConfig{Policies: engine}))
+
+			r := httptest.NewRequest(http.MethodGet, "/orders?customer_id=c1", nil)
+			r = r.WithContext(context.WithValue(r.Context(), auth.SubjectContextKey, tt.sub))
+			w := httptest.NewRecorder()
+			h.ListOrders(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+			if svc.customerID != "c1" || svc.createdBy != tt.createdBy {
+				t.Fatalf("ListOrders(%q, %q), want (%q, %q)", svc.customerID, svc.createdBy, "c1", tt.createdBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Package modules implements the ports of the order domain with the services
+// of the product and customer modules, which run in the same process.
+package modules
+
+import (
+	"context"
+	"errors"
+
+	customer "hex-postgres-grpc/internal/customer/domain"
+	order "hex-postgres-grpc/internal/order/domain"
+	product "hex-postgres-grpc/internal/product/domain"
+)
+
+type Catalog struct {
+	products product.Service
+}
+
+func NewCatalog(products product.Service) *Catalog {
+	return &Catalog{products: products}
+}
+
+func (c *Catalog) ProductPrice(ctx context.Context, productID string) (float64, error) {
+	p, err := c.products.GetProduct(ctx, productID)
+	if errors.Is(err, product.ErrNotFound) {
+		return 0, order.ErrUnknownProduct
+	}
+	if err != nil {
+		return 0, err
+	}
+	return p.Price, nil
+}
+
+type Customers struct {
+	customers customer.Service
+}
+
+func NewCustomers(customers customer.Service) *Customers {
+	return &Customers{customers: customers}
+}
+
+func (c *Customers) CheckCustomer(ctx context.Context, customerID string) error {
+	_, err := c.customers.GetCustomer(ctx, customerID)
+	if errors.Is(err, customer.ErrNotFound) {
+		return order.ErrUnknownCustomer
+	}
+	return err
+}
@@ -6,68 +6,278 @@ import (
 	"errors"
 	"time"
 
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/tenant"
 	order "hex-postgres-grpc/internal/order/domain"
+
+	"github.com/lib/pq"
 )
 
 type OrderRepoPG struct {
-	db *sql.DB
+	db *commonpg.DB
 }
 
 func NewOrderRepoPG(db *sql.DB) *OrderRepoPG {
-	return &OrderRepoPG{db: db}
+	return &OrderRepoPG{db: commonpg.Wrap(db)}
 }
 
 func (r *OrderRepoPG) Save(ctx context.Context, o *order.Order) error {
-	const q = `INSERT INTO orders (id, amount, created_at) VALUES ($1, $2, $3)`
-	_, err := r.db.ExecContext(ctx, q, o.ID, o.Amount, o.CreatedAt)
-	return err
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+	o.TenantID = tenantID
+
+	return r.db.InTx(ctx, func(ctx context.Context) error {
+		const q = `INSERT INTO orders (id, tenant_id, customer_id, status, amount, created_at, created_by, created_by_actor, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+		if _, err := r.db.ExecContext(ctx, q, o.ID, o.TenantID, o.CustomerID, o.Status, o.Amount, o.CreatedAt, o.CreatedBy, o.CreatedByActor, o.Version); err != nil {
+			return err
+		}
+		return r.insertItems(ctx, o)
+	})
 }
 
 func (r *OrderRepoPG) FindByID(ctx context.Context, id string) (*order.Order, error) {
-	const query = `SELECT id, amount, created_at FROM orders WHERE id = $1`
-	var o order.Order
-	var created time.Time
-	row := r.db.QueryRowContext(ctx, query, id)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := row.Scan(&o.ID, &o.Amount, &created); err != nil {
+	const query = `SELECT ` + orderColumns + ` FROM orders WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL`
+	o, err := scanOrder(r.db.QueryRowContext(ctx, query, id, tenantID))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, order.ErrNotFound
 		}
 		return nil, err
 	}
-	o.CreatedAt = created
-	return &o, nil
+
+	items, err := r.findItems(ctx, tenantID, []string{o.ID})
+	if err != nil {
+		return nil, err
+	}
+	o.Items = items[o.ID]
+	return o, nil
 }
 
 func (r *OrderRepoPG) Update(ctx context.Context, o *order.Order) error {
-	const q = `UPDATE orders SET amount = $1 WHERE id = $2`
-	_, err := r.db.ExecContext(ctx, q, o.Amount, o.ID)
-	return err
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.InTx(ctx, func(ctx context.Context) error {
+		const q = `UPDATE orders SET amount = $1, updated_at = $2, updated_by = $3, updated_by_actor = $4, version = version + 1
+			WHERE id = $5 AND tenant_id = $6 AND version = $7 AND deleted_at IS NULL`
+		res, err := r.db.ExecContext(ctx, q, o.Amount, o.UpdatedAt, o.UpdatedBy, o.UpdatedByActor, o.ID, tenantID, o.Version)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return order.ErrConflict
+		}
+
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM order_items WHERE order_id = $1 AND tenant_id = $2`, o.ID, tenantID); err != nil {
+			return err
+		}
+		return r.insertItems(ctx, o)
+	})
+	if err != nil {
+		return err
+	}
+	o.Version++
+	return nil
 }
 
-func (r *OrderRepoPG) Delete(ctx context.Context, id string) error {
-	const q = `DELETE FROM orders WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, q, id)
-	return err
+func (r *OrderRepoPG) UpdateStatus(ctx context.Context, o *order.Order, change order.StatusChange) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.InTx(ctx, func(ctx context.Context) error {
+		const q = `UPDATE orders SET status = $1, updated_at = $2, updated_by = $3, updated_by_actor = $4, version = version + 1
+			WHERE id = $5 AND tenant_id = $6 AND version = $7 AND deleted_at IS NULL`
+		res, err := r.db.ExecContext(ctx, q, o.Status, o.UpdatedAt, o.UpdatedBy, o.UpdatedByActor, o.ID, tenantID, o.Version)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return order.ErrConflict
+		}
+
+		const h = `INSERT INTO order_status_history (order_id, tenant_id, from_status, to_status, reason, changed_by, changed_by_actor, changed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+		_, err = r.db.ExecContext(ctx, h, o.ID, tenantID, change.From, change.To, change.Reason, change.ChangedBy, change.ChangedByActor, change.ChangedAt)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	o.Version++
+	return nil
 }
 
-func (r *OrderRepoPG) FindAll(ctx context.Context) ([]order.Order, error) {
-	const q = `SELECT id, amount, created_at FROM orders`
-	rows, err := r.db.QueryContext(ctx, q)
+func (r *OrderRepoPG) History(ctx context.Context, orderID string) ([]order.StatusChange, error) {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `SELECT from_status, to_status, reason, changed_by, changed_by_actor, changed_at FROM order_status_history
+		WHERE order_id = $1 AND tenant_id = $2 ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, q, orderID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []order.StatusChange{}
+	for rows.Next() {
+		var c order.StatusChange
+		if err := rows.Scan(&c.From, &c.To, &c.Reason, &c.ChangedBy, &c.ChangedByActor, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (r *OrderRepoPG) Delete(ctx context.Context, id string, deletedBy string, deletedByActor *string) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE orders SET deleted_at = $1, deleted_by = $2, deleted_by_actor = $3, version = version + 1
+		WHERE id = $4 AND tenant_id = $5 AND deleted_at IS NULL`
+	res, err := r.db.ExecContext(ctx, q, time.Now(), deletedBy, deletedByActor, id, tenantID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return order.ErrNotFound
+	}
+	return nil
+}
+
+func (r *OrderRepoPG) Restore(ctx context.Context, id string, restoredBy string, restoredByActor *string) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE orders SET deleted_at = NULL, deleted_by = NULL, deleted_by_actor = NULL,
+		updated_at = $1, updated_by = $2, updated_by_actor = $3, version = version + 1
+		WHERE id = $4 AND tenant_id = $5 AND deleted_at IS NOT NULL`
+	res, err := r.db.ExecContext(ctx, q, time.Now(), restoredBy, restoredByActor, id, tenantID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return order.ErrNotFound
+	}
+	return nil
+}
+
+func (r *OrderRepoPG) FindAll(ctx context.Context, customerID, createdBy string) ([]order.Order, error) {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `SELECT ` + orderColumns + ` FROM orders
+		WHERE tenant_id = $1 AND deleted_at IS NULL AND ($2 = '' OR customer_id::text = $2) AND ($3 = '' OR created_by = $3)`
+	rows, err := r.db.QueryContext(ctx, q, tenantID, customerID, createdBy)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	orders := []order.Order{}
+	var ids []string
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *o)
+		ids = append(ids, o.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return orders, nil
+	}
+
+	items, err := r.findItems(ctx, tenantID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		orders[i].Items = items[orders[i].ID]
+	}
+	return orders, nil
+}
+
+const orderColumns = `id, tenant_id, customer_id, status, amount, created_at, created_by, created_by_actor, updated_at, updated_by, updated_by_actor, version`
+
+func scanOrder(row interface{ Scan(...interface{}) error }) (*order.Order, error) {
+	var o order.Order
+	var customerID sql.NullString
+	if err := row.Scan(&o.ID, &o.TenantID, &customerID, &o.Status, &o.Amount, &o.CreatedAt, &o.CreatedBy, &o.CreatedByActor, &o.UpdatedAt, &o.UpdatedBy, &o.UpdatedByActor, &o.Version); err != nil {
+		return nil, err
+	}
+	o.CustomerID = customerID.String
+	return &o, nil
+}
+
+func (r *OrderRepoPG) insertItems(ctx context.Context, o *order.Order) error {
+	const q = `INSERT INTO order_items (order_id, position, tenant_id, product_id, quantity, unit_price) VALUES ($1, $2, $3, $4, $5, $6)`
+	for i, item := range o.Items {
+		if _, err := r.db.ExecContext(ctx, q, o.ID, i, o.TenantID, item.ProductID, item.Quantity, item.UnitPrice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findItems returns the items of the given orders by order ID, in the order
+// they were added.
+func (r *OrderRepoPG) findItems(ctx context.Context, tenantID string, orderIDs []string) (map[string][]order.LineItem, error) {
+	const q = `SELECT order_id, product_id, quantity, unit_price FROM order_items
+		WHERE tenant_id = $1 AND order_id::text = ANY($2) ORDER BY order_id, position`
+	rows, err := r.db.QueryContext(ctx, q, tenantID, pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[string][]order.LineItem, len(orderIDs))
 	for rows.Next() {
-		var o order.Order
-		var created time.Time
-		if err := rows.Scan(&o.ID, &o.Amount, &created); err != nil {
+		var orderID string
+		var item order.LineItem
+		if err := rows.Scan(&orderID, &item.ProductID, &item.Quantity, &item.UnitPrice); err != nil {
 			return nil, err
 		}
-		o.CreatedAt = created
-		orders = append(orders, o)
+		items[orderID] = append(items[orderID], item)
 	}
-	return orders, rows.Err()
+	return items, rows.Err()
 }
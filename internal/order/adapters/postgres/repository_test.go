@@ -0,0 +1,251 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"hex-postgres-grpc/internal/auth"
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/adapters/postgres/pgtest"
+	domain_common "hex-postgres-grpc/internal/common/domain"
+	customerpg "hex-postgres-grpc/internal/customer/adapters/postgres"
+	customerusecase "hex-postgres-grpc/internal/customer/usecase"
+	"hex-postgres-grpc/internal/order/adapters/modules"
+	order "hex-postgres-grpc/internal/order/domain"
+	productpg "hex-postgres-grpc/internal/product/adapters/postgres"
+	productusecase "hex-postgres-grpc/internal/product/usecase"
+
+	"github.com/google/uuid"
+)
+
+const testUserID = "00000000-0000-0000-0000-000000000001"
+
+func TestRepositoryTenantIsolation(t *testing.T) {
+	repo := NewOrderRepoPG(pgtest.Open(t))
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	o := &order.Order{
+		BaseEntity: domain_common.BaseEntity{ID: uuid.NewString(), CreatedAt: time.Now(), CreatedBy: testUserID, Version: 1},
+		CustomerID: uuid.NewString(),
+		Status:     order.StatusPending,
+		Items:      []order.LineItem{{ProductID: uuid.NewString(), Quantity: 2, UnitPrice: 5}},
+		Amount:     10,
+	}
+	if err := repo.Save(ctxA, o); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if _, err := repo.FindByID(ctxB, o.ID); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("FindByID in other tenant: %v, want ErrNotFound", err)
+	}
+	for _, customerID := range []string{"", o.CustomerID} {
+		list, err := repo.FindAll(ctxB, customerID, "")
+		if err != nil {
+			t.Fatalf("FindAll(%q): %v", customerID, err)
+		}
+		if len(list) != 0 {
+			t.Fatalf("FindAll(%q) in other tenant lists %d orders, want none", customerID, len(list))
+		}
+	}
+
+	now := time.Now()
+	changed := *o
+	changed.Items = []order.LineItem{{ProductID: uuid.NewString(), Quantity: 1, UnitPrice: 1}}
+	changed.Amount = 1
+	changed.UpdatedAt = &now
+	if err := repo.Update(ctxB, &changed); !errors.Is(err, order.ErrConflict) {
+		t.Fatalf("Update in other tenant: %v, want ErrConflict", err)
+	}
+	changed = *o
+	changed.Status = order.StatusCancelled
+	change := order.StatusChange{From: order.StatusPending, To: order.StatusCancelled, Reason: "stolen", ChangedBy: testUserID, ChangedAt: now}
+	if err := repo.UpdateStatus(ctxB, &changed, change); !errors.Is(err, order.ErrConflict) {
+		t.Fatalf("UpdateStatus in other tenant: %v, want ErrConflict", err)
+	}
+	if err := repo.Delete(ctxB, o.ID, testUserID, nil); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("Delete in other tenant: %v, want ErrNotFound", err)
+	}
+
+	got, err := repo.FindByID(ctxA, o.ID)
+	if err != nil {
+		t.Fatalf("FindByID in own tenant: %v", err)
+	}
+	if got.Version != 1 || got.Status != order.StatusPending || got.Amount != 10 || len(got.Items) != 1 || got.Items[0] != o.Items[0] {
+		t.Fatalf("order changed by other tenant: %+v", got)
+	}
+	if history, err := repo.History(ctxA, o.ID); err != nil || len(history) != 0 {
+		t.Fatalf("History = %+v (%v), want none", history, err)
+	}
+	if list, err := repo.FindAll(ctxA, "", ""); err != nil || len(list) != 1 {
+		t.Fatalf("own tenant lists %d orders (%v), want 1", len(list), err)
+	}
+
+	// A deleted order cannot be restored from another tenant either.
+	if err := repo.Delete(ctxA, o.ID, testUserID, nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Restore(ctxB, o.ID, testUserID, nil); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("Restore in other tenant: %v, want ErrNotFound", err)
+	}
+	if _, err := repo.FindByID(ctxA, o.ID); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("FindByID after restore in other tenant: %v, want ErrNotFound", err)
+	}
+}
+
+func TestServiceTenantIsolation(t *testing.T) {
+	db := pgtest.Open(t)
+	tx := commonpg.NewTxManager(db, commonpg.TxConfig{})
+	outbox := commonpg.NewOutboxStore(db)
+	products := productusecase.NewService(productpg.NewProductRepoPG(db), tx, outbox)
+	customers := customerusecase.NewService(customerpg.NewRepository(db), tx, outbox)
+	svc := order.NewService(NewOrderRepoPG(db), modules.NewCatalog(products), modules.NewCustomers(customers), tx, outbox)
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	p, err := products.CreateProduct(ctxA, "Lamp", 5)
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	c, err := customers.CreateCustomer(ctxA, "Ann", uuid.NewString()+"@example.com", "1 Main St")
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	items := []order.LineItem{{ProductID: p.ID, Quantity: 2}}
+	o, err := svc.CreateOrder(ctxA, c.ID, items)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	// Customers and products of another tenant cannot be ordered.
+	if _, err := svc.CreateOrder(ctxB, c.ID, items); !errors.Is(err, order.ErrUnknownCustomer) {
+		t.Fatalf("CreateOrder for customer of other tenant: %v, want ErrUnknownCustomer", err)
+	}
+
+	if _, err := svc.GetOrder(ctxB, o.ID); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("GetOrder in other tenant: %v, want ErrNotFound", err)
+	}
+	list, err := svc.ListOrders(ctxB, "", "")
+	if err != nil {
+		t.Fatalf("ListOrders: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("other tenant lists %d orders, want none", len(list))
+	}
+	if _, err := svc.UpdateOrder(ctxB, o.ID, items, 0); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("UpdateOrder in other tenant: %v, want ErrNotFound", err)
+	}
+	if _, err := svc.TransitionOrder(ctxB, o.ID, order.StatusCancelled, "stolen", 0); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("TransitionOrder in other tenant: %v, want ErrNotFound", err)
+	}
+	if _, err := svc.OrderHistory(ctxB, o.ID); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("OrderHistory in other tenant: %v, want ErrNotFound", err)
+	}
+	if err := svc.DeleteOrder(ctxB, o.ID); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("DeleteOrder in other tenant: %v, want ErrNotFound", err)
+	}
+
+	got, err := svc.GetOrder(ctxA, o.ID)
+	if err != nil {
+		t.Fatalf("GetOrder in own tenant: %v", err)
+	}
+	if got.Version != 1 || got.Status != order.StatusPending || got.Amount != 10 {
+		t.Fatalf("order changed by other tenant: %+v", got)
+	}
+}
+
+func TestListOrdersFiltersByOwner(t *testing.T) {
+	db := pgtest.Open(t)
+	tx := commonpg.NewTxManager(db, commonpg.TxConfig{})
+	outbox := commonpg.NewOutboxStore(db)
+	products := productusecase.NewService(productpg.NewProductRepoPG(db), tx, outbox)
+	customers := customerusecase.NewService(customerpg.NewRepository(db), tx, outbox)
+	svc := order.NewService(NewOrderRepoPG(db), modules.NewCatalog(products), modules.NewCustomers(customers), tx, outbox)
+	ctx := pgtest.Tenant()
+	as := func(id string) context.Context {
+		return context.WithValue(ctx, auth.SubjectContextKey, auth.Subject{ID: id, Role: "user"})
+	}
+	ann, bob := uuid.NewString(), uuid.NewString()
+
+	p, err := products.CreateProduct(ctx, "Lamp", 5)
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	c, err := customers.CreateCustomer(ctx, "Ann", uuid.NewString()+"@example.com", "1 Main St")
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	items := []order.LineItem{{ProductID: p.ID, Quantity: 1}}
+	owners := map[string]string{}
+	for _, owner := range []string{ann, ann, bob} {
+		o, err := svc.CreateOrder(as(owner), c.ID, items)
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		owners[o.ID] = owner
+	}
+
+	tests := []struct {
+		name      string
+		createdBy string
+		want      int
+	}{
+		{"owner", ann, 2},
+		{"other owner", bob, 1},
+		{"user without orders", uuid.NewString(), 0},
+		{"all owners", "", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, customerID := range []string{"", c.ID} {
+				list, err := svc.ListOrders(ctx, customerID, tt.createdBy)
+				if err != nil {
+					t.Fatalf("ListOrders(%q, %q): %v", customerID, tt.createdBy, err)
+				}
+				if len(list) != tt.want {
+					t.Fatalf("ListOrders(%q, %q) = %d orders, want %d", customerID, tt.createdBy, len(list), tt.want)
+				}
+				for _, o := range list {
+					if tt.createdBy != "" && owners[o.ID] != tt.createdBy {
+						t.Fatalf("ListOrders(%q, %q) returned order %s of %s", customerID, tt.createdBy, o.ID, owners[o.ID])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteOrder(t *testing.T) {
+	repo := NewOrderRepoPG(pgtest.Open(t))
+	ctx := pgtest.Tenant()
+
+	o := &order.Order{
+		BaseEntity: domain_common.BaseEntity{ID: uuid.NewString(), CreatedAt: time.Now(), CreatedBy: testUserID, Version: 1},
+		CustomerID: uuid.NewString(),
+		Status:     order.StatusPending,
+	}
+	if err := repo.Save(ctx, o); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if err := repo.Delete(ctx, uuid.NewString(), testUserID, nil); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("Delete of unknown order: %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, o.ID, testUserID, nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, o.ID, testUserID, nil); !errors.Is(err, order.ErrNotFound) {
+		t.Fatalf("Delete of deleted order: %v, want ErrNotFound", err)
+	}
+
+	if err := repo.Restore(ctx, o.ID, testUserID, nil); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	got, err := repo.FindByID(ctx, o.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if got.Version != 3 {
+		t.Fatalf("version after delete and restore = %d, want 3", got.Version)
+	}
+}
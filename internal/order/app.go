@@ -3,10 +3,15 @@ package order
 import (
 	"database/sql"
 	"hex-postgres-grpc/internal/auth"
+	"hex-postgres-grpc/internal/common/events"
+	"hex-postgres-grpc/internal/common/uow"
+	customerdomain "hex-postgres-grpc/internal/customer/domain"
 	"hex-postgres-grpc/internal/order/adapters/grpc"
 	"hex-postgres-grpc/internal/order/adapters/http"
+	"hex-postgres-grpc/internal/order/adapters/modules"
 	"hex-postgres-grpc/internal/order/adapters/postgres"
 	orderdomain "hex-postgres-grpc/internal/order/domain"
+	productdomain "hex-postgres-grpc/internal/product/domain"
 )
 
 type Components struct {
@@ -15,11 +20,13 @@ type Components struct {
 	GRPCServer  *grpc.Server
 }
 
-func Init(db *sql.DB, authSvc auth.Service) Components {
+// Init builds the order module. Orders refer to the products and customers
+// of the given services, and their events are recorded in outbox.
+func Init(db *sql.DB, authSvc auth.Service, products productdomain.Service, customers customerdomain.Service, unitOfWork uow.UnitOfWork, outbox events.Outbox) Components {
 	repo := postgres.NewOrderRepoPG(db)
-	svc := orderdomain.NewService(repo)
+	svc := orderdomain.NewService(repo, modules.NewCatalog(products), modules.NewCustomers(customers), unitOfWork, outbox)
 	httpHandler := http.NewHandler(svc, authSvc)
-	grpcServer := grpc.NewOrderGRPCServer(svc) // TODO: Add auth interceptor if needed
+	grpcServer := grpc.NewOrderGRPCServer(svc, authSvc)
 
 	return Components{
 		Service:     svc,
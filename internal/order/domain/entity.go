@@ -6,5 +6,21 @@ import (
 
 type Order struct {
 	domain_common.BaseEntity
+	CustomerID string     `json:"customer_id"`
+	Status     Status     `json:"status"`
+	Items      []LineItem `json:"items"`
+	// Amount is the total of the items and is computed by the service.
 	Amount float64 `json:"amount"`
 }
+
+// LineItem is a product in an order. UnitPrice is the price of the product
+// when the item was added, so later price changes do not alter the order.
+type LineItem struct {
+	ProductID string  `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+}
+
+func (i LineItem) Total() float64 {
+	return float64(i.Quantity) * i.UnitPrice
+}
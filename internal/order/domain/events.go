@@ -0,0 +1,28 @@
+package order
+
+import "time"
+
+// Event types raised by orders, and the payloads they carry.
+const (
+	AggregateType = "order"
+
+	EventOrderCreated       = "order.created"
+	EventOrderStatusChanged = "order.status_changed"
+)
+
+type OrderCreated struct {
+	OrderID    string     `json:"order_id"`
+	CustomerID string     `json:"customer_id"`
+	Items      []LineItem `json:"items"`
+	Amount     float64    `json:"amount"`
+	CreatedBy  string     `json:"created_by"`
+}
+
+type OrderStatusChanged struct {
+	OrderID   string    `json:"order_id"`
+	From      Status    `json:"from"`
+	To        Status    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -0,0 +1,17 @@
+package order
+
+import "context"
+
+// Catalog gives orders access to the products of the product module.
+type Catalog interface {
+	// ProductPrice returns the current unit price of a product, or
+	// ErrUnknownProduct when the product does not exist.
+	ProductPrice(ctx context.Context, productID string) (float64, error)
+}
+
+// Customers gives orders access to the customers of the customer module.
+type Customers interface {
+	// CheckCustomer returns ErrUnknownCustomer when the customer does not
+	// exist.
+	CheckCustomer(ctx context.Context, customerID string) error
+}
@@ -3,9 +3,27 @@ package order
 import "context"
 
 type Repository interface {
+	// Save stores a new order together with its items.
 	Save(ctx context.Context, order *Order) error
 	FindByID(ctx context.Context, id string) (*Order, error)
+	// Update stores order if the stored version still equals order.Version,
+	// and increments the version. It returns ErrConflict otherwise. The
+	// stored items are replaced by order.Items.
 	Update(ctx context.Context, order *Order) error
-	Delete(ctx context.Context, id string) error
-	FindAll(ctx context.Context) ([]Order, error)
+	// UpdateStatus stores the status of order and appends change to its
+	// history, under the same version check as Update.
+	UpdateStatus(ctx context.Context, order *Order, change StatusChange) error
+	// History returns the status changes of an order, oldest first.
+	History(ctx context.Context, orderID string) ([]StatusChange, error)
+	// Delete marks an order as deleted and increments its version. FindByID,
+	// FindAll and the updates ignore deleted orders. It returns ErrNotFound
+	// unless the order exists and is not deleted.
+	Delete(ctx context.Context, id string, deletedBy string, deletedByActor *string) error
+	// Restore clears the deletion of an order and records restoredBy as its
+	// last author. It returns ErrNotFound unless the order is deleted.
+	Restore(ctx context.Context, id string, restoredBy string, restoredByActor *string) error
+	// FindAll returns all orders, or those of one customer when customerID
+	// is not empty, and only those created by createdBy when it is not
+	// empty.
+	FindAll(ctx context.Context, customerID, createdBy string) ([]Order, error)
 }
@@ -2,51 +2,134 @@ package order
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"hex-postgres-grpc/internal/auth"
+	"hex-postgres-grpc/internal/common/apperr"
 	domain_common "hex-postgres-grpc/internal/common/domain"
+	"hex-postgres-grpc/internal/common/events"
+	"hex-postgres-grpc/internal/common/uow"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-var ErrNotFound = errors.New("order not found")
-var ErrInvalidAmount = errors.New("invalid amount")
+var ErrNotFound = apperr.New(apperr.NotFound, "ORDER_NOT_FOUND", "order not found")
+
+var (
+	ErrCustomerRequired = apperr.NewField("customer_id", "ORDER_CUSTOMER_REQUIRED", "customer id is required")
+	ErrUnknownCustomer  = apperr.NewField("customer_id", "ORDER_UNKNOWN_CUSTOMER", "customer does not exist")
+	ErrItemsRequired    = apperr.NewField("items", "ORDER_ITEMS_REQUIRED", "an order needs at least one item")
+	ErrInvalidQuantity  = apperr.NewField("items", "ORDER_INVALID_QUANTITY", "item quantity must be positive")
+	ErrUnknownProduct   = apperr.NewField("items", "ORDER_UNKNOWN_PRODUCT", "product does not exist")
+)
+
+var (
+	ErrInvalidStatus     = apperr.NewField("status", "ORDER_INVALID_STATUS", "unknown order status")
+	ErrInvalidTransition = apperr.New(apperr.FailedPrecondition, "ORDER_INVALID_TRANSITION", "order cannot move to this status")
+	ErrReasonRequired    = apperr.NewField("reason", "ORDER_REASON_REQUIRED", "a reason is required for this transition")
+	ErrNotEditable       = apperr.New(apperr.FailedPrecondition, "ORDER_NOT_EDITABLE", "only pending orders can be changed")
+)
+
+// ErrConflict is returned when an order was changed while an update was being
+// applied.
+var ErrConflict = apperr.New(apperr.Conflict, "ORDER_CONFLICT", "order was modified concurrently")
+
+// ErrVersionMismatch is returned when an update only applies to a version of
+// an order that is no longer current.
+var ErrVersionMismatch = apperr.New(apperr.PreconditionFailed, "ORDER_VERSION_MISMATCH", "order has changed since the given version")
 
 type Service interface {
-	CreateOrder(ctx context.Context, amount float64) (Order, error)
+	// CreateOrder places an order for a customer. The unit prices of the
+	// items are taken from the catalog and the amount is computed from them;
+	// UnitPrice of the given items is ignored.
+	CreateOrder(ctx context.Context, customerID string, items []LineItem) (Order, error)
 	GetOrder(ctx context.Context, id string) (Order, error)
-	UpdateOrder(ctx context.Context, id string, amount float64) (Order, error)
+	// UpdateOrder replaces the items of a pending order, at current prices.
+	// It applies only to the given version of the order and returns
+	// ErrVersionMismatch if it is not current; 0 updates whatever version is
+	// current.
+	UpdateOrder(ctx context.Context, id string, items []LineItem, version int64) (Order, error)
+	// TransitionOrder moves the order to status next and records the change
+	// in its history. It returns ErrInvalidTransition when the lifecycle does
+	// not allow the move. The version works as in UpdateOrder.
+	TransitionOrder(ctx context.Context, id string, next Status, reason string, version int64) (Order, error)
+	// OrderHistory returns the status changes of the order, oldest first.
+	OrderHistory(ctx context.Context, id string) ([]StatusChange, error)
+	// DeleteOrder marks the order as deleted. Deleted orders are hidden until
+	// they are restored.
 	DeleteOrder(ctx context.Context, id string) error
-	ListOrders(ctx context.Context) ([]Order, error)
+	// RestoreOrder undeletes an order. It returns ErrNotFound unless the
+	// order is deleted.
+	RestoreOrder(ctx context.Context, id string) (Order, error)
+	// ListOrders returns all orders, or those of one customer when
+	// customerID is not empty, and only those created by createdBy when it
+	// is not empty.
+	ListOrders(ctx context.Context, customerID, createdBy string) ([]Order, error)
 }
 
 type service struct {
-	repo Repository
+	repo      Repository
+	catalog   Catalog
+	customers Customers
+	uow       uow.UnitOfWork
+	outbox    events.Outbox
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+// NewService records OrderCreated and OrderStatusChanged events in outbox,
+// in the unit of work of the write they describe.
+func NewService(repo Repository, catalog Catalog, customers Customers, unitOfWork uow.UnitOfWork, outbox events.Outbox) Service {
+	return &service{repo: repo, catalog: catalog, customers: customers, uow: unitOfWork, outbox: outbox}
 }
 
-func (s *service) CreateOrder(ctx context.Context, amount float64) (Order, error) {
-	if amount <= 0 {
-		return Order{}, ErrInvalidAmount
+func (s *service) CreateOrder(ctx context.Context, customerID string, items []LineItem) (Order, error) {
+	if customerID == "" {
+		return Order{}, ErrCustomerRequired
+	}
+	if err := s.customers.CheckCustomer(ctx, customerID); err != nil {
+		return Order{}, err
+	}
+	items, amount, err := s.priceItems(ctx, items)
+	if err != nil {
+		return Order{}, err
 	}
 
+	createdBy, actor := author(ctx)
 	id := uuid.NewString()
 	o := Order{
 		BaseEntity: domain_common.BaseEntity{
-			ID:        id,
-			CreatedAt: time.Now(),
+			ID:             id,
+			CreatedAt:      time.Now(),
+			CreatedBy:      createdBy,
+			CreatedByActor: actor,
+			Version:        1,
 		},
-		Amount: amount,
+		CustomerID: customerID,
+		Status:     StatusPending,
+		Items:      items,
+		Amount:     amount,
 	}
-	if err := s.repo.Save(ctx, &o); err != nil {
+	event, err := events.New(EventOrderCreated, AggregateType, o.ID, OrderCreated{
+		OrderID:    o.ID,
+		CustomerID: o.CustomerID,
+		Items:      o.Items,
+		Amount:     o.Amount,
+		CreatedBy:  o.CreatedBy,
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.Save(ctx, &o); err != nil {
+			return err
+		}
+		return s.outbox.Record(ctx, event)
+	})
+	if err != nil {
 		return Order{}, err
 	}
 	return o, nil
-
 }
+
 func (s *service) GetOrder(ctx context.Context, id string) (Order, error) {
 	o, err := s.repo.FindByID(ctx, id)
 	if err != nil {
@@ -55,17 +138,26 @@ func (s *service) GetOrder(ctx context.Context, id string) (Order, error) {
 	return *o, nil
 }
 
-func (s *service) UpdateOrder(ctx context.Context, id string, amount float64) (Order, error) {
+func (s *service) UpdateOrder(ctx context.Context, id string, items []LineItem, version int64) (Order, error) {
 	o, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return Order{}, err
 	}
+	if version != 0 && o.Version != version {
+		return Order{}, ErrVersionMismatch
+	}
+	if o.Status != StatusPending {
+		return Order{}, ErrNotEditable
+	}
 
-	if amount <= 0 {
-		return Order{}, ErrInvalidAmount
+	items, amount, err := s.priceItems(ctx, items)
+	if err != nil {
+		return Order{}, err
 	}
 
+	o.Items = items
 	o.Amount = amount
+	o.touch(ctx)
 	if err := s.repo.Update(ctx, o); err != nil {
 		return Order{}, err
 	}
@@ -73,10 +165,128 @@ func (s *service) UpdateOrder(ctx context.Context, id string, amount float64) (O
 	return *o, nil
 }
 
+func (s *service) TransitionOrder(ctx context.Context, id string, next Status, reason string, version int64) (Order, error) {
+	if !next.Valid() {
+		return Order{}, ErrInvalidStatus
+	}
+	if next.RequiresReason() && reason == "" {
+		return Order{}, ErrReasonRequired
+	}
+
+	o, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Order{}, err
+	}
+	if version != 0 && o.Version != version {
+		return Order{}, ErrVersionMismatch
+	}
+	if !o.Status.CanTransitionTo(next) {
+		return Order{}, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, o.Status, next)
+	}
+
+	o.touch(ctx)
+	change := StatusChange{
+		From:           o.Status,
+		To:             next,
+		Reason:         reason,
+		ChangedBy:      *o.UpdatedBy,
+		ChangedByActor: o.UpdatedByActor,
+		ChangedAt:      *o.UpdatedAt,
+	}
+
+	event, err := events.New(EventOrderStatusChanged, AggregateType, o.ID, OrderStatusChanged{
+		OrderID:   o.ID,
+		From:      change.From,
+		To:        change.To,
+		Reason:    change.Reason,
+		ChangedBy: change.ChangedBy,
+		ChangedAt: change.ChangedAt,
+	})
+	if err != nil {
+		return Order{}, err
+	}
+
+	o.Status = next
+	// The unit of work may run more than once, so each run starts from o.
+	var updated Order
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		updated = *o
+		if err := s.repo.UpdateStatus(ctx, &updated, change); err != nil {
+			return err
+		}
+		return s.outbox.Record(ctx, event)
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return updated, nil
+}
+
+func (s *service) OrderHistory(ctx context.Context, id string) ([]StatusChange, error) {
+	if _, err := s.repo.FindByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.History(ctx, id)
+}
+
 func (s *service) DeleteOrder(ctx context.Context, id string) error {
-	return s.repo.Delete(ctx, id)
+	deletedBy, actor := author(ctx)
+	return s.repo.Delete(ctx, id, deletedBy, actor)
+}
+
+func (s *service) RestoreOrder(ctx context.Context, id string) (Order, error) {
+	restoredBy, actor := author(ctx)
+	if err := s.repo.Restore(ctx, id, restoredBy, actor); err != nil {
+		return Order{}, err
+	}
+	return s.GetOrder(ctx, id)
+}
+
+func (s *service) ListOrders(ctx context.Context, customerID, createdBy string) ([]Order, error) {
+	return s.repo.FindAll(ctx, customerID, createdBy)
+}
+
+// priceItems validates items and returns them with the current unit prices
+// of their products, together with the order total.
+func (s *service) priceItems(ctx context.Context, items []LineItem) ([]LineItem, float64, error) {
+	if len(items) == 0 {
+		return nil, 0, ErrItemsRequired
+	}
+
+	priced := make([]LineItem, len(items))
+	var amount float64
+	for i, item := range items {
+		if item.Quantity <= 0 {
+			return nil, 0, ErrInvalidQuantity
+		}
+		if item.ProductID == "" {
+			return nil, 0, ErrUnknownProduct
+		}
+		price, err := s.catalog.ProductPrice(ctx, item.ProductID)
+		if err != nil {
+			return nil, 0, err
+		}
+		priced[i] = LineItem{ProductID: item.ProductID, Quantity: item.Quantity, UnitPrice: price}
+		amount += priced[i].Total()
+	}
+	return priced, amount, nil
+}
+
+// author returns the user to record as the author of a write, and the
+// administrator behind it while impersonating.
+func author(ctx context.Context) (string, *string) {
+	sub, _ := auth.SubjectFromContext(ctx)
+	if sub.ID == "" {
+		return domain_common.SystemUserID, nil
+	}
+	return sub.ID, sub.Actor()
 }
 
-func (s *service) ListOrders(ctx context.Context) ([]Order, error) {
-	return s.repo.FindAll(ctx)
+// touch records the subject of ctx as the author of an update.
+func (o *Order) touch(ctx context.Context) {
+	now := time.Now()
+	updatedBy, actor := author(ctx)
+	o.UpdatedAt = &now
+	o.UpdatedBy = &updatedBy
+	o.UpdatedByActor = actor
 }
@@ -0,0 +1,61 @@
+package order
+
+import (
+	"slices"
+	"time"
+)
+
+// Status is the stage of an order in its lifecycle:
+//
+//	pending → confirmed → paid → shipped → delivered
+//
+// Pending and confirmed orders can be cancelled, and paid or delivered
+// orders refunded. Cancelled and refunded are final.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusConfirmed Status = "confirmed"
+	StatusPaid      Status = "paid"
+	StatusShipped   Status = "shipped"
+	StatusDelivered Status = "delivered"
+	StatusCancelled Status = "cancelled"
+	StatusRefunded  Status = "refunded"
+)
+
+var transitions = map[Status][]Status{
+	StatusPending:   {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusShipped, StatusRefunded},
+	StatusShipped:   {StatusDelivered},
+	StatusDelivered: {StatusRefunded},
+	StatusCancelled: nil,
+	StatusRefunded:  nil,
+}
+
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order may move from s to next.
+func (s Status) CanTransitionTo(next Status) bool {
+	return slices.Contains(transitions[s], next)
+}
+
+// RequiresReason reports whether moving to s must be explained.
+func (s Status) RequiresReason() bool {
+	return s == StatusCancelled || s == StatusRefunded
+}
+
+// StatusChange records a transition of an order.
+type StatusChange struct {
+	From   Status `json:"from"`
+	To     Status `json:"to"`
+	Reason string `json:"reason,omitempty"`
+	// ChangedByActor is the administrator who made the change while
+	// impersonating ChangedBy.
+	ChangedBy      string    `json:"changed_by"`
+	ChangedByActor *string   `json:"changed_by_actor,omitempty"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
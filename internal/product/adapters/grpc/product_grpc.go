@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	domain_common "hex-postgres-grpc/internal/common/domain"
 	product "hex-postgres-grpc/internal/product/domain"
 	productpb "hex-postgres-grpc/proto/product"
 
@@ -30,6 +31,7 @@ func (s *Server) CreateProduct(ctx context.Context, req *productpb.CreateProduct
 			Name:      p.Name,
 			Price:     p.Price,
 			CreatedAt: timestamppb.New(p.CreatedAt),
+			Etag:      p.ETag(),
 		},
 	}, nil
 }
@@ -45,12 +47,18 @@ func (s *Server) GetProduct(ctx context.Context, req *productpb.GetProductReques
 			Name:      p.Name,
 			Price:     p.Price,
 			CreatedAt: timestamppb.New(p.CreatedAt),
+			Etag:      p.ETag(),
 		},
 	}, nil
 }
 
 func (s *Server) UpdateProduct(ctx context.Context, req *productpb.UpdateProductRequest) (*productpb.UpdateProductResponse, error) {
-	p, err := s.service.UpdateProduct(ctx, req.Id, req.Name, req.Price)
+	version, err := domain_common.ParseETag(req.Etag)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.service.UpdateProduct(ctx, req.Id, req.Name, req.Price, version)
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +68,7 @@ func (s *Server) UpdateProduct(ctx context.Context, req *productpb.UpdateProduct
 			Name:      p.Name,
 			Price:     p.Price,
 			CreatedAt: timestamppb.New(p.CreatedAt),
+			Etag:      p.ETag(),
 		},
 	}, nil
 }
@@ -86,6 +95,7 @@ func (s *Server) ListProducts(ctx context.Context, req *productpb.ListProductsRe
 			Name:      p.Name,
 			Price:     p.Price,
 			CreatedAt: timestamppb.New(p.CreatedAt),
+			Etag:      p.ETag(),
 		})
 	}
 
@@ -6,6 +6,8 @@ import (
 	"strconv"
 
 	"hex-postgres-grpc/internal/auth"
+	commonhttp "hex-postgres-grpc/internal/common/adapters/http"
+	domain_common "hex-postgres-grpc/internal/common/domain"
 	product "hex-postgres-grpc/internal/product/domain"
 )
 
@@ -46,37 +48,42 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param Idempotency-Key header string false "Makes retries with the same key and body return the first response instead of creating another product"
 // @Param request body CreateProductRequest true "Create Product Request"
 // @Success 200 {object} product.Product
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
+// @Header 200 {string} ETag "Version of the product"
+// @Header 200 {string} Idempotent-Replayed "true when the response is replayed for a retry"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 409 {object} commonhttp.Problem "idempotency key reused for a different request, or in use"
 // @Router /products [post]
 func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionCreate, auth.Resource{Type: "product"})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
 	var req CreateProductRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	p, err := h.service.CreateProduct(r.Context(), req.Name, req.Price)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", p.ETag())
 	json.NewEncoder(w).Encode(p)
 }
 
@@ -88,93 +95,99 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param id path string true "Product ID"
 // @Success 200 {object} product.Product
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
-// @Failure 404 {string} string "not found"
+// @Header 200 {string} ETag "Version of the product"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
 // @Router /products/{id} [get]
 func (h *Handler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if id == "" {
-		http.Error(w, "id parameter required", http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "id parameter required")
 		return
 	}
 
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionRead, auth.Resource{Type: "product", ID: id})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
 	p, err := h.service.GetProduct(r.Context(), id)
 	if err != nil {
-		if err == product.ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", p.ETag())
 	json.NewEncoder(w).Encode(p)
 }
 
 // UpdateProduct updates an existing product
 // @Summary Update Product
-// @Description Update name and price of an existing product
+// @Description Update name and price of an existing product. With If-Match the update only applies to that version of the product.
 // @Tags products
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Product ID"
+// @Param If-Match header string false "ETag of the version being updated"
 // @Param request body UpdateProductRequest true "Update Product Request"
 // @Success 200 {object} product.Product
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
-// @Failure 404 {string} string "not found"
+// @Header 200 {string} ETag "Version of the product"
+// @Failure 400 {object} commonhttp.Problem "invalid If-Match header"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
+// @Failure 404 {object} commonhttp.Problem "not found"
+// @Failure 409 {object} commonhttp.Problem "product was modified concurrently"
+// @Failure 412 {object} commonhttp.Problem "product has changed since the If-Match version"
 // @Router /products/{id} [put]
 func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if id == "" {
-		http.Error(w, "id parameter required", http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "id parameter required")
 		return
 	}
 
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionUpdate, auth.Resource{Type: "product", ID: id})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	version, err := domain_common.ParseETag(r.Header.Get("If-Match"))
+	if err != nil {
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	var req UpdateProductRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	p, err := h.service.UpdateProduct(r.Context(), id, req.Name, req.Price)
+	p, err := h.service.UpdateProduct(r.Context(), id, req.Name, req.Price, version)
 	if err != nil {
-		if err == product.ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", p.ETag())
 	json.NewEncoder(w).Encode(p)
 }
 
@@ -185,30 +198,30 @@ func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param id path string true "Product ID"
 // @Success 204 "No Content"
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
 // @Router /products/{id} [delete]
 func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if id == "" {
-		http.Error(w, "id parameter required", http.StatusBadRequest)
+		commonhttp.WriteProblem(w, r, http.StatusBadRequest, "id parameter required")
 		return
 	}
 
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionDelete, auth.Resource{Type: "product", ID: id})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
 	if err := h.service.DeleteProduct(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
@@ -224,19 +237,19 @@ func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 // @Param page query int false "Page number (default 1)"
 // @Param limit query int false "Items per page (default 10)"
 // @Success 200 {object} product.PaginatedResponse
-// @Failure 401 {string} string "unauthorized"
-// @Failure 403 {string} string "forbidden"
+// @Failure 401 {object} commonhttp.Problem "unauthorized"
+// @Failure 403 {object} commonhttp.Problem "forbidden"
 // @Router /products [get]
 func (h *Handler) ListProducts(w http.ResponseWriter, r *http.Request) {
 	sub, ok := auth.SubjectFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		commonhttp.WriteProblem(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	authorized, err := h.auth.Authorize(r.Context(), sub, auth.ActionRead, auth.Resource{Type: "product"})
 	if err != nil || !authorized {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		commonhttp.WriteProblem(w, r, http.StatusForbidden, "forbidden")
 		return
 	}
 
@@ -259,7 +272,7 @@ func (h *Handler) ListProducts(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.service.ListProductsPaginated(r.Context(), page, limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		commonhttp.WriteError(w, r, err)
 		return
 	}
 
@@ -6,29 +6,42 @@ import (
 	"errors"
 	"time"
 
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/tenant"
 	product "hex-postgres-grpc/internal/product/domain"
 )
 
 type ProductRepoPG struct {
-	db *sql.DB
+	db *commonpg.DB
 }
 
 func NewProductRepoPG(db *sql.DB) *ProductRepoPG {
-	return &ProductRepoPG{db: db}
+	return &ProductRepoPG{db: commonpg.Wrap(db)}
 }
 
 func (r *ProductRepoPG) Save(ctx context.Context, p *product.Product) error {
-	const q = `INSERT INTO products (id, name, price, created_at, created_by) VALUES ($1, $2, $3, $4, $5)`
-	_, err := r.db.ExecContext(ctx, q, p.ID, p.Name, p.Price, p.CreatedAt, p.CreatedBy)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+	p.TenantID = tenantID
+
+	const q = `INSERT INTO products (id, tenant_id, name, price, created_at, created_by, created_by_actor, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = r.db.ExecContext(ctx, q, p.ID, p.TenantID, p.Name, p.Price, p.CreatedAt, p.CreatedBy, p.CreatedByActor, p.Version)
 	return err
 }
 
 func (r *ProductRepoPG) FindByID(ctx context.Context, id string) (*product.Product, error) {
-	const query = `SELECT id, name, price, created_at, created_by, updated_at, updated_by FROM products WHERE id = $1 AND deleted_at IS NULL`
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const query = `SELECT id, tenant_id, name, price, created_at, created_by, created_by_actor, updated_at, updated_by, updated_by_actor, version FROM products WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL`
 	var p product.Product
-	row := r.db.QueryRowContext(ctx, query, id)
+	row := r.db.QueryRowContext(ctx, query, id, tenantID)
 
-	if err := row.Scan(&p.ID, &p.Name, &p.Price, &p.CreatedAt, &p.CreatedBy, &p.UpdatedAt, &p.UpdatedBy); err != nil {
+	if err := row.Scan(&p.ID, &p.TenantID, &p.Name, &p.Price, &p.CreatedAt, &p.CreatedBy, &p.CreatedByActor, &p.UpdatedAt, &p.UpdatedBy, &p.UpdatedByActor, &p.Version); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, product.ErrNotFound
 		}
@@ -38,26 +51,52 @@ func (r *ProductRepoPG) FindByID(ctx context.Context, id string) (*product.Produ
 }
 
 func (r *ProductRepoPG) Update(ctx context.Context, p *product.Product) error {
-	const q = `UPDATE products SET name = $1, price = $2, updated_at = $3, updated_by = $4 WHERE id = $5 AND deleted_at IS NULL`
-	_, err := r.db.ExecContext(ctx, q, p.Name, p.Price, p.UpdatedAt, p.UpdatedBy, p.ID)
-	return err
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE products SET name = $1, price = $2, updated_at = $3, updated_by = $4, updated_by_actor = $5, version = version + 1 WHERE id = $6 AND tenant_id = $7 AND version = $8 AND deleted_at IS NULL`
+	res, err := r.db.ExecContext(ctx, q, p.Name, p.Price, p.UpdatedAt, p.UpdatedBy, p.UpdatedByActor, p.ID, tenantID, p.Version)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return product.ErrConflict
+	}
+	p.Version++
+	return nil
 }
 
-func (r *ProductRepoPG) Delete(ctx context.Context, id string, deletedBy string) error {
-	const q = `UPDATE products SET deleted_at = $1, deleted_by = $2 WHERE id = $3 AND deleted_at IS NULL`
-	_, err := r.db.ExecContext(ctx, q, time.Now(), deletedBy, id)
+func (r *ProductRepoPG) Delete(ctx context.Context, id string, deletedBy string, deletedByActor *string) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE products SET deleted_at = $1, deleted_by = $2, deleted_by_actor = $3 WHERE id = $4 AND tenant_id = $5 AND deleted_at IS NULL`
+	_, err = r.db.ExecContext(ctx, q, time.Now(), deletedBy, deletedByActor, id, tenantID)
 	return err
 }
 
 func (r *ProductRepoPG) FindAllPaginated(ctx context.Context, limit, offset int) ([]product.Product, int, error) {
-	const countQ = `SELECT COUNT(*) FROM products WHERE deleted_at IS NULL`
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	const countQ = `SELECT COUNT(*) FROM products WHERE tenant_id = $1 AND deleted_at IS NULL`
 	var total int
-	if err := r.db.QueryRowContext(ctx, countQ).Scan(&total); err != nil {
+	if err := r.db.QueryRowContext(ctx, countQ, tenantID).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
-	const q = `SELECT id, name, price, created_at, created_by, updated_at, updated_by FROM products WHERE deleted_at IS NULL LIMIT $1 OFFSET $2`
-	rows, err := r.db.QueryContext(ctx, q, limit, offset)
+	const q = `SELECT id, tenant_id, name, price, created_at, created_by, created_by_actor, updated_at, updated_by, updated_by_actor, version FROM products WHERE tenant_id = $1 AND deleted_at IS NULL LIMIT $2 OFFSET $3`
+	rows, err := r.db.QueryContext(ctx, q, tenantID, limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -66,7 +105,7 @@ func (r *ProductRepoPG) FindAllPaginated(ctx context.Context, limit, offset int)
 	products := []product.Product{}
 	for rows.Next() {
 		var p product.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.CreatedAt, &p.CreatedBy, &p.UpdatedAt, &p.UpdatedBy); err != nil {
+		if err := rows.Scan(&p.ID, &p.TenantID, &p.Name, &p.Price, &p.CreatedAt, &p.CreatedBy, &p.CreatedByActor, &p.UpdatedAt, &p.UpdatedBy, &p.UpdatedByActor, &p.Version); err != nil {
 			return nil, 0, err
 		}
 		products = append(products, p)
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	commonpg "hex-postgres-grpc/internal/common/adapters/postgres"
+	"hex-postgres-grpc/internal/common/adapters/postgres/pgtest"
+	domain_common "hex-postgres-grpc/internal/common/domain"
+	product "hex-postgres-grpc/internal/product/domain"
+	"hex-postgres-grpc/internal/product/usecase"
+
+	"github.com/google/uuid"
+)
+
+func TestRepositoryTenantIsolation(t *testing.T) {
+	repo := NewProductRepoPG(pgtest.Open(t))
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	p := &product.Product{
+		BaseEntity: domain_common.BaseEntity{ID: uuid.NewString(), CreatedAt: time.Now(), CreatedBy: domain_common.SystemUserID, Version: 1},
+		Name:       "Lamp",
+		Price:      10,
+	}
+	if err := repo.Save(ctxA, p); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if _, err := repo.FindByID(ctxB, p.ID); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("FindByID in other tenant: %v, want ErrNotFound", err)
+	}
+	list, total, err := repo.FindAllPaginated(ctxB, 100, 0)
+	if err != nil {
+		t.Fatalf("FindAllPaginated: %v", err)
+	}
+	if len(list) != 0 || total != 0 {
+		t.Fatalf("other tenant lists %d of %d products, want none", len(list), total)
+	}
+	changed := *p
+	changed.Name = "Stolen"
+	if err := repo.Update(ctxB, &changed); !errors.Is(err, product.ErrConflict) {
+		t.Fatalf("Update in other tenant: %v, want ErrConflict", err)
+	}
+	if err := repo.Delete(ctxB, p.ID, domain_common.SystemUserID, nil); err != nil {
+		t.Fatalf("Delete in other tenant: %v", err)
+	}
+
+	got, err := repo.FindByID(ctxA, p.ID)
+	if err != nil {
+		t.Fatalf("FindByID in own tenant: %v", err)
+	}
+	if got.Name != "Lamp" || got.Version != 1 {
+		t.Fatalf("product changed by other tenant: %+v", got)
+	}
+	if list, total, err := repo.FindAllPaginated(ctxA, 100, 0); err != nil || len(list) != 1 || total != 1 {
+		t.Fatalf("own tenant lists %d of %d products (%v), want 1", len(list), total, err)
+	}
+}
+
+func TestServiceTenantIsolation(t *testing.T) {
+	db := pgtest.Open(t)
+	svc := usecase.NewService(NewProductRepoPG(db), commonpg.NewTxManager(db, commonpg.TxConfig{}), commonpg.NewOutboxStore(db))
+	ctxA, ctxB := pgtest.Tenant(), pgtest.Tenant()
+
+	p, err := svc.CreateProduct(ctxA, "Lamp", 10)
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	if _, err := svc.GetProduct(ctxB, p.ID); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("GetProduct in other tenant: %v, want ErrNotFound", err)
+	}
+	page, err := svc.ListProductsPaginated(ctxB, 1, 100)
+	if err != nil {
+		t.Fatalf("ListProductsPaginated: %v", err)
+	}
+	if page.Data.TotalItem != 0 {
+		t.Fatalf("other tenant lists %d products, want none", page.Data.TotalItem)
+	}
+	if _, err := svc.UpdateProduct(ctxB, p.ID, "Stolen", 1, 0); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("UpdateProduct in other tenant: %v, want ErrNotFound", err)
+	}
+	if err := svc.DeleteProduct(ctxB, p.ID); err != nil {
+		t.Fatalf("DeleteProduct in other tenant: %v", err)
+	}
+
+	got, err := svc.GetProduct(ctxA, p.ID)
+	if err != nil {
+		t.Fatalf("GetProduct in own tenant: %v", err)
+	}
+	if got.Name != "Lamp" || got.Price != 10 || got.Version != 1 {
+		t.Fatalf("product changed by other tenant: %+v", got)
+	}
+}
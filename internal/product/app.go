@@ -3,25 +3,30 @@ package product
 import (
 	"database/sql"
 	"hex-postgres-grpc/internal/auth"
+	"hex-postgres-grpc/internal/common/events"
+	"hex-postgres-grpc/internal/common/uow"
 	"hex-postgres-grpc/internal/product/adapters/grpc"
 	"hex-postgres-grpc/internal/product/adapters/http"
 	"hex-postgres-grpc/internal/product/adapters/postgres"
+	productdomain "hex-postgres-grpc/internal/product/domain"
 	"hex-postgres-grpc/internal/product/usecase"
 )
 
 type Components struct {
+	Service     productdomain.Service
 	HTTPHandler *http.Handler
 	GRPCServer  *grpc.Server
 }
 
-func Init(db *sql.DB, authSvc auth.Service) Components {
+func Init(db *sql.DB, authSvc auth.Service, unitOfWork uow.UnitOfWork, outbox events.Outbox) Components {
 	repo := postgres.NewProductRepoPG(db)
-	service := usecase.NewService(repo)
+	service := usecase.NewService(repo, unitOfWork, outbox)
 
 	httpHandler := http.NewHandler(service, authSvc)
-	grpcServer := grpc.NewProductGRPCServer(service) // TODO: Add auth interceptor if needed
+	grpcServer := grpc.NewProductGRPCServer(service)
 
 	return Components{
+		Service:     service,
 		HTTPHandler: httpHandler,
 		GRPCServer:  grpcServer,
 	}
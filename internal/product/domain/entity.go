@@ -1,12 +1,20 @@
 package product
 
 import (
-	"errors"
+	"hex-postgres-grpc/internal/common/apperr"
 	domain_common "hex-postgres-grpc/internal/common/domain"
 )
 
-var ErrNotFound = errors.New("product not found")
-var ErrInvalidPrice = errors.New("invalid price")
+var ErrNotFound = apperr.New(apperr.NotFound, "PRODUCT_NOT_FOUND", "product not found")
+var ErrInvalidPrice = apperr.NewField("price", "INVALID_PRICE", "invalid price")
+
+// ErrConflict is returned when a product was changed while an update was
+// being applied.
+var ErrConflict = apperr.New(apperr.Conflict, "PRODUCT_CONFLICT", "product was modified concurrently")
+
+// ErrVersionMismatch is returned when an update only applies to a version of
+// a product that is no longer current.
+var ErrVersionMismatch = apperr.New(apperr.PreconditionFailed, "PRODUCT_VERSION_MISMATCH", "product has changed since the given version")
 
 type Product struct {
 	domain_common.BaseEntity
@@ -0,0 +1,15 @@
+package product
+
+// Event types raised by products, and the payloads they carry.
+const (
+	AggregateType = "product"
+
+	EventProductPriceChanged = "product.price_changed"
+)
+
+type ProductPriceChanged struct {
+	ProductID string  `json:"product_id"`
+	OldPrice  float64 `json:"old_price"`
+	NewPrice  float64 `json:"new_price"`
+	ChangedBy string  `json:"changed_by"`
+}
@@ -5,7 +5,9 @@ import "context"
 type Repository interface {
 	Save(ctx context.Context, product *Product) error
 	FindByID(ctx context.Context, id string) (*Product, error)
+	// Update stores product if the stored version still equals product.Version,
+	// and increments the version. It returns ErrConflict otherwise.
 	Update(ctx context.Context, product *Product) error
-	Delete(ctx context.Context, id string, deletedBy string) error
+	Delete(ctx context.Context, id string, deletedBy string, deletedByActor *string) error
 	FindAllPaginated(ctx context.Context, limit, offset int) ([]Product, int, error)
 }
@@ -7,7 +7,10 @@ import (
 type Service interface {
 	CreateProduct(ctx context.Context, name string, price float64) (Product, error)
 	GetProduct(ctx context.Context, id string) (Product, error)
-	UpdateProduct(ctx context.Context, id string, name string, price float64) (Product, error)
+	// UpdateProduct applies only to the given version of the product and
+	// returns ErrVersionMismatch if it is not current; 0 updates whatever
+	// version is current.
+	UpdateProduct(ctx context.Context, id string, name string, price float64, version int64) (Product, error)
 	DeleteProduct(ctx context.Context, id string) error
 	ListProductsPaginated(ctx context.Context, page, limit int) (PaginatedResponse, error)
 }
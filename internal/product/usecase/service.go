@@ -6,19 +6,23 @@ import (
 
 	"hex-postgres-grpc/internal/auth"
 	domain_common "hex-postgres-grpc/internal/common/domain"
+	"hex-postgres-grpc/internal/common/events"
+	"hex-postgres-grpc/internal/common/uow"
 	product "hex-postgres-grpc/internal/product/domain"
 
 	"github.com/google/uuid"
 )
 
-const SystemUserID = "00000000-0000-0000-0000-000000000000"
-
 type service struct {
-	repo product.Repository
+	repo   product.Repository
+	uow    uow.UnitOfWork
+	outbox events.Outbox
 }
 
-func NewService(repo product.Repository) product.Service {
-	return &service{repo: repo}
+// NewService records a ProductPriceChanged event in outbox, in the unit of
+// work of the update, whenever the price of a product changes.
+func NewService(repo product.Repository, unitOfWork uow.UnitOfWork, outbox events.Outbox) product.Service {
+	return &service{repo: repo, uow: unitOfWork, outbox: outbox}
 }
 
 func (s *service) CreateProduct(ctx context.Context, name string, price float64) (product.Product, error) {
@@ -27,7 +31,7 @@ func (s *service) CreateProduct(ctx context.Context, name string, price float64)
 	}
 
 	sub, _ := auth.SubjectFromContext(ctx)
-	createdBy := SystemUserID
+	createdBy := domain_common.SystemUserID
 	if sub.ID != "" {
 		createdBy = sub.ID
 	}
@@ -35,9 +39,11 @@ func (s *service) CreateProduct(ctx context.Context, name string, price float64)
 	id := uuid.NewString()
 	p := product.Product{
 		BaseEntity: domain_common.BaseEntity{
-			ID:        id,
-			CreatedAt: time.Now(),
-			CreatedBy: createdBy,
+			ID:             id,
+			CreatedAt:      time.Now(),
+			CreatedBy:      createdBy,
+			CreatedByActor: sub.Actor(),
+			Version:        1,
 		},
 		Name:  name,
 		Price: price,
@@ -56,42 +62,72 @@ func (s *service) GetProduct(ctx context.Context, id string) (product.Product, e
 	return *p, nil
 }
 
-func (s *service) UpdateProduct(ctx context.Context, id string, name string, price float64) (product.Product, error) {
+func (s *service) UpdateProduct(ctx context.Context, id string, name string, price float64, version int64) (product.Product, error) {
 	p, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return product.Product{}, err
 	}
+	if version != 0 && p.Version != version {
+		return product.Product{}, product.ErrVersionMismatch
+	}
 
 	if price < 0 {
 		return product.Product{}, product.ErrInvalidPrice
 	}
 
 	sub, _ := auth.SubjectFromContext(ctx)
-	updatedBy := SystemUserID
+	updatedBy := domain_common.SystemUserID
 	if sub.ID != "" {
 		updatedBy = sub.ID
 	}
 
+	var pending []events.Event
+	if price != p.Price {
+		event, err := events.New(product.EventProductPriceChanged, product.AggregateType, p.ID, product.ProductPriceChanged{
+			ProductID: p.ID,
+			OldPrice:  p.Price,
+			NewPrice:  price,
+			ChangedBy: updatedBy,
+		})
+		if err != nil {
+			return product.Product{}, err
+		}
+		pending = append(pending, event)
+	}
+
 	now := time.Now()
 	p.Name = name
 	p.Price = price
 	p.UpdatedAt = &now
 	p.UpdatedBy = &updatedBy
-
-	if err := s.repo.Update(ctx, p); err != nil {
+	p.UpdatedByActor = sub.Actor()
+
+	// The unit of work may run more than once, so each run starts from p.
+	var updated product.Product
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		updated = *p
+		if err := s.repo.Update(ctx, &updated); err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		return s.outbox.Record(ctx, pending...)
+	})
+	if err != nil {
 		return product.Product{}, err
 	}
 
-	return *p, nil
+	return updated, nil
 }
 
 func (s *service) DeleteProduct(ctx context.Context, id string) error {
 	sub, _ := auth.SubjectFromContext(ctx)
-	deletedBy := SystemUserID
+	deletedBy := domain_common.SystemUserID
 	if sub.ID != "" {
 		deletedBy = sub.ID
 	}
-	return s.repo.Delete(ctx, id, deletedBy)
+	return s.repo.Delete(ctx, id, deletedBy, sub.Actor())
 }
 
 func (s *service) ListProductsPaginated(ctx context.Context, page, limit int) (product.PaginatedResponse, error) {
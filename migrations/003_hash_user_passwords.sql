@@ -0,0 +1,9 @@
+-- Rehash any plaintext passwords left in the users table (e.g. the seeds from 001).
+-- pgcrypto produces $2a$ bcrypt hashes, which the application verifies and
+-- transparently upgrades to its configured cost on the next successful login.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+UPDATE users
+SET password_hash = crypt(password_hash, gen_salt('bf', 12)),
+    updated_at = CURRENT_TIMESTAMP
+WHERE password_hash NOT LIKE '$2_$%';
@@ -0,0 +1,10 @@
+-- Create signing_keys table
+-- Holds the asymmetric keys used to sign access tokens. Keys past expires_at
+-- are no longer published in the JWKS nor accepted for verification.
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id TEXT PRIMARY KEY,
+    algorithm TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
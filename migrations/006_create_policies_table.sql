@@ -0,0 +1,27 @@
+-- Create policies table
+-- ABAC policies loaded by the policy engine and reloaded without a restart.
+-- condition is an expression over subject/resource attributes, e.g.
+-- 'resource.owner_id == subject.id'; an empty condition always holds.
+CREATE TABLE IF NOT EXISTS policies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subject_role TEXT NOT NULL,
+    action TEXT NOT NULL,
+    resource_type TEXT NOT NULL DEFAULT '*',
+    condition TEXT NOT NULL DEFAULT '',
+    priority INT NOT NULL DEFAULT 0,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Seed the built-in policy set (same as config/policies.yaml)
+INSERT INTO policies (subject_role, action, resource_type, condition, priority, description)
+SELECT * FROM (VALUES
+    ('admin', 'create', '*', '', 0, 'Admins can create everything'),
+    ('admin', 'read', '*', '', 0, 'Admins can read everything'),
+    ('admin', 'update', '*', '', 0, 'Admins can update everything'),
+    ('admin', 'delete', '*', '', 0, 'Admins can delete everything'),
+    ('user', 'read', 'user', '', 10, 'Users can read users'),
+    ('user', 'update', 'customer', 'resource.owner_id == subject.id', 10, 'Owners can update their own customer record'),
+    ('user', 'read', '*', '', 20, 'Users can read everything')
+) AS seed(subject_role, action, resource_type, condition, priority, description)
+WHERE NOT EXISTS (SELECT 1 FROM policies);
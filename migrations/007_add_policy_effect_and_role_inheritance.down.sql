@@ -0,0 +1,4 @@
+-- Deny policies cannot be expressed without the effect column and are removed.
+DROP TABLE IF EXISTS role_inheritance;
+DELETE FROM policies WHERE effect = 'deny';
+ALTER TABLE policies DROP COLUMN IF EXISTS effect;
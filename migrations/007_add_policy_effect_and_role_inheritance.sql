@@ -0,0 +1,21 @@
+-- Deny rules and role inheritance for the policy engine.
+-- A matching deny always overrides any allow. A role inherits every policy of
+-- the roles listed for it in role_inheritance.
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS effect TEXT NOT NULL DEFAULT 'allow'
+    CHECK (effect IN ('allow', 'deny'));
+
+CREATE TABLE IF NOT EXISTS role_inheritance (
+    role TEXT NOT NULL,
+    inherits TEXT NOT NULL,
+    PRIMARY KEY (role, inherits),
+    CHECK (role <> inherits)
+);
+
+INSERT INTO role_inheritance (role, inherits)
+VALUES ('admin', 'user')
+ON CONFLICT DO NOTHING;
+
+-- Only admins may inspect policies and authorization decisions
+INSERT INTO policies (subject_role, action, resource_type, condition, effect, priority, description)
+SELECT 'user', '*', 'policy', 'subject.role != "admin"', 'deny', 0, 'Only admins may inspect policies and authorization decisions'
+WHERE NOT EXISTS (SELECT 1 FROM policies WHERE resource_type = 'policy' AND effect = 'deny');
@@ -0,0 +1,3 @@
+DELETE FROM policies WHERE resource_type = 'api_key' AND effect = 'deny';
+DROP TABLE IF EXISTS api_keys;
+ALTER TABLE users DROP COLUMN IF EXISTS service_account;
@@ -0,0 +1,25 @@
+-- Service accounts and their API keys
+-- Service accounts have no usable password and authenticate with API keys.
+-- Keys are stored as SHA-256 hashes; prefix holds the leading characters of
+-- the key so that it can be recognised in listings.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS service_account BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    prefix TEXT NOT NULL,
+    key_hash TEXT UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+
+-- Only admins may manage API keys
+INSERT INTO policies (subject_role, action, resource_type, condition, effect, priority, description)
+SELECT 'user', '*', 'api_key', 'subject.role != "admin"', 'deny', 0, 'Only admins may manage API keys'
+WHERE NOT EXISTS (SELECT 1 FROM policies WHERE resource_type = 'api_key' AND effect = 'deny');
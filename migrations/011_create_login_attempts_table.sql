@@ -0,0 +1,10 @@
+-- Create login_attempts table
+-- Failed logins are counted per key, "user:<username>" or "ip:<address>",
+-- including usernames that do not exist. locked_until is set once a key has
+-- crossed its threshold.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
@@ -0,0 +1,12 @@
+-- Account deactivation and soft delete
+-- Disabled users cannot sign in and their existing tokens stop working.
+-- Deleted users are kept with deleted_at set; their username and email become
+-- available again, so uniqueness only applies to rows that are not deleted.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_active ON users (username) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users (lower(email)) WHERE deleted_at IS NULL;
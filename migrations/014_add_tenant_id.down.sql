@@ -0,0 +1,19 @@
+-- Fails when usernames, email addresses or identities are only unique per
+-- tenant; merge or remove the other tenants first.
+ALTER TABLE user_identities DROP CONSTRAINT IF EXISTS user_identities_pkey;
+ALTER TABLE user_identities ADD PRIMARY KEY (issuer, subject);
+
+DROP INDEX IF EXISTS idx_customers_tenant_email;
+ALTER TABLE customers ADD CONSTRAINT customers_email_key UNIQUE (email);
+
+DROP INDEX IF EXISTS idx_users_tenant_email_active;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users (lower(email)) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_users_tenant_username_active;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_active ON users (username) WHERE deleted_at IS NULL;
+
+ALTER TABLE IF EXISTS category DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE IF EXISTS orders DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE IF EXISTS products DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE user_identities DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE customers DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
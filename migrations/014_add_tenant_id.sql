@@ -0,0 +1,23 @@
+-- Multi-tenancy
+-- Every tenant-owned row carries the tenant it belongs to. Rows that existed
+-- before tenants were introduced belong to the 'default' tenant. Usernames,
+-- user and customer email addresses and external identities are unique per
+-- tenant. The products, orders and category tables are not created by these
+-- migrations and are only altered when they exist.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE user_identities ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE IF EXISTS products ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE IF EXISTS orders ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE IF EXISTS category ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS idx_users_username_active;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_username_active ON users (tenant_id, username) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_users_email_active;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_email_active ON users (tenant_id, lower(email)) WHERE deleted_at IS NULL;
+
+ALTER TABLE customers DROP CONSTRAINT IF EXISTS customers_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_customers_tenant_email ON customers (tenant_id, email);
+
+ALTER TABLE user_identities DROP CONSTRAINT IF EXISTS user_identities_pkey;
+ALTER TABLE user_identities ADD PRIMARY KEY (tenant_id, issuer, subject);
@@ -0,0 +1,12 @@
+DELETE FROM policies WHERE resource_type = 'impersonation' AND effect = 'deny';
+
+ALTER TABLE IF EXISTS customers DROP COLUMN IF EXISTS created_by_actor;
+ALTER TABLE IF EXISTS customers DROP COLUMN IF EXISTS created_by;
+
+ALTER TABLE IF EXISTS category DROP COLUMN IF EXISTS updated_by_actor;
+ALTER TABLE IF EXISTS category DROP COLUMN IF EXISTS created_by_actor;
+ALTER TABLE IF EXISTS products DROP COLUMN IF EXISTS deleted_by_actor;
+ALTER TABLE IF EXISTS products DROP COLUMN IF EXISTS updated_by_actor;
+ALTER TABLE IF EXISTS products DROP COLUMN IF EXISTS created_by_actor;
+
+DROP TABLE IF EXISTS impersonations;
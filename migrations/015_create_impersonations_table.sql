@@ -0,0 +1,33 @@
+-- Admin impersonation
+-- An impersonation session lets an administrator (actor_id) act as another
+-- user (subject_id) until expires_at or until it is ended. Tokens issued for
+-- a session are refused once ended_at is set. Writes made while
+-- impersonating record the administrator in the *_by_actor columns.
+CREATE TABLE IF NOT EXISTS impersonations (
+    id UUID PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subject_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ended_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonations_actor_id ON impersonations (tenant_id, actor_id);
+CREATE INDEX IF NOT EXISTS idx_impersonations_subject_id ON impersonations (tenant_id, subject_id);
+
+ALTER TABLE IF EXISTS products ADD COLUMN IF NOT EXISTS created_by_actor TEXT;
+ALTER TABLE IF EXISTS products ADD COLUMN IF NOT EXISTS updated_by_actor TEXT;
+ALTER TABLE IF EXISTS products ADD COLUMN IF NOT EXISTS deleted_by_actor TEXT;
+ALTER TABLE IF EXISTS category ADD COLUMN IF NOT EXISTS created_by_actor TEXT;
+ALTER TABLE IF EXISTS category ADD COLUMN IF NOT EXISTS updated_by_actor TEXT;
+
+-- Customers record the user who created them as well
+ALTER TABLE IF EXISTS customers ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE IF EXISTS customers ADD COLUMN IF NOT EXISTS created_by_actor TEXT;
+
+-- Only admins may impersonate, and administrators cannot be impersonated
+INSERT INTO policies (subject_role, action, resource_type, condition, effect, priority, description)
+SELECT 'user', '*', 'impersonation', 'subject.role != "admin" || resource.role == "admin"', 'deny', 0, 'Only admins may impersonate, and administrators cannot be impersonated'
+WHERE NOT EXISTS (SELECT 1 FROM policies WHERE resource_type = 'impersonation' AND effect = 'deny');
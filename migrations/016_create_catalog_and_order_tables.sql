@@ -0,0 +1,79 @@
+-- Create products, category and orders tables
+-- These tables used to be created by hand from the README, so they may
+-- already exist. Missing columns, including the audit columns and the
+-- tenant, are added to existing tables as well.
+CREATE TABLE IF NOT EXISTS products (
+    id UUID PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    name TEXT NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP WITH TIME ZONE,
+    updated_by TEXT,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    deleted_by TEXT,
+    created_by_actor TEXT,
+    updated_by_actor TEXT,
+    deleted_by_actor TEXT
+);
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS updated_by TEXT;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS created_by_actor TEXT;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS updated_by_actor TEXT;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_by_actor TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_products_tenant_id ON products (tenant_id) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS category (
+    id UUID PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    name TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP WITH TIME ZONE,
+    updated_by TEXT,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    deleted_by TEXT,
+    created_by_actor TEXT,
+    updated_by_actor TEXT
+);
+
+ALTER TABLE category ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE category ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE category ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE category ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE category ADD COLUMN IF NOT EXISTS updated_by TEXT;
+ALTER TABLE category ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE category ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+ALTER TABLE category ADD COLUMN IF NOT EXISTS created_by_actor TEXT;
+ALTER TABLE category ADD COLUMN IF NOT EXISTS updated_by_actor TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_category_tenant_id ON category (tenant_id) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS orders (
+    id UUID PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    amount DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP WITH TIME ZONE,
+    updated_by TEXT,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    deleted_by TEXT
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_by TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_orders_tenant_id ON orders (tenant_id);
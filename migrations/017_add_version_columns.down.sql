@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS version;
+ALTER TABLE category DROP COLUMN IF EXISTS version;
+ALTER TABLE products DROP COLUMN IF EXISTS version;
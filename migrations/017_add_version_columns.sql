@@ -0,0 +1,6 @@
+-- Add row versions for optimistic concurrency control
+-- Every update increments the version and only applies when the row still
+-- has the version the change was based on.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE category ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
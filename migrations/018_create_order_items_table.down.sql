@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_items;
+DROP INDEX IF EXISTS idx_orders_tenant_customer;
+ALTER TABLE orders DROP COLUMN IF EXISTS customer_id;
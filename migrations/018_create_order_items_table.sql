@@ -0,0 +1,20 @@
+-- Link orders to a customer and record their line items
+-- Orders created before this migration have no customer. unit_price is the
+-- price of the product when the item was added. Customers and products
+-- belong to other modules and are checked by the application, so there are
+-- no foreign keys to them.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS customer_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_orders_tenant_customer ON orders (tenant_id, customer_id);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    tenant_id TEXT NOT NULL,
+    product_id UUID NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (order_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_tenant_product ON order_items (tenant_id, product_id);
@@ -0,0 +1,9 @@
+DELETE FROM policies WHERE resource_type = 'order' AND effect = 'deny';
+DELETE FROM policies WHERE resource_type = 'order' AND action = 'create' AND subject_role = 'user';
+
+DROP INDEX IF EXISTS idx_orders_tenant_id;
+CREATE INDEX IF NOT EXISTS idx_orders_tenant_id ON orders (tenant_id);
+
+ALTER TABLE orders DROP COLUMN IF EXISTS deleted_by_actor;
+ALTER TABLE orders DROP COLUMN IF EXISTS updated_by_actor;
+ALTER TABLE orders DROP COLUMN IF EXISTS created_by_actor;
@@ -0,0 +1,18 @@
+-- Order ownership, audit and soft delete
+-- Orders record who wrote them like products do, and are soft-deleted.
+-- The user who creates an order owns it: users of the user role may place
+-- orders and only see and change their own.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS created_by_actor TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_by_actor TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_by_actor TEXT;
+
+DROP INDEX IF EXISTS idx_orders_tenant_id;
+CREATE INDEX IF NOT EXISTS idx_orders_tenant_id ON orders (tenant_id) WHERE deleted_at IS NULL;
+
+INSERT INTO policies (subject_role, action, resource_type, condition, effect, priority, description)
+SELECT 'user', 'create', 'order', '', 'allow', 0, 'Users may place orders'
+WHERE NOT EXISTS (SELECT 1 FROM policies WHERE resource_type = 'order' AND action = 'create' AND subject_role = 'user');
+
+INSERT INTO policies (subject_role, action, resource_type, condition, effect, priority, description)
+SELECT 'user', '*', 'order', 'subject.role != "admin" && resource.owner_id != null && resource.owner_id != subject.id', 'deny', 0, 'Users only see and change their own orders'
+WHERE NOT EXISTS (SELECT 1 FROM policies WHERE resource_type = 'order' AND effect = 'deny');
@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL migrations of the schema, applied by
+// internal/migrate. Scripts in optional/ are not part of it and are run by
+// hand.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,21 @@
+-- Optional: row-level security for tenant isolation
+-- The application scopes every query by tenant itself. This script makes
+-- Postgres enforce the same isolation for other roles that read the database
+-- directly, such as reporting users. Their sessions see nothing until they
+-- name a tenant:
+--   SET app.tenant_id = 'acme';
+-- Table owners and superusers bypass these policies, so the application,
+-- which owns the tables, is not affected. Run after the numbered migrations.
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['users', 'customers', 'user_identities', 'products', 'orders', 'order_items', 'order_status_history', 'category', 'idempotency_keys', 'impersonations', 'outbox_events', 'outbox_dead_letters'] LOOP
+        IF to_regclass(t) IS NOT NULL THEN
+            EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+            EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %I', t);
+            EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_id = current_setting(''app.tenant_id'', true)) WITH CHECK (tenant_id = current_setting(''app.tenant_id'', true))', t);
+        END IF;
+    END LOOP;
+END
+$$;
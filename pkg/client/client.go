@@ -0,0 +1,122 @@
+// Package client is a Go client for the gRPC API. It logs in once and then
+// attaches a bearer token to every call, refreshing it before it expires.
+package client
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	authpb "hex-postgres-grpc/proto/auth"
+	categorypb "hex-postgres-grpc/proto/category"
+	customerpb "hex-postgres-grpc/proto/customer"
+	orderpb "hex-postgres-grpc/proto/order"
+	productpb "hex-postgres-grpc/proto/product"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+type Config struct {
+	Target string
+	// APIKey authenticates a service account. When it is set, Username and
+	// Password are ignored.
+	APIKey   string
+	Username string
+	Password string
+	// Tenant is sent with every call, including the login. It can be left
+	// empty for the default tenant.
+	Tenant string
+	// Insecure dials without TLS and allows tokens to be sent in plain text.
+	// Use it for local development only.
+	Insecure bool
+	// RefreshSkew defaults to DefaultRefreshSkew.
+	RefreshSkew time.Duration
+	// DialOptions are appended to the options set by New, e.g. to supply
+	// transport credentials.
+	DialOptions []grpc.DialOption
+}
+
+type Client struct {
+	conn   *grpc.ClientConn
+	tokens *TokenSource
+
+	Auth       authpb.AuthServiceClient
+	Orders     orderpb.ORderServiceClient
+	Products   productpb.ProductServiceClient
+	Customers  customerpb.CustomerServiceClient
+	Categories categorypb.CategoryServiceClient
+}
+
+// authServicePrefix marks calls that must go out without a bearer token; the
+// token source itself uses them to obtain one.
+const authServicePrefix = "/authpb.AuthService/"
+
+const tenantMetadataKey = "x-tenant-id"
+
+func New(cfg Config) (*Client, error) {
+	c := &Client{}
+	bearer := &bearerCredentials{requireTLS: !cfg.Insecure}
+	var creds credentials.PerRPCCredentials = bearer
+	if cfg.APIKey != "" {
+		creds = &apiKeyCredentials{key: cfg.APIKey, requireTLS: !cfg.Insecure}
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if cfg.Tenant != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, tenantMetadataKey, cfg.Tenant)
+			}
+			if !strings.HasPrefix(method, authServicePrefix) {
+				opts = append(opts, grpc.PerRPCCredentials(creds))
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			if cfg.Tenant != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, tenantMetadataKey, cfg.Tenant)
+			}
+			if !strings.HasPrefix(method, authServicePrefix) {
+				opts = append(opts, grpc.PerRPCCredentials(creds))
+			}
+			return streamer(ctx, desc, cc, method, opts...)
+		}),
+	}
+	if cfg.Insecure {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	opts = append(opts, cfg.DialOptions...)
+
+	conn, err := grpc.NewClient(cfg.Target, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.conn = conn
+	c.Auth = authpb.NewAuthServiceClient(conn)
+	c.tokens = NewTokenSource(c.Auth, cfg.Username, cfg.Password, cfg.RefreshSkew)
+	bearer.tokens = c.tokens
+
+	c.Orders = orderpb.NewORderServiceClient(conn)
+	c.Products = productpb.NewProductServiceClient(conn)
+	c.Customers = customerpb.NewCustomerServiceClient(conn)
+	c.Categories = categorypb.NewCategoryServiceClient(conn)
+	return c, nil
+}
+
+// Tokens exposes the token source, e.g. to log in eagerly with Token. It is
+// unused when the client authenticates with an API key.
+func (c *Client) Tokens() *TokenSource {
+	return c.tokens
+}
+
+// Close revokes the session's refresh token and closes the connection.
+func (c *Client) Close(ctx context.Context) error {
+	logoutErr := c.tokens.Logout(ctx)
+	if err := c.conn.Close(); err != nil {
+		return err
+	}
+	return logoutErr
+}
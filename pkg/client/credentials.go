@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	authpb "hex-postgres-grpc/proto/auth"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// DefaultRefreshSkew is how long before expiry an access token is refreshed.
+const DefaultRefreshSkew = 30 * time.Second
+
+// ErrMFARequired is returned when the account has MFA enabled. Unattended
+// clients should authenticate with a service account API key instead.
+var ErrMFARequired = errors.New("account requires multi-factor authentication")
+
+// TokenSource logs in with a username and password and keeps the access
+// token fresh: it refreshes shortly before expiry and falls back to a new
+// login when the refresh token is rejected.
+type TokenSource struct {
+	auth     authpb.AuthServiceClient
+	username string
+	password string
+	skew     time.Duration
+
+	mu      sync.Mutex
+	access  string
+	refresh string
+	expiry  time.Time
+}
+
+func NewTokenSource(auth authpb.AuthServiceClient, username, password string, skew time.Duration) *TokenSource {
+	if skew <= 0 {
+		skew = DefaultRefreshSkew
+	}
+	return &TokenSource{auth: auth, username: username, password: password, skew: skew}
+}
+
+// Token returns a valid access token, logging in or refreshing as needed.
+func (t *TokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.access != "" && time.Now().Add(t.skew).Before(t.expiry) {
+		return t.access, nil
+	}
+
+	if t.refresh != "" {
+		resp, err := t.auth.Refresh(ctx, &authpb.RefreshRequest{RefreshToken: t.refresh})
+		if err == nil {
+			return t.store(resp.GetTokens())
+		}
+		t.refresh = ""
+	}
+
+	resp, err := t.auth.Login(ctx, &authpb.LoginRequest{Username: t.username, Password: t.password})
+	if err != nil {
+		return "", err
+	}
+	if resp.GetMfaRequired() {
+		return "", ErrMFARequired
+	}
+	return t.store(resp.GetTokens())
+}
+
+// Logout revokes the current refresh token and forgets the cached tokens.
+func (t *TokenSource) Logout(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	refresh := t.refresh
+	t.access, t.refresh, t.expiry = "", "", time.Time{}
+	if refresh == "" {
+		return nil
+	}
+	_, err := t.auth.Logout(ctx, &authpb.LogoutRequest{RefreshToken: refresh})
+	return err
+}
+
+func (t *TokenSource) store(tokens *authpb.TokenPairMessage) (string, error) {
+	if tokens.GetAccessToken() == "" {
+		return "", errors.New("auth service returned no access token")
+	}
+	t.access = tokens.GetAccessToken()
+	t.refresh = tokens.GetRefreshToken()
+	t.expiry = time.Now().Add(time.Duration(tokens.GetExpiresIn()) * time.Second)
+	return t.access, nil
+}
+
+// bearerCredentials attaches the token source's access token to each RPC.
+type bearerCredentials struct {
+	tokens     *TokenSource
+	requireTLS bool
+}
+
+var _ credentials.PerRPCCredentials = (*bearerCredentials)(nil)
+
+func (c *bearerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (c *bearerCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+// apiKeyCredentials sends a service account's API key with each RPC.
+type apiKeyCredentials struct {
+	key        string
+	requireTLS bool
+}
+
+var _ credentials.PerRPCCredentials = (*apiKeyCredentials)(nil)
+
+func (c *apiKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"x-api-key": c.key}, nil
+}
+
+func (c *apiKeyCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
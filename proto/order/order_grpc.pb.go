@@ -19,11 +19,14 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ORderService_CreateOrder_FullMethodName = "/orderpb.ORderService/CreateOrder"
-	ORderService_GetOrder_FullMethodName    = "/orderpb.ORderService/GetOrder"
-	ORderService_UpdateOrder_FullMethodName = "/orderpb.ORderService/UpdateOrder"
-	ORderService_DeleteOrder_FullMethodName = "/orderpb.ORderService/DeleteOrder"
-	ORderService_ListOrders_FullMethodName  = "/orderpb.ORderService/ListOrders"
+	ORderService_CreateOrder_FullMethodName          = "/orderpb.ORderService/CreateOrder"
+	ORderService_GetOrder_FullMethodName             = "/orderpb.ORderService/GetOrder"
+	ORderService_UpdateOrder_FullMethodName          = "/orderpb.ORderService/UpdateOrder"
+	ORderService_DeleteOrder_FullMethodName          = "/orderpb.ORderService/DeleteOrder"
+	ORderService_ListOrders_FullMethodName           = "/orderpb.ORderService/ListOrders"
+	ORderService_TransitionOrder_FullMethodName      = "/orderpb.ORderService/TransitionOrder"
+	ORderService_ListOrderTransitions_FullMethodName = "/orderpb.ORderService/ListOrderTransitions"
+	ORderService_RestoreOrder_FullMethodName         = "/orderpb.ORderService/RestoreOrder"
 )
 
 // ORderServiceClient is the client API for ORderService service.
@@ -35,6 +38,9 @@ type ORderServiceClient interface {
 	UpdateOrder(ctx context.Context, in *UpdateOrderRequest, opts ...grpc.CallOption) (*UpdateOrderResponse, error)
 	DeleteOrder(ctx context.Context, in *DeleteOrderRequest, opts ...grpc.CallOption) (*DeleteOrderResponse, error)
 	ListOrders(ctx context.Context, in *ListOrdersRequest, opts ...grpc.CallOption) (*ListOrdersResponse, error)
+	TransitionOrder(ctx context.Context, in *TransitionOrderRequest, opts ...grpc.CallOption) (*TransitionOrderResponse, error)
+	ListOrderTransitions(ctx context.Context, in *ListOrderTransitionsRequest, opts ...grpc.CallOption) (*ListOrderTransitionsResponse, error)
+	RestoreOrder(ctx context.Context, in *RestoreOrderRequest, opts ...grpc.CallOption) (*RestoreOrderResponse, error)
 }
 
 type oRderServiceClient struct {
@@ -95,6 +101,36 @@ func (c *oRderServiceClient) ListOrders(ctx context.Context, in *ListOrdersReque
 	return out, nil
 }
 
+func (c *oRderServiceClient) TransitionOrder(ctx context.Context, in *TransitionOrderRequest, opts ...grpc.CallOption) (*TransitionOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransitionOrderResponse)
+	err := c.cc.Invoke(ctx, ORderService_TransitionOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oRderServiceClient) ListOrderTransitions(ctx context.Context, in *ListOrderTransitionsRequest, opts ...grpc.CallOption) (*ListOrderTransitionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListOrderTransitionsResponse)
+	err := c.cc.Invoke(ctx, ORderService_ListOrderTransitions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oRderServiceClient) RestoreOrder(ctx context.Context, in *RestoreOrderRequest, opts ...grpc.CallOption) (*RestoreOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestoreOrderResponse)
+	err := c.cc.Invoke(ctx, ORderService_RestoreOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ORderServiceServer is the server API for ORderService service.
 // All implementations must embed UnimplementedORderServiceServer
 // for forward compatibility.
@@ -104,6 +140,9 @@ type ORderServiceServer interface {
 	UpdateOrder(context.Context, *UpdateOrderRequest) (*UpdateOrderResponse, error)
 	DeleteOrder(context.Context, *DeleteOrderRequest) (*DeleteOrderResponse, error)
 	ListOrders(context.Context, *ListOrdersRequest) (*ListOrdersResponse, error)
+	TransitionOrder(context.Context, *TransitionOrderRequest) (*TransitionOrderResponse, error)
+	ListOrderTransitions(context.Context, *ListOrderTransitionsRequest) (*ListOrderTransitionsResponse, error)
+	RestoreOrder(context.Context, *RestoreOrderRequest) (*RestoreOrderResponse, error)
 	mustEmbedUnimplementedORderServiceServer()
 }
 
@@ -129,6 +168,15 @@ func (UnimplementedORderServiceServer) DeleteOrder(context.Context, *DeleteOrder
 func (UnimplementedORderServiceServer) ListOrders(context.Context, *ListOrdersRequest) (*ListOrdersResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method ListOrders not implemented")
 }
+func (UnimplementedORderServiceServer) TransitionOrder(context.Context, *TransitionOrderRequest) (*TransitionOrderResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method TransitionOrder not implemented")
+}
+func (UnimplementedORderServiceServer) ListOrderTransitions(context.Context, *ListOrderTransitionsRequest) (*ListOrderTransitionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListOrderTransitions not implemented")
+}
+func (UnimplementedORderServiceServer) RestoreOrder(context.Context, *RestoreOrderRequest) (*RestoreOrderResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RestoreOrder not implemented")
+}
 func (UnimplementedORderServiceServer) mustEmbedUnimplementedORderServiceServer() {}
 func (UnimplementedORderServiceServer) testEmbeddedByValue()                      {}
 
@@ -240,6 +288,60 @@ func _ORderService_ListOrders_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ORderService_TransitionOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransitionOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ORderServiceServer).TransitionOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ORderService_TransitionOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ORderServiceServer).TransitionOrder(ctx, req.(*TransitionOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ORderService_ListOrderTransitions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListOrderTransitionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ORderServiceServer).ListOrderTransitions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ORderService_ListOrderTransitions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ORderServiceServer).ListOrderTransitions(ctx, req.(*ListOrderTransitionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ORderService_RestoreOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ORderServiceServer).RestoreOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ORderService_RestoreOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ORderServiceServer).RestoreOrder(ctx, req.(*RestoreOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ORderService_ServiceDesc is the grpc.ServiceDesc for ORderService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -267,6 +369,18 @@ var ORderService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListOrders",
 			Handler:    _ORderService_ListOrders_Handler,
 		},
+		{
+			MethodName: "TransitionOrder",
+			Handler:    _ORderService_TransitionOrder_Handler,
+		},
+		{
+			MethodName: "ListOrderTransitions",
+			Handler:    _ORderService_ListOrderTransitions_Handler,
+		},
+		{
+			MethodName: "RestoreOrder",
+			Handler:    _ORderService_RestoreOrder_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/order/order.proto",